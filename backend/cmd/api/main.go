@@ -1,17 +1,36 @@
 package main
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
 
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/config"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/handlers"
 	"pharmacy-backend/internal/middleware"
+	"pharmacy-backend/internal/models"
 
 	"github.com/gorilla/mux"
 )
 
+// Role groups used to gate routes
+var (
+	ownerOnly    = []models.Role{models.RoleOwner}
+	managers     = []models.Role{models.RoleOwner, models.RolePharmacist}
+	stockStaff   = []models.Role{models.RoleOwner, models.RolePharmacist, models.RoleStockClerk}
+	counterStaff = []models.Role{models.RoleOwner, models.RolePharmacist, models.RoleCashier}
+)
+
+// placeholderSecrets are example JWT secrets from earlier docs and compose
+// files; anyone could sign tokens with them
+var placeholderSecrets = map[string]bool{
+	"change-me":               true,
+	"change-me-in-production": true,
+}
+
 func main() {
 	// Initialize database connection
 	if err := database.InitDB(); err != nil {
@@ -19,50 +38,151 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	cfg := config.Load()
+	if cfg.Auth.JWTSecret == "" {
+		log.Fatalf("JWT_SECRET is not set; set a random secret (openssl rand -hex 32)")
+	}
+	if placeholderSecrets[cfg.Auth.JWTSecret] {
+		log.Fatalf("JWT_SECRET is set to a published placeholder; set a random secret")
+	}
+
+	if err := database.SetBarcodePrefix(cfg.Catalog.BarcodePrefix); err != nil {
+		log.Fatalf("Invalid BARCODE_PREFIX: %v", err)
+	}
+	if err := database.SetProductCodePattern(cfg.Catalog.ProductCodePattern); err != nil {
+		log.Fatalf("Invalid PRODUCT_CODE_PATTERN: %v", err)
+	}
+
+	tokens := auth.NewTokens(cfg.Auth.JWTSecret, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+
+	if err := bootstrapAdmin(database.GetDB(), cfg.Auth); err != nil {
+		log.Fatalf("Failed to create admin user: %v", err)
+	}
+
 	// Create router
 	r := mux.NewRouter()
 
 	// Apply middleware
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logging)
 
 	// Initialize handlers
-	h := handlers.New(database.GetDB())
+	h := handlers.New(database.GetDB(), tokens)
 
 	// API routes
 	api := r.PathPrefix("/api").Subrouter()
 
-	// Health check
+	// Public routes
 	api.HandleFunc("/health", h.Health).Methods("GET")
+	api.HandleFunc("/auth/login", h.Login).Methods("POST")
+	api.HandleFunc("/auth/refresh", h.RefreshToken).Methods("POST")
+	api.HandleFunc("/auth/logout", h.Logout).Methods("POST")
+
+	// Everything else requires a bearer token
+	secured := api.NewRoute().Subrouter()
+	secured.Use(middleware.Auth(tokens))
+
+	// Auth & user routes
+	secured.HandleFunc("/auth/me", h.Me).Methods("GET")
+	secured.HandleFunc("/users", middleware.RequireRole(h.GetUsers, ownerOnly...)).Methods("GET")
+	secured.HandleFunc("/users", middleware.RequireRole(h.CreateUser, ownerOnly...)).Methods("POST")
+	secured.HandleFunc("/users/{id}", middleware.RequireRole(h.UpdateUser, ownerOnly...)).Methods("PUT")
 
 	// Inventory routes
-	inventory := api.PathPrefix("/inventory").Subrouter()
+	inventory := secured.PathPrefix("/inventory").Subrouter()
 	inventory.HandleFunc("/medicines", h.GetMedicines).Methods("GET")
 	inventory.HandleFunc("/medicines/{id}", h.GetMedicineByID).Methods("GET")
-	inventory.HandleFunc("/medicines", h.CreateMedicine).Methods("POST")
-	inventory.HandleFunc("/medicines/{id}", h.UpdateMedicine).Methods("PUT")
-	inventory.HandleFunc("/medicines/{id}", h.DeleteMedicine).Methods("DELETE")
+	inventory.HandleFunc("/medicines", middleware.RequireRole(h.CreateMedicine, stockStaff...)).Methods("POST")
+	inventory.HandleFunc("/medicines/{id}", middleware.RequireRole(h.UpdateMedicine, stockStaff...)).Methods("PUT")
+	inventory.HandleFunc("/medicines/{id}", middleware.RequireRole(h.DeleteMedicine, managers...)).Methods("DELETE")
+	inventory.HandleFunc("/medicines/{id}/batches", middleware.RequireRole(h.GetMedicineBatches, stockStaff...)).Methods("GET")
+	inventory.HandleFunc("/medicines/{id}/stock-history", h.GetStockHistory).Methods("GET")
+	inventory.HandleFunc("/medicines/{id}/stock-adjustments", middleware.RequireRole(h.AdjustStock, stockStaff...)).Methods("POST")
+	inventory.HandleFunc("/batches/quarantine", middleware.RequireRole(h.QuarantineBatches, stockStaff...)).Methods("POST")
+	inventory.HandleFunc("/batches/{id}/write-off", middleware.RequireRole(h.WriteOffBatch, stockStaff...)).Methods("POST")
+	inventory.HandleFunc("/batches/{id}/sales", middleware.RequireRole(h.GetBatchSales, managers...)).Methods("GET")
 	inventory.HandleFunc("/racks", h.GetRacks).Methods("GET")
 	inventory.HandleFunc("/racks/medicines", h.GetRackMedicines).Methods("GET")
-	inventory.HandleFunc("/racks", h.CreateRack).Methods("POST")
+	inventory.HandleFunc("/racks", middleware.RequireRole(h.CreateRack, stockStaff...)).Methods("POST")
 	inventory.HandleFunc("/generics", h.GetGenerics).Methods("GET")
+	inventory.HandleFunc("/pack-units", h.GetPackUnits).Methods("GET")
+	inventory.HandleFunc("/pack-units", middleware.RequireRole(h.CreatePackUnit, managers...)).Methods("POST")
+
+	// Ingredient routes
+	secured.HandleFunc("/ingredients", h.GetIngredients).Methods("GET")
+	secured.HandleFunc("/ingredients", middleware.RequireRole(h.CreateIngredient, stockStaff...)).Methods("POST")
+	secured.HandleFunc("/ingredients/products", h.GetIngredientProducts).Methods("GET")
+
+	// Search routes
+	secured.HandleFunc("/search/suggest", h.SuggestProducts).Methods("GET")
 
 	// Product routes (new API with exact frontend response format)
+	secured.HandleFunc("/products/barcode/{code}", h.GetProductByBarcode).Methods("GET")
+	secured.HandleFunc("/products/codes/regenerate", middleware.RequireRole(h.RegenerateProductCodes, managers...)).Methods("POST")
+	secured.HandleFunc("/products/{id}/substitutes", h.GetSubstitutes).Methods("GET")
 
 	// Supplier routes
-	api.HandleFunc("/suppliers/companies", h.GetSupplierCompanies).Methods("GET")
-	api.HandleFunc("/suppliers", h.GetSuppliers).Methods("GET")
-	api.HandleFunc("/suppliers", h.AddSupplier).Methods("POST")
-	api.HandleFunc("/suppliers/{id}", h.UpdateSupplier).Methods("PUT")
-	api.HandleFunc("/suppliers/{id}", h.DeleteSupplier).Methods("DELETE")
+	secured.HandleFunc("/suppliers/companies", h.GetSupplierCompanies).Methods("GET")
+	secured.HandleFunc("/suppliers", h.GetSuppliers).Methods("GET")
+	secured.HandleFunc("/suppliers", middleware.RequireRole(h.AddSupplier, stockStaff...)).Methods("POST")
+	secured.HandleFunc("/suppliers/{id}", middleware.RequireRole(h.GetSupplier, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/suppliers/{id}", middleware.RequireRole(h.UpdateSupplier, stockStaff...)).Methods("PUT")
+	secured.HandleFunc("/suppliers/{id}", middleware.RequireRole(h.DeleteSupplier, ownerOnly...)).Methods("DELETE")
+	secured.HandleFunc("/suppliers/{id}/return-credits", middleware.RequireRole(h.GetSupplierReturnCredits, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/suppliers/{id}/payments", middleware.RequireRole(h.CreateSupplierPayment, managers...)).Methods("POST")
+	secured.HandleFunc("/suppliers/{id}/ledger", middleware.RequireRole(h.GetSupplierLedger, managers...)).Methods("GET")
 
 	// Customer routes
-	api.HandleFunc("/customers", h.GetCustomers).Methods("GET")
-	api.HandleFunc("/customers", h.CreateCustomer).Methods("POST")
-	api.HandleFunc("/customers/{id}", h.UpdateCustomer).Methods("PUT")
-	api.HandleFunc("/customers/{id}", h.DeleteCustomer).Methods("DELETE")
+	secured.HandleFunc("/customers/receivables", middleware.RequireRole(h.GetReceivables, managers...)).Methods("GET")
+	secured.HandleFunc("/customers", middleware.RequireRole(h.GetCustomers, counterStaff...)).Methods("GET")
+	secured.HandleFunc("/customers", middleware.RequireRole(h.CreateCustomer, counterStaff...)).Methods("POST")
+	secured.HandleFunc("/customers/{id}", middleware.RequireRole(h.GetCustomer, counterStaff...)).Methods("GET")
+	secured.HandleFunc("/customers/{id}", middleware.RequireRole(h.UpdateCustomer, counterStaff...)).Methods("PUT")
+	secured.HandleFunc("/customers/{id}", middleware.RequireRole(h.DeleteCustomer, managers...)).Methods("DELETE")
+	secured.HandleFunc("/customers/{id}/payments", middleware.RequireRole(h.CreateCustomerPayment, counterStaff...)).Methods("POST")
+	secured.HandleFunc("/customers/{id}/ledger", middleware.RequireRole(h.GetCustomerLedger, counterStaff...)).Methods("GET")
+
+	// Sales routes
+	secured.HandleFunc("/sales", middleware.RequireRole(h.CreateSale, counterStaff...)).Methods("POST")
+	secured.HandleFunc("/sales/{id}", middleware.RequireRole(h.GetSale, counterStaff...)).Methods("GET")
+	secured.HandleFunc("/sales/{id}/returns", middleware.RequireRole(h.CreateCustomerReturn, counterStaff...)).Methods("POST")
+	secured.HandleFunc("/sales/{id}/returns", middleware.RequireRole(h.GetCustomerReturns, counterStaff...)).Methods("GET")
+	secured.HandleFunc("/invoices", middleware.RequireRole(h.GetInvoices, counterStaff...)).Methods("GET")
+
+	// Prescription routes
+	secured.HandleFunc("/prescriptions", middleware.RequireRole(h.GetPrescriptions, counterStaff...)).Methods("GET")
+	secured.HandleFunc("/prescriptions", middleware.RequireRole(h.CreatePrescription, counterStaff...)).Methods("POST")
+	secured.HandleFunc("/prescriptions/{id}", middleware.RequireRole(h.GetPrescription, counterStaff...)).Methods("GET")
+
+	// Purchase (goods receipt) routes
+	secured.HandleFunc("/purchases", middleware.RequireRole(h.GetPurchases, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/purchases", middleware.RequireRole(h.CreatePurchase, stockStaff...)).Methods("POST")
+	secured.HandleFunc("/purchases/{id}", middleware.RequireRole(h.GetPurchase, stockStaff...)).Methods("GET")
+
+	// Company return (return to supplier) routes
+	secured.HandleFunc("/company-returns", middleware.RequireRole(h.GetCompanyReturns, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/company-returns", middleware.RequireRole(h.CreateCompanyReturn, stockStaff...)).Methods("POST")
+	secured.HandleFunc("/company-returns/{id}", middleware.RequireRole(h.GetCompanyReturn, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/company-returns/{id}/settle", middleware.RequireRole(h.SettleCompanyReturn, managers...)).Methods("POST")
+
+	// Batch recall routes
+	secured.HandleFunc("/recalls", middleware.RequireRole(h.GetRecalls, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/recalls", middleware.RequireRole(h.CreateRecall, stockStaff...)).Methods("POST")
+	secured.HandleFunc("/recalls/{id}", middleware.RequireRole(h.GetRecall, stockStaff...)).Methods("GET")
+
+	// Dashboard
+	secured.HandleFunc("/dashboard", h.GetDashboard).Methods("GET")
+
+	// Report routes
+	secured.HandleFunc("/reports/expiry", middleware.RequireRole(h.GetExpiryReport, stockStaff...)).Methods("GET")
+	secured.HandleFunc("/reports/profit-loss", middleware.RequireRole(h.GetProfitLossReport, managers...)).Methods("GET")
+	secured.HandleFunc("/reports/controlled-register", middleware.RequireRole(h.GetControlledRegister, managers...)).Methods("GET")
+
+	// Audit log
+	secured.HandleFunc("/audit", middleware.RequireRole(h.GetAuditLog, managers...)).Methods("GET")
 
 	// Vendor routes
-	api.HandleFunc("/vendors", h.GetVendors).Methods("GET")
+	secured.HandleFunc("/vendors", h.GetVendors).Methods("GET")
 
 	// Get port from environment
 	port := os.Getenv("PORT")
@@ -79,3 +199,32 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// bootstrapAdmin creates the first owner account from ADMIN_USERNAME and
+// ADMIN_PASSWORD when the users table is empty
+func bootstrapAdmin(db *sql.DB, cfg config.AuthConfig) error {
+	count, err := database.CountUsers(db)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if cfg.AdminPassword == "" {
+		log.Println("No users exist; set ADMIN_PASSWORD to create the first owner account")
+		return nil
+	}
+
+	hash, err := auth.HashPassword(cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+	if _, err := database.CreateUser(db, models.Actor{}, models.CreateUserRequest{
+		Username: cfg.AdminUsername,
+		Role:     models.RoleOwner,
+	}, hash); err != nil {
+		return err
+	}
+	log.Printf("Created owner account %q", cfg.AdminUsername)
+	return nil
+}
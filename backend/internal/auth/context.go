@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+
+	"pharmacy-backend/internal/models"
+)
+
+type (
+	actorKey     struct{}
+	requestIDKey struct{}
+)
+
+// WithActor stores the authenticated user on a request context
+func WithActor(ctx context.Context, actor models.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// WithRequestID stores the request ID on a request context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFrom returns the request ID, or "" if none was assigned
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ActorFrom returns the authenticated user and request ID, or a zero Actor
+// for anonymous requests
+func ActorFrom(ctx context.Context) models.Actor {
+	actor, _ := ctx.Value(actorKey{}).(models.Actor)
+	actor.RequestID = RequestIDFrom(ctx)
+	return actor
+}
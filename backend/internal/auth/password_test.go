@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11 and the widely published PBKDF2-HMAC-SHA256 vectors
+	tests := []struct {
+		password, salt string
+		iterations     int
+		keyLen         int
+		want           string
+	}{
+		{"password", "salt", 1, 32, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"password", "salt", 1, 16, "120fb6cffcf8b32c43e7225256c4f837"},
+	}
+	for _, tt := range tests {
+		got := hex.EncodeToString(pbkdf2SHA256([]byte(tt.password), []byte(tt.salt), tt.iterations, tt.keyLen))
+		if got != tt.want {
+			t.Errorf("pbkdf2SHA256(%q, %q, %d, %d) = %s, want %s", tt.password, tt.salt, tt.iterations, tt.keyLen, got, tt.want)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if !strings.HasPrefix(hash, fmt.Sprintf("%s$%d$", hashScheme, hashIterations)) {
+		t.Errorf("HashPassword() = %q, want the %s scheme with %d iterations", hash, hashScheme, hashIterations)
+	}
+
+	again, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if again == hash {
+		t.Error("HashPassword() returned the same hash twice; salt is not random")
+	}
+
+	for _, tt := range []struct {
+		password string
+		want     bool
+	}{
+		{"correct horse", true},
+		{"correct horse ", false},
+		{"Correct horse", false},
+		{"", false},
+	} {
+		ok, err := CheckPassword(hash, tt.password)
+		if err != nil {
+			t.Fatalf("CheckPassword(%q): %v", tt.password, err)
+		}
+		if ok != tt.want {
+			t.Errorf("CheckPassword(%q) = %v, want %v", tt.password, ok, tt.want)
+		}
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	b64 := base64.RawStdEncoding.EncodeToString
+	key := pbkdf2SHA256([]byte("secret"), []byte("salt"), 3, keyLength)
+	valid := fmt.Sprintf("%s$3$%s$%s", hashScheme, b64([]byte("salt")), b64(key))
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  error
+	}{
+		{"match", valid, "secret", true, nil},
+		{"mismatch", valid, "Secret", false, nil},
+		{"other scheme", strings.Replace(valid, hashScheme, "bcrypt", 1), "secret", false, ErrInvalidHash},
+		{"missing part", fmt.Sprintf("%s$3$%s", hashScheme, b64([]byte("salt"))), "secret", false, ErrInvalidHash},
+		{"zero iterations", strings.Replace(valid, "$3$", "$0$", 1), "secret", false, ErrInvalidHash},
+		{"iterations not a number", strings.Replace(valid, "$3$", "$x$", 1), "secret", false, ErrInvalidHash},
+		{"bad salt", fmt.Sprintf("%s$3$!!$%s", hashScheme, b64(key)), "secret", false, ErrInvalidHash},
+		{"empty key", fmt.Sprintf("%s$3$%s$", hashScheme, b64([]byte("salt"))), "secret", false, ErrInvalidHash},
+		{"empty", "", "secret", false, ErrInvalidHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := CheckPassword(tt.hash, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CheckPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if ok != tt.want {
+				t.Errorf("CheckPassword() = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+func TestAccessToken(t *testing.T) {
+	tokens := NewTokens("test-secret", 15*time.Minute, time.Hour)
+	token, expiresAt, err := tokens.IssueAccessToken(7, "rina", models.RolePharmacist)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	if d := time.Until(expiresAt); d <= 14*time.Minute || d > 15*time.Minute {
+		t.Errorf("token expires in %v, want 15m", d)
+	}
+
+	claims, err := tokens.VerifyAccessToken(token)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	want := models.Actor{UserID: 7, Username: "rina", Role: models.RolePharmacist}
+	if got := claims.Actor(); got != want {
+		t.Errorf("claims.Actor() = %+v, want %+v", got, want)
+	}
+	if claims.ExpiresAt != expiresAt.Unix() {
+		t.Errorf("claims.ExpiresAt = %d, want %d", claims.ExpiresAt, expiresAt.Unix())
+	}
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	tokens := NewTokens("test-secret", 15*time.Minute, time.Hour)
+	token, _, err := tokens.IssueAccessToken(7, "rina", models.RoleCashier)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	parts := strings.Split(token, ".")
+
+	expired, _, err := NewTokens("test-secret", -time.Minute, time.Hour).IssueAccessToken(7, "rina", models.RoleCashier)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	otherSecret, _, err := NewTokens("other-secret", 15*time.Minute, time.Hour).IssueAccessToken(7, "rina", models.RoleCashier)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+
+	// A cashier promoting themselves to owner in the payload
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	promoted := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(payload), `"cashier"`, `"owner"`, 1)))
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", token, nil},
+		{"expired", expired, ErrExpiredToken},
+		{"signed with another secret", otherSecret, ErrInvalidToken},
+		{"payload changed", parts[0] + "." + promoted + "." + parts[2], ErrInvalidToken},
+		{"signature removed", parts[0] + "." + parts[1] + ".", ErrInvalidToken},
+		{"alg none", unsigned + "." + parts[1] + ".", ErrInvalidToken},
+		{"two parts", parts[0] + "." + parts[1], ErrInvalidToken},
+		{"empty", "", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokens.VerifyAccessToken(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	token, hash, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken: %v", err)
+	}
+	if hash != HashRefreshToken(token) {
+		t.Errorf("NewRefreshToken() hash = %s, want HashRefreshToken(token) = %s", hash, HashRefreshToken(token))
+	}
+	if strings.Contains(hash, token) || len(hash) != 64 {
+		t.Errorf("HashRefreshToken() = %q, want a 64-character SHA-256 hex digest", hash)
+	}
+
+	other, _, err := NewRefreshToken()
+	if err != nil {
+		t.Fatalf("NewRefreshToken: %v", err)
+	}
+	if other == token {
+		t.Error("NewRefreshToken() returned the same token twice")
+	}
+}
@@ -2,12 +2,15 @@ package config
 
 import (
 	"os"
+	"time"
 )
 
 // Config holds the application configuration
 type Config struct {
 	Database DatabaseConfig
 	Server   ServerConfig
+	Auth     AuthConfig
+	Catalog  CatalogConfig
 }
 
 // DatabaseConfig holds database configuration
@@ -24,6 +27,21 @@ type ServerConfig struct {
 	Port string
 }
 
+// AuthConfig holds token signing and first-user bootstrap settings
+type AuthConfig struct {
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	AdminUsername   string
+	AdminPassword   string
+}
+
+// CatalogConfig holds product numbering settings
+type CatalogConfig struct {
+	BarcodePrefix      string // GS1 prefix for generated EAN-13 barcodes
+	ProductCodePattern string // e.g. "{category}-{generic}-{seq}"
+}
+
 // Load loads configuration from environment variables
 func Load() *Config {
 	return &Config{
@@ -37,6 +55,17 @@ func Load() *Config {
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 		},
+		Auth: AuthConfig{
+			JWTSecret:       getEnv("JWT_SECRET", ""),
+			AccessTokenTTL:  getDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getDuration("REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			AdminUsername:   getEnv("ADMIN_USERNAME", "admin"),
+			AdminPassword:   getEnv("ADMIN_PASSWORD", ""),
+		},
+		Catalog: CatalogConfig{
+			BarcodePrefix:      getEnv("BARCODE_PREFIX", "200"),
+			ProductCodePattern: getEnv("PRODUCT_CODE_PATTERN", "{category}-{generic}-{seq}"),
+		},
 	}
 }
 
@@ -47,3 +76,11 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getDuration parses a duration such as "15m" from an environment variable
+func getDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// AUDIT LOG
+// Mutations of products, suppliers, customers and users write one
+// audit_log row per changed field, inside the same transaction.
+// =====================================================
+
+// Audited entities and actions
+const (
+	auditProduct  = "product"
+	auditSupplier = "supplier"
+	auditCustomer = "customer"
+	auditUser     = "user"
+
+	auditCreate = "create"
+	auditUpdate = "update"
+	auditDelete = "delete"
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// auditField is one named value of an entity snapshot. A nil value is NULL.
+type auditField struct {
+	name  string
+	value *string
+}
+
+// auditValue converts a nullable text column to an audit value
+func auditValue(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+// writeAuditDiff records every field whose value differs between two snapshots
+// of the same entity (taken in the same field order). A nil before snapshot
+// records a create.
+func writeAuditDiff(ex execer, actor models.Actor, entity string, entityID int, before, after []auditField) error {
+	action := auditUpdate
+	if before == nil {
+		action = auditCreate
+	}
+	for i, field := range after {
+		var old *string
+		if i < len(before) {
+			old = before[i].value
+		}
+		if sameAuditValue(old, field.value) {
+			continue
+		}
+		if err := writeAudit(ex, actor, entity, entityID, action, field.name, old, field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAudit appends one audit_log row
+func writeAudit(ex execer, actor models.Actor, entity string, entityID int, action, field string, oldValue, newValue *string) error {
+	_, err := ex.Exec(`
+		INSERT INTO audit_log (entity, entity_id, action, field, old_value, new_value, user_id, username, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, entity, entityID, action, nullString(field), oldValue, newValue,
+		nullInt(actor.UserID), nullString(actor.Username), nullString(actor.RequestID))
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// snapshot reads the named columns of one entity row as text, in order
+func snapshot(q rowQueryer, entity string, names []string, query string, args ...interface{}) ([]auditField, error) {
+	values := make([]sql.NullString, len(names))
+	dest := make([]interface{}, len(names))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	err := q.QueryRow(query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s not found", entity)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s for audit: %w", entity, err)
+	}
+
+	fields := make([]auditField, len(names))
+	for i, name := range names {
+		fields[i] = auditField{name: name, value: auditValue(values[i])}
+	}
+	return fields, nil
+}
+
+var productAuditFields = []string{
+	"name", "description", "strength", "manufacture", "barcode", "productCode",
+	"price", "mrp", "discount", "vat", "buyingPrice", "inStock", "stockAlert",
+	"genericName", "rackNo", "type", "category", "supplier",
+	"packSize.strip", "packPrice.strip", "packSize.box", "packPrice.box",
+	"packBarcode.strip", "packBarcode.box", "packaging", "ingredients", "requiresPrescription", "schedule",
+}
+
+// productAuditSnapshot reads the audited fields of a product, using the same
+// names as the product API
+func productAuditSnapshot(q rowQueryer, id int) ([]auditField, error) {
+	return snapshot(q, auditProduct, productAuditFields, `
+		SELECT p.product_name, p.product_description, p.strength, p.manufacture, p.barcode, p.product_code,
+		       p.unit_price::text, p.unit_mrp::text, p.discount_percent::text, p.vat_percent::text,
+		       p.unit_cost_price::text, p.available_stock::text, p.stock_alert::text,
+		       g.generic_name, r.rack_name, pt.type_name, c.category_name,
+		       (SELECT s.name FROM product_supplier ps JOIN supplier s ON ps.supplier_id = s.id
+		        WHERE ps.product_id = p.id AND ps.is_primary ORDER BY ps.id LIMIT 1),
+		       (SELECT units_per_pack::text FROM product_packaging WHERE product_id = p.id AND pack_type = 'strip'),
+		       (SELECT selling_price::text FROM product_packaging WHERE product_id = p.id AND pack_type = 'strip'),
+		       (SELECT units_per_pack::text FROM product_packaging WHERE product_id = p.id AND pack_type = 'box'),
+		       (SELECT selling_price::text FROM product_packaging WHERE product_id = p.id AND pack_type = 'box'),
+		       (SELECT barcode FROM product_packaging WHERE product_id = p.id AND pack_type = 'strip'),
+		       (SELECT barcode FROM product_packaging WHERE product_id = p.id AND pack_type = 'box'),
+		       (SELECT string_agg(pack_type || ' ' || units_per_pack || ' @ ' || selling_price, ', ' ORDER BY units_per_pack, pack_type)
+		        FROM product_packaging WHERE product_id = p.id),
+		       (SELECT string_agg(i.name || COALESCE(' ' || pi.strength::float8 || COALESCE(pi.strength_unit, ''), ''), ' + ' ORDER BY pi.position)
+		        FROM product_ingredient pi JOIN ingredient i ON i.id = pi.ingredient_id WHERE pi.product_id = p.id),
+		       p.requires_prescription::text, p.schedule
+		FROM product p
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		LEFT JOIN rack r ON p.rack_fk_id = r.id
+		LEFT JOIN product_type pt ON p.product_type_fk_id = pt.id
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		WHERE p.id = $1
+	`, id)
+}
+
+var supplierAuditFields = []string{"name", "company", "phone", "email", "address", "status"}
+
+func supplierAuditSnapshot(q rowQueryer, id int) ([]auditField, error) {
+	return snapshot(q, auditSupplier, supplierAuditFields,
+		"SELECT name, company, contact, email, address, status FROM supplier WHERE id = $1", id)
+}
+
+var customerAuditFields = []string{"name", "phone", "email", "address", "creditLimit"}
+
+func customerAuditSnapshot(q rowQueryer, id int) ([]auditField, error) {
+	return snapshot(q, auditCustomer, customerAuditFields,
+		"SELECT name, phone, email, address, credit_limit::text FROM customer WHERE id = $1", id)
+}
+
+var userAuditFields = []string{"username", "fullName", "role", "active"}
+
+func userAuditSnapshot(q rowQueryer, id int) ([]auditField, error) {
+	return snapshot(q, auditUser, userAuditFields,
+		"SELECT username, full_name, role, active::text FROM app_user WHERE id = $1", id)
+}
+
+func sameAuditValue(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// GetAuditLog lists audit entries, newest first. entity, entityID and userID
+// filter the results when set.
+func GetAuditLog(db *sql.DB, entity string, entityID, userID, page, limit int) ([]models.AuditEntryDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if entity != "" {
+		args = append(args, entity)
+		whereClause += fmt.Sprintf(" AND entity = $%d", len(args))
+	}
+	if entityID > 0 {
+		args = append(args, entityID)
+		whereClause += fmt.Sprintf(" AND entity_id = $%d", len(args))
+	}
+	if userID > 0 {
+		args = append(args, userID)
+		whereClause += fmt.Sprintf(" AND user_id = $%d", len(args))
+	}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM audit_log"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := `
+		SELECT id, entity, entity_id, action, COALESCE(field, ''), old_value, new_value,
+		       user_id, COALESCE(username, ''), COALESCE(request_id, ''), created_at
+		FROM audit_log` + whereClause +
+		fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntryDTO{}
+	for rows.Next() {
+		var e models.AuditEntryDTO
+		var oldValue, newValue sql.NullString
+		var uid sql.NullInt64
+		var createdAt time.Time
+		if err := rows.Scan(&e.ID, &e.Entity, &e.EntityID, &e.Action, &e.Field, &oldValue, &newValue,
+			&uid, &e.Username, &e.RequestID, &createdAt); err != nil {
+			return nil, models.Pagination{}, err
+		}
+		if oldValue.Valid {
+			e.OldValue = &oldValue.String
+		}
+		if newValue.Valid {
+			e.NewValue = &newValue.String
+		}
+		if uid.Valid {
+			v := int(uid.Int64)
+			e.UserID = &v
+		}
+		e.CreatedAt = createdAt.Format(time.RFC3339)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (totalItems + limit - 1) / limit
+	}
+
+	return entries, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
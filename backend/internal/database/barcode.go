@@ -0,0 +1,223 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// BARCODES
+// A product's own barcode sells one unit; strip and box packaging can carry
+// their own codes. Generated codes are EAN-13: the GS1 prefix, a number from
+// product_barcode_seq, and a check digit.
+// =====================================================
+
+// barcodePrefix defaults to 200, the start of the GS1 range reserved for
+// in-store codes, so generated codes never clash with manufacturer codes
+var barcodePrefix = "200"
+
+// SetBarcodePrefix sets the GS1 prefix used for generated barcodes
+func SetBarcodePrefix(prefix string) error {
+	if len(prefix) < 2 || len(prefix) > 9 || !isDigits(prefix) {
+		return fmt.Errorf("barcode prefix must be 2 to 9 digits, got %q", prefix)
+	}
+	barcodePrefix = prefix
+	return nil
+}
+
+// validBarcode reports whether code is an EAN-8, UPC-A, EAN-13 or GTIN-14
+// with a correct check digit
+func validBarcode(code string) bool {
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return false
+	}
+	if !isDigits(code) {
+		return false
+	}
+	return gtinCheckDigit(code[:len(code)-1]) == code[len(code)-1]
+}
+
+// gtinCheckDigit computes the GS1 mod-10 check digit for the given digits
+func gtinCheckDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Weights alternate 3, 1, 3, ... starting next to the check digit
+		if (len(digits)-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// generateBarcode returns the next unused EAN-13 code for this shop
+func generateBarcode(tx *sql.Tx) (string, error) {
+	width := 12 - len(barcodePrefix)
+	limit := int64(1)
+	for i := 0; i < width; i++ {
+		limit *= 10
+	}
+
+	// A manufacturer code inside our prefix could already hold a number; skip it
+	for attempt := 0; attempt < 10; attempt++ {
+		var seq int64
+		if err := tx.QueryRow("SELECT nextval('product_barcode_seq')").Scan(&seq); err != nil {
+			return "", fmt.Errorf("failed to allocate barcode: %w", err)
+		}
+		if seq >= limit {
+			return "", fmt.Errorf("barcode prefix %s has no numbers left", barcodePrefix)
+		}
+
+		body := fmt.Sprintf("%s%0*d", barcodePrefix, width, seq)
+		code := body + string(gtinCheckDigit(body))
+
+		ownerID, _, err := barcodeOwner(tx, code)
+		if err != nil {
+			return "", err
+		}
+		if ownerID == 0 {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("failed to allocate an unused barcode")
+}
+
+// barcodeOwner finds the product and pack type a barcode belongs to.
+// Unit barcodes of deleted products are free again; pack barcodes stay
+// taken, as idx_product_packaging_barcode is unique across all products.
+// ownerID is 0 when the code is unused.
+func barcodeOwner(q rowQueryer, code string) (ownerID int, packType models.PackType, err error) {
+	err = q.QueryRow(`
+		SELECT id, 'unit' FROM product WHERE barcode = $1 AND deleted = 0
+		UNION ALL
+		SELECT product_id, pack_type::text FROM product_packaging WHERE barcode = $1
+		LIMIT 1
+	`, code).Scan(&ownerID, &packType)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to look up barcode: %w", err)
+	}
+	return ownerID, packType, nil
+}
+
+// checkBarcode validates a manufacturer barcode and makes sure no other
+// product or pack uses it
+func checkBarcode(tx *sql.Tx, productID int, packType models.PackType, code string) error {
+	if !validBarcode(code) {
+		return fmt.Errorf("%w: barcode %q is not a valid EAN/UPC code", ErrInvalidInput, code)
+	}
+	ownerID, ownerPack, err := barcodeOwner(tx, code)
+	if err != nil {
+		return err
+	}
+	if ownerID != 0 && (ownerID != productID || ownerPack != packType) {
+		return fmt.Errorf("%w: barcode %s is already used by prod_%03d (%s)", ErrInvalidInput, code, ownerID, ownerPack)
+	}
+	return nil
+}
+
+// setProductBarcode assigns the unit barcode of a product, generating one
+// when code is empty
+func setProductBarcode(tx *sql.Tx, productID int, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		generated, err := generateBarcode(tx)
+		if err != nil {
+			return err
+		}
+		code = generated
+	} else if err := checkBarcode(tx, productID, models.PackTypeUnit, code); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("UPDATE product SET barcode = $1, updated_at = NOW() WHERE id = $2", code, productID)
+	if err != nil {
+		return fmt.Errorf("failed to set barcode: %w", err)
+	}
+	return nil
+}
+
+// setPackBarcode assigns or, when code is empty, clears the barcode of a
+// product's strip or box packaging
+func setPackBarcode(tx *sql.Tx, productID int, packType models.PackType, code string) error {
+	code = strings.TrimSpace(code)
+	if code != "" {
+		if err := checkBarcode(tx, productID, packType, code); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec(`
+		UPDATE product_packaging SET barcode = $1, updated_at = NOW()
+		WHERE product_id = $2 AND pack_type = $3
+	`, nullString(code), productID, packType)
+	if err != nil {
+		return fmt.Errorf("failed to set %s barcode: %w", packType, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 && code != "" {
+		return fmt.Errorf("%w: product has no %s packaging", ErrInvalidInput, packType)
+	}
+	return nil
+}
+
+// setPackBarcodes applies the strip and box barcodes of a request
+func setPackBarcodes(tx *sql.Tx, productID int, codes models.PackBarcode) error {
+	if err := setPackBarcode(tx, productID, models.PackTypeStrip, codes.Strip); err != nil {
+		return err
+	}
+	return setPackBarcode(tx, productID, models.PackTypeBox, codes.Box)
+}
+
+// LookupBarcode resolves a scanned code to its product and pack type
+func LookupBarcode(db *sql.DB, code string) (*models.BarcodeLookupResponse, error) {
+	code = strings.TrimSpace(code)
+	ownerID, packType, err := barcodeOwner(db, code)
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == 0 {
+		return nil, fmt.Errorf("barcode not found")
+	}
+
+	product, err := GetProductResponseByID(db, fmt.Sprintf("%d", ownerID))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BarcodeLookupResponse{
+		Barcode:      code,
+		PackType:     packType,
+		UnitsPerPack: 1,
+		Price:        product.Price,
+		MRP:          product.MRP,
+		Product:      *product,
+	}
+	if packType != models.PackTypeUnit {
+		err = db.QueryRow(`
+			SELECT units_per_pack, selling_price, mrp
+			FROM product_packaging WHERE product_id = $1 AND pack_type = $2
+		`, ownerID, packType).Scan(&result.UnitsPerPack, &result.Price, &result.MRP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get packaging: %w", err)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import "testing"
+
+func TestGTINCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"400638133393", '1'},  // EAN-13 4006381333931
+		{"890123456789", '0'},  // EAN-13 8901234567890
+		{"200000000001", '5'},  // first generated in-store code
+		{"03600029145", '2'},   // UPC-A 036000291452
+		{"9638507", '4'},       // EAN-8 96385074
+		{"1001234567890", '2'}, // GTIN-14 10012345678902
+		{"000000000000", '0'},
+	}
+	for _, tt := range tests {
+		if got := gtinCheckDigit(tt.digits); got != tt.want {
+			t.Errorf("gtinCheckDigit(%q) = %c, want %c", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestValidBarcode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"4006381333931", true},
+		{"036000291452", true},
+		{"96385074", true},
+		{"10012345678902", true},
+		{"4006381333932", false}, // wrong check digit
+		{"400638133393", false},  // EAN-13 without its check digit
+		{"40063813339311", false},
+		{"4006-81333931", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validBarcode(tt.code); got != tt.want {
+			t.Errorf("validBarcode(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestSetBarcodePrefix(t *testing.T) {
+	saved := barcodePrefix
+	defer func() { barcodePrefix = saved }()
+
+	tests := []struct {
+		prefix  string
+		wantErr bool
+	}{
+		{"200", false},
+		{"29", false},
+		{"123456789", false},
+		{"2", true},
+		{"1234567890", true},
+		{"2A0", true},
+		{"", true},
+	}
+	for _, tt := range tests {
+		err := SetBarcodePrefix(tt.prefix)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SetBarcodePrefix(%q) error = %v, wantErr %v", tt.prefix, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// BATCH ALLOCATION (FEFO)
+// =====================================================
+
+// batchAllocation is a quantity drawn from one product_batch row
+type batchAllocation struct {
+	batchID   int
+	batchNo   string
+	quantity  int
+	costPrice float64
+}
+
+// allocateBatchesFEFO draws units from a product's unexpired batches, earliest
+// expiry first, and decrements their quantities. Batches without an expiry date
+// are used last. Expired and quarantined batches are never drawn from.
+func allocateBatchesFEFO(tx *sql.Tx, productID, units int) ([]batchAllocation, error) {
+	return drawFromBatches(tx, productID, units, false)
+}
+
+// drawFromBatches removes units from a product's active batches in expiry
+// order. Sales must not touch expired batches; stock write-downs may include
+// them. Quarantined batches are outside available stock and always skipped.
+func drawFromBatches(tx *sql.Tx, productID, units int, includeExpired bool) ([]batchAllocation, error) {
+	expiryFilter := " AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)"
+	if includeExpired {
+		expiryFilter = ""
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, batch_id, quantity, COALESCE(cost_price, 0)
+		FROM product_batch
+		WHERE product_id = $1 AND quantity > 0 AND status = 'active'`+expiryFilter+`
+		ORDER BY expiry_date ASC NULLS LAST, id ASC
+		FOR UPDATE
+	`, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+
+	var allocations []batchAllocation
+	remaining := units
+	for rows.Next() && remaining > 0 {
+		var a batchAllocation
+		var available int
+		if err := rows.Scan(&a.batchID, &a.batchNo, &available, &a.costPrice); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+		a.quantity = min(available, remaining)
+		remaining -= a.quantity
+		allocations = append(allocations, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batches: %w", err)
+	}
+
+	if remaining > 0 {
+		kind := "unexpired units"
+		if includeExpired {
+			kind = "units"
+		}
+		return nil, fmt.Errorf("%w: product %d has only %d %s in batches, %d requested",
+			ErrInsufficientStock, productID, units-remaining, kind, units)
+	}
+
+	for _, a := range allocations {
+		_, err := tx.Exec(
+			"UPDATE product_batch SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2",
+			a.quantity, a.batchID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update batch %s: %w", a.batchNo, err)
+		}
+	}
+
+	return allocations, nil
+}
+
+// recordInvoiceItemBatches stores which batches an invoice line consumed
+func recordInvoiceItemBatches(tx *sql.Tx, invoiceItemID int, allocations []batchAllocation) error {
+	for _, a := range allocations {
+		_, err := tx.Exec(`
+			INSERT INTO invoice_item_batches (invoice_item_id, batch_fk_id, quantity, cost_price)
+			VALUES ($1, $2, $3, $4)
+		`, invoiceItemID, a.batchID, a.quantity, a.costPrice)
+		if err != nil {
+			return fmt.Errorf("failed to record batch allocation: %w", err)
+		}
+	}
+	return nil
+}
+
+// addToStockBatch adds units to a product's batch that holds stock not
+// received through a purchase (e.g. OPENING, ADJUSTMENT), creating it if
+// needed, and returns the batch's ID
+func addToStockBatch(tx *sql.Tx, productID int, batchNo string, units int) (int, error) {
+	var batchID int
+	err := tx.QueryRow(`
+		SELECT id FROM product_batch
+		WHERE product_id = $1 AND batch_id = $2 AND supplier_id IS NULL AND status = 'active'
+		ORDER BY id LIMIT 1
+		FOR UPDATE
+	`, productID, batchNo).Scan(&batchID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			INSERT INTO product_batch (product_id, batch_id, quantity, purchase_date, cost_price)
+			SELECT id, $2, $3, CURRENT_DATE, COALESCE(unit_cost_price, 0) FROM product WHERE id = $1
+			RETURNING id
+		`, productID, batchNo, units).Scan(&batchID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create %s batch: %w", batchNo, err)
+		}
+		return batchID, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get %s batch: %w", batchNo, err)
+	}
+
+	_, err = tx.Exec("UPDATE product_batch SET quantity = quantity + $1, updated_at = NOW() WHERE id = $2", units, batchID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update %s batch: %w", batchNo, err)
+	}
+	return batchID, nil
+}
+
+// QuarantineBatches takes batches out of sellable stock. Their units stay on
+// the batch (for a write-off or a return to the supplier) but leave
+// available_stock through a 'quarantine' ledger movement.
+func QuarantineBatches(db *sql.DB, actor models.Actor, req models.QuarantineBatchesRequest) ([]models.QuarantinedBatchDTO, error) {
+	if len(req.BatchIDs) == 0 && !req.Expired {
+		return nil, fmt.Errorf("%w: batchIds or expired is required", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(req.BatchIDs))
+	for i, id := range req.BatchIDs {
+		ids[i] = int64(id)
+	}
+
+	// Work through the batches product by product, so products are always
+	// locked in the same order
+	rows, err := tx.Query(`
+		SELECT id, product_id FROM product_batch
+		WHERE id = ANY($1)
+		   OR ($2 AND status = 'active' AND quantity > 0 AND expiry_date < CURRENT_DATE)
+		ORDER BY product_id, id
+	`, pq.Array(ids), req.Expired)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+	type target struct{ batchID, productID int }
+	var targets []target
+	found := map[int]bool{}
+	for rows.Next() {
+		var t target
+		if err := rows.Scan(&t.batchID, &t.productID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+		targets = append(targets, t)
+		found[t.batchID] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batches: %w", err)
+	}
+	for _, id := range req.BatchIDs {
+		if !found[id] {
+			return nil, fmt.Errorf("batch %d not found", id)
+		}
+	}
+
+	quarantined := []models.QuarantinedBatchDTO{}
+	for _, t := range targets {
+		_, err := tx.Exec("SELECT id FROM product WHERE id = $1 FOR UPDATE", t.productID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock product: %w", err)
+		}
+
+		var batchNo string
+		var quantity int
+		var status models.BatchStatus
+		var expiry sql.NullTime
+		err = tx.QueryRow(`
+			SELECT batch_id, COALESCE(quantity, 0), status, expiry_date
+			FROM product_batch WHERE id = $1
+			FOR UPDATE
+		`, t.batchID).Scan(&batchNo, &quantity, &status, &expiry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock batch: %w", err)
+		}
+		if status != models.BatchStatusActive {
+			return nil, fmt.Errorf("%w: batch %s is already %s", ErrInvalidInput, batchNo, status)
+		}
+
+		if quantity > 0 {
+			entry, err := recordStockMovement(tx, stockMovement{
+				productID:     t.productID,
+				change:        -quantity,
+				changeType:    models.StockChangeQuarantine,
+				referenceType: refBatch,
+				referenceID:   t.batchID,
+				note:          req.Note,
+				userID:        actor.UserID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			allocs := []batchAllocation{{batchID: t.batchID, batchNo: batchNo, quantity: quantity}}
+			if err := recordRegisterBatches(tx, entry.registerID, allocs); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE product_batch
+			SET status = 'quarantined', quarantined_at = NOW(), quarantined_by = $1,
+			    quarantine_note = $2, updated_at = NOW()
+			WHERE id = $3
+		`, nullInt(actor.UserID), nullString(req.Note), t.batchID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to quarantine batch %s: %w", batchNo, err)
+		}
+
+		q := models.QuarantinedBatchDTO{
+			BatchID:   t.batchID,
+			BatchNo:   batchNo,
+			ProductID: fmt.Sprintf("prod_%03d", t.productID),
+			Quantity:  quantity,
+		}
+		if expiry.Valid {
+			q.ExpiryDate = expiry.Time.Format("2006-01-02")
+		}
+		quarantined = append(quarantined, q)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return quarantined, nil
+}
+
+// WriteOffBatch disposes of units from a quarantined or recalled batch. The
+// units left available stock when the batch was blocked, so only the batch
+// and the write-off record change.
+func WriteOffBatch(db *sql.DB, actor models.Actor, batchID int, req models.WriteOffBatchRequest) (*models.WrittenOffBatchDTO, error) {
+	if req.Reason == "" {
+		req.Reason = models.AdjustmentExpired
+	}
+	switch req.Reason {
+	case models.AdjustmentExpired, models.AdjustmentDamaged, models.AdjustmentLost:
+	default:
+		return nil, fmt.Errorf("%w: write-off reason must be expired, damaged or lost", ErrInvalidInput)
+	}
+	if req.Quantity < 0 {
+		return nil, fmt.Errorf("%w: quantity cannot be negative", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var productID int
+	err = tx.QueryRow("SELECT product_id FROM product_batch WHERE id = $1", batchID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("batch not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	if _, err := tx.Exec("SELECT id FROM product WHERE id = $1 FOR UPDATE", productID); err != nil {
+		return nil, fmt.Errorf("failed to lock product: %w", err)
+	}
+
+	var a batchAllocation
+	var available int
+	var status models.BatchStatus
+	err = tx.QueryRow(`
+		SELECT batch_id, COALESCE(quantity, 0), COALESCE(cost_price, 0), status
+		FROM product_batch WHERE id = $1
+		FOR UPDATE
+	`, batchID).Scan(&a.batchNo, &available, &a.costPrice, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock batch: %w", err)
+	}
+	if status == models.BatchStatusActive {
+		return nil, fmt.Errorf("%w: batch %s is not quarantined; use a stock adjustment for active stock", ErrInvalidInput, a.batchNo)
+	}
+
+	a.batchID = batchID
+	a.quantity = req.Quantity
+	if a.quantity == 0 {
+		a.quantity = available
+	}
+	if a.quantity == 0 || a.quantity > available {
+		return nil, fmt.Errorf("%w: batch %s has %d units, %d requested", ErrInsufficientStock, a.batchNo, available, a.quantity)
+	}
+
+	_, err = tx.Exec("UPDATE product_batch SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2", a.quantity, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update batch: %w", err)
+	}
+	if err := recordWriteOffs(tx, productID, []batchAllocation{a}, req.Reason, req.Note, actor.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.WrittenOffBatchDTO{
+		BatchID:   batchID,
+		BatchNo:   a.batchNo,
+		ProductID: fmt.Sprintf("prod_%03d", productID),
+		Quantity:  a.quantity,
+		Remaining: available - a.quantity,
+		Reason:    req.Reason,
+		Amount:    roundMoney(float64(a.quantity) * a.costPrice),
+	}, nil
+}
+
+// GetProductBatches lists a product's batches in FEFO order
+func GetProductBatches(db *sql.DB, idStr string) ([]models.BatchDTO, error) {
+	id, err := parseProductID(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+
+	rows, err := db.Query(`
+		SELECT b.id, b.batch_id, COALESCE(b.quantity, 0), b.expiry_date, b.purchase_date,
+		       b.supplier_id, COALESCE(s.name, ''), COALESCE(b.cost_price, 0), b.status
+		FROM product_batch b
+		LEFT JOIN supplier s ON b.supplier_id = s.id
+		WHERE b.product_id = $1
+		ORDER BY b.expiry_date ASC NULLS LAST, b.id ASC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+	defer rows.Close()
+
+	today := time.Now().Truncate(24 * time.Hour)
+	batches := []models.BatchDTO{}
+	for rows.Next() {
+		var b models.BatchDTO
+		var expiry, purchase sql.NullTime
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&b.ID, &b.BatchNo, &b.Quantity, &expiry, &purchase,
+			&supplierID, &b.Supplier, &b.CostPrice, &b.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+		b.ProductID = fmt.Sprintf("prod_%03d", id)
+		if expiry.Valid {
+			b.ExpiryDate = expiry.Time.Format("2006-01-02")
+			b.Expired = expiry.Time.Before(today)
+		}
+		if purchase.Valid {
+			b.PurchaseDate = purchase.Time.Format("2006-01-02")
+		}
+		if supplierID.Valid {
+			sid := int(supplierID.Int64)
+			b.SupplierID = &sid
+		}
+		batches = append(batches, b)
+	}
+
+	return batches, rows.Err()
+}
+
+// GetBatchSales lists every invoice line (and customer) that received units from a batch
+func GetBatchSales(db *sql.DB, batchID int) ([]models.BatchSaleDTO, error) {
+	rows, err := db.Query(`
+		SELECT i.id, ii.id, i.created_at, i.customer_id_fk,
+		       COALESCE(c.name, ''), COALESCE(c.phone, ''), iib.quantity
+		FROM invoice_item_batches iib
+		JOIN invoice_items ii ON iib.invoice_item_id = ii.id
+		JOIN invoice i ON ii.invoice_id = i.id
+		LEFT JOIN customer c ON i.customer_id_fk = c.id
+		WHERE iib.batch_fk_id = $1 AND i.deleted = 0
+		ORDER BY i.created_at DESC, ii.id DESC
+	`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch sales: %w", err)
+	}
+	defer rows.Close()
+
+	sales := []models.BatchSaleDTO{}
+	for rows.Next() {
+		var s models.BatchSaleDTO
+		var soldAt time.Time
+		var customerID sql.NullInt64
+		if err := rows.Scan(&s.InvoiceID, &s.InvoiceItemID, &soldAt, &customerID,
+			&s.CustomerName, &s.CustomerPhone, &s.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to scan batch sale: %w", err)
+		}
+		s.InvoiceNo = formatInvoiceNo(s.InvoiceID)
+		s.SoldAt = soldAt.Format(time.RFC3339)
+		if customerID.Valid {
+			cid := int(customerID.Int64)
+			s.CustomerID = &cid
+		}
+		sales = append(sales, s)
+	}
+
+	return sales, rows.Err()
+}
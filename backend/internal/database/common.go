@@ -1,5 +1,19 @@
 package database
 
+import (
+	"errors"
+	"math"
+)
+
+// Sentinel errors returned by the write paths so handlers can map them to
+// the right HTTP status. Wrap them with fmt.Errorf("%w: ...") to add detail.
+var (
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrCreditLimit       = errors.New("credit limit exceeded")
+	ErrPrescription      = errors.New("prescription required")
+)
+
 // calculateStockStatus determines the stock status string
 func calculateStockStatus(quantity int) string {
 	if quantity <= 0 {
@@ -9,3 +23,14 @@ func calculateStockStatus(quantity int) string {
 	}
 	return "Normal"
 }
+
+// roundMoney rounds a currency amount to 2 decimal places
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// roundCost rounds a per-unit cost to 4 decimal places, enough to keep
+// pack costs that don't divide evenly into units ("10 for 12.50") exact
+func roundCost(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,387 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// COMPANY RETURNS (RETURN TO SUPPLIER)
+// =====================================================
+
+const refCompanyReturn = "company_return"
+
+// CreateCompanyReturn sends batches back to their supplier. The returned units
+// leave stock through a 'company_return' ledger movement and the expected
+// credit is valued at each batch's cost price.
+func CreateCompanyReturn(db *sql.DB, actor models.Actor, supplierID int, req models.CreateCompanyReturnRequest) (*models.CompanyReturnDTO, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	returnID, err := createCompanyReturn(tx, actor, supplierID, 0, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetCompanyReturnByID(db, returnID)
+}
+
+// createCompanyReturn writes a company return inside tx and returns its ID.
+// recallID links returns raised by a batch recall.
+func createCompanyReturn(tx *sql.Tx, actor models.Actor, supplierID, recallID int, req models.CreateCompanyReturnRequest) (int, error) {
+	if len(req.Items) == 0 {
+		return 0, fmt.Errorf("%w: no batches to return", ErrInvalidInput)
+	}
+	if !req.Reason.Valid() {
+		return 0, fmt.Errorf("%w: unknown return reason %q", ErrInvalidInput, req.Reason)
+	}
+
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM supplier WHERE id = $1 AND deleted = 0)", supplierID).Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get supplier: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("supplier not found")
+	}
+
+	var returnID int
+	err = tx.QueryRow(`
+		INSERT INTO company_return (supplier_id, reason, status, notes, user_id, recall_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, supplierID, req.Reason, models.CompanyReturnStatusOpen, nullString(req.Notes), nullInt(actor.UserID),
+		nullInt(recallID)).Scan(&returnID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert company return: %w", err)
+	}
+
+	var expected float64
+	for _, item := range req.Items {
+		if item.Quantity < 0 {
+			return 0, fmt.Errorf("%w: return quantity must not be negative", ErrInvalidInput)
+		}
+
+		var productID int
+		var batchSupplier sql.NullInt64
+		err := tx.QueryRow("SELECT product_id, supplier_id FROM product_batch WHERE id = $1", item.BatchID).
+			Scan(&productID, &batchSupplier)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("batch %d not found", item.BatchID)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to get batch: %w", err)
+		}
+		if !batchSupplier.Valid || int(batchSupplier.Int64) != supplierID {
+			return 0, fmt.Errorf("%w: batch %d was not received from this supplier", ErrInvalidInput, item.BatchID)
+		}
+
+		// Lock the product before the batch, as every stock movement does
+		_, err = tx.Exec("SELECT id FROM product WHERE id = $1 FOR UPDATE", productID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock product: %w", err)
+		}
+
+		var batchNo string
+		var available int
+		var costPrice float64
+		var status models.BatchStatus
+		err = tx.QueryRow(`
+			SELECT batch_id, COALESCE(quantity, 0), COALESCE(cost_price, 0), status
+			FROM product_batch WHERE id = $1
+			FOR UPDATE
+		`, item.BatchID).Scan(&batchNo, &available, &costPrice, &status)
+		if err != nil {
+			return 0, fmt.Errorf("failed to lock batch: %w", err)
+		}
+
+		units := item.Quantity
+		if units == 0 {
+			units = available
+		}
+		if units == 0 {
+			return 0, fmt.Errorf("%w: batch %s is empty", ErrInvalidInput, batchNo)
+		}
+		if units > available {
+			return 0, fmt.Errorf("%w: batch %s has %d units, %d requested", ErrInsufficientStock, batchNo, available, units)
+		}
+
+		// Quarantined and recalled units left available stock when the batch was blocked
+		if status == models.BatchStatusActive {
+			entry, err := recordStockMovement(tx, stockMovement{
+				productID:     productID,
+				change:        -units,
+				changeType:    models.StockChangeCompanyReturn,
+				referenceType: refCompanyReturn,
+				referenceID:   returnID,
+				note:          string(req.Reason),
+				userID:        actor.UserID,
+			})
+			if err != nil {
+				return 0, err
+			}
+			returned := []batchAllocation{{batchID: item.BatchID, batchNo: batchNo, quantity: units, costPrice: costPrice}}
+			if err := recordRegisterBatches(tx, entry.registerID, returned); err != nil {
+				return 0, err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE product_batch SET quantity = quantity - $1, updated_at = NOW() WHERE id = $2", units, item.BatchID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update batch %s: %w", batchNo, err)
+		}
+
+		amount := roundMoney(float64(units) * costPrice)
+		_, err = tx.Exec(`
+			INSERT INTO company_return_items (return_id, batch_fk_id, product_id, quantity, cost_price, amount)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, returnID, item.BatchID, productID, units, costPrice, amount)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert company return item: %w", err)
+		}
+		expected += amount
+	}
+
+	_, err = tx.Exec("UPDATE company_return SET expected_credit = $1 WHERE id = $2", roundMoney(expected), returnID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update company return: %w", err)
+	}
+
+	return returnID, nil
+}
+
+// SettleCompanyReturn records credit received from the supplier for a return.
+// The credit is applied to the supplier's open purchases like a payment.
+func SettleCompanyReturn(db *sql.DB, actor models.Actor, id int, req models.SettleCompanyReturnRequest) (*models.CompanyReturnDTO, error) {
+	if req.Amount < 0 {
+		return nil, fmt.Errorf("%w: amount must not be negative", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var supplierID int
+	var expected, settled float64
+	var status models.CompanyReturnStatus
+	err = tx.QueryRow(`
+		SELECT supplier_id, COALESCE(expected_credit, 0), COALESCE(settled_amount, 0), status
+		FROM company_return WHERE id = $1
+		FOR UPDATE
+	`, id).Scan(&supplierID, &expected, &settled, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("company return not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company return: %w", err)
+	}
+	if status == models.CompanyReturnStatusSettled {
+		return nil, fmt.Errorf("%w: company return is already settled", ErrInvalidInput)
+	}
+	if req.Amount == 0 && !req.Close {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrInvalidInput)
+	}
+
+	settled = roundMoney(settled + req.Amount)
+	status = models.CompanyReturnStatusPartial
+	if req.Close || settled >= expected {
+		status = models.CompanyReturnStatusSettled
+	}
+
+	_, err = tx.Exec(`
+		UPDATE company_return
+		SET settled_amount = $1, status = $2,
+		    settled_at = CASE WHEN $2 = 'settled' THEN NOW() ELSE settled_at END,
+		    updated_at = NOW()
+		WHERE id = $3
+	`, settled, string(status), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update company return: %w", err)
+	}
+
+	if req.Amount > 0 {
+		_, err := applySupplierPayment(tx, actor, supplierPayment{
+			supplierID:      supplierID,
+			amount:          roundMoney(req.Amount),
+			notes:           fmt.Sprintf("Credit for CR-%06d", id),
+			companyReturnID: id,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetCompanyReturnByID(db, id)
+}
+
+const companyReturnColumns = `
+	SELECT cr.id, cr.supplier_id, COALESCE(s.name, ''), cr.reason, cr.status,
+	       COALESCE(cr.expected_credit, 0), COALESCE(cr.settled_amount, 0), cr.settled_at,
+	       COALESCE(cr.notes, ''), cr.created_at
+	FROM company_return cr
+	LEFT JOIN supplier s ON cr.supplier_id = s.id`
+
+// GetCompanyReturns lists company returns, newest first, optionally filtered by supplier and status
+func GetCompanyReturns(db *sql.DB, page, limit, supplierID int, status string) ([]models.CompanyReturnDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if supplierID > 0 {
+		args = append(args, supplierID)
+		whereClause += fmt.Sprintf(" AND cr.supplier_id = $%d", len(args))
+	}
+	if status != "" {
+		args = append(args, status)
+		whereClause += fmt.Sprintf(" AND cr.status = $%d", len(args))
+	}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM company_return cr"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := companyReturnColumns + whereClause +
+		fmt.Sprintf(" ORDER BY cr.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	returns := []models.CompanyReturnDTO{}
+	for rows.Next() {
+		r, err := scanCompanyReturn(rows)
+		if err != nil {
+			return nil, models.Pagination{}, err
+		}
+		returns = append(returns, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (totalItems + limit - 1) / limit
+	}
+
+	return returns, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
+
+// GetCompanyReturnByID retrieves a company return with its batches
+func GetCompanyReturnByID(db *sql.DB, id int) (*models.CompanyReturnDTO, error) {
+	r, err := scanCompanyReturn(db.QueryRow(companyReturnColumns+" WHERE cr.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("company return not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get company return: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT cri.id, cri.batch_fk_id, b.batch_id, b.expiry_date, cri.product_id, p.product_name,
+		       cri.quantity, COALESCE(cri.cost_price, 0), COALESCE(cri.amount, 0)
+		FROM company_return_items cri
+		JOIN product_batch b ON cri.batch_fk_id = b.id
+		JOIN product p ON cri.product_id = p.id
+		WHERE cri.return_id = $1
+		ORDER BY cri.id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query company return items: %w", err)
+	}
+	defer rows.Close()
+
+	r.Items = []models.CompanyReturnItemDTO{}
+	for rows.Next() {
+		var item models.CompanyReturnItemDTO
+		var expiry sql.NullTime
+		var productID int
+		if err := rows.Scan(&item.ID, &item.BatchID, &item.BatchNo, &expiry, &productID, &item.ProductName,
+			&item.Quantity, &item.CostPrice, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan company return item: %w", err)
+		}
+		item.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if expiry.Valid {
+			item.ExpiryDate = expiry.Time.Format("2006-01-02")
+		}
+		r.Items = append(r.Items, item)
+	}
+
+	return r, rows.Err()
+}
+
+// GetSupplierReturnCredits lists a supplier's returns whose credit is not yet settled
+func GetSupplierReturnCredits(db *sql.DB, supplierID int) (*models.SupplierReturnCreditsDTO, error) {
+	rows, err := db.Query(companyReturnColumns+" WHERE cr.supplier_id = $1 AND cr.status <> $2 ORDER BY cr.id",
+		supplierID, models.CompanyReturnStatusSettled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query return credits: %w", err)
+	}
+	defer rows.Close()
+
+	credits := &models.SupplierReturnCreditsDTO{
+		SupplierID: fmt.Sprintf("SUP-%03d", supplierID),
+		Returns:    []models.CompanyReturnDTO{},
+	}
+	for rows.Next() {
+		r, err := scanCompanyReturn(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan company return: %w", err)
+		}
+		credits.OpenCredit += r.OpenCredit
+		credits.Returns = append(credits.Returns, *r)
+	}
+	credits.OpenCredit = roundMoney(credits.OpenCredit)
+
+	return credits, rows.Err()
+}
+
+func scanCompanyReturn(row rowScanner) (*models.CompanyReturnDTO, error) {
+	var r models.CompanyReturnDTO
+	var supplierID sql.NullInt64
+	var settledAt sql.NullTime
+	var createdAt time.Time
+	if err := row.Scan(&r.ID, &supplierID, &r.SupplierName, &r.Reason, &r.Status,
+		&r.ExpectedCredit, &r.SettledAmount, &settledAt, &r.Notes, &createdAt); err != nil {
+		return nil, err
+	}
+	r.ReturnNo = fmt.Sprintf("CR-%06d", r.ID)
+	if supplierID.Valid {
+		r.SupplierID = fmt.Sprintf("SUP-%03d", supplierID.Int64)
+	}
+	if r.Status != models.CompanyReturnStatusSettled {
+		r.OpenCredit = roundMoney(math.Max(r.ExpectedCredit-r.SettledAmount, 0))
+	}
+	if settledAt.Valid {
+		r.SettledAt = settledAt.Time.Format(time.RFC3339)
+	}
+	r.CreatedAt = createdAt.Format(time.RFC3339)
+	return &r, nil
+}
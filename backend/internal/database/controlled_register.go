@@ -0,0 +1,332 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// CONTROLLED SUBSTANCE REGISTER
+// recordStockMovement adds an entry for every ledger row of a scheduled
+// product, with the running balance; callers that know the batches attach
+// them with recordRegisterBatches. Both tables are append-only.
+// =====================================================
+
+// registerCounterpartySQL names the customer or supplier behind a movement
+// from its ledger reference ($8 type, $9 ID)
+const registerCounterpartySQL = `
+	CASE $8::varchar
+		WHEN 'invoice' THEN (SELECT COALESCE(c.name, 'Walk-in customer') FROM invoice i
+		                     LEFT JOIN customer c ON i.customer_id_fk = c.id WHERE i.id = $9)
+		WHEN 'customer_return' THEN (SELECT COALESCE(c.name, 'Walk-in customer') FROM customer_return cr
+		                             LEFT JOIN customer c ON cr.customer_id_fk = c.id WHERE cr.id = $9)
+		WHEN 'purchase' THEN (SELECT s.name FROM product_stock_purchase psp
+		                      JOIN supplier s ON psp.supplier_id = s.id WHERE psp.id = $9)
+		WHEN 'company_return' THEN (SELECT s.name FROM company_return cr
+		                            JOIN supplier s ON cr.supplier_id = s.id WHERE cr.id = $9)
+	END`
+
+// recordRegisterEntry writes the register entry for a ledger row. balance is
+// the product's stock after the movement.
+func recordRegisterEntry(tx *sql.Tx, m stockMovement, historyID int, schedule models.DrugSchedule, balance int) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		INSERT INTO controlled_register (
+			product_id, stock_history_id, schedule, entry_type, quantity_in, quantity_out, balance,
+			reference_type, reference_id, counterparty, prescriber_name, prescriber_reg_no, note, user_id
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, `+registerCounterpartySQL+`,
+		        (SELECT rx.prescriber_name FROM prescription_item pi
+		         JOIN prescription rx ON pi.prescription_id = rx.id WHERE pi.id = $10),
+		        (SELECT rx.prescriber_reg_no FROM prescription_item pi
+		         JOIN prescription rx ON pi.prescription_id = rx.id WHERE pi.id = $10),
+		        $11, $12)
+		RETURNING id
+	`, m.productID, historyID, schedule, string(m.changeType), max(m.change, 0), max(-m.change, 0), balance,
+		nullString(m.referenceType), nullInt(m.referenceID), nullInt(m.prescriptionItemID),
+		nullString(m.note), nullInt(m.userID),
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write controlled register entry: %w", err)
+	}
+	return id, nil
+}
+
+// recordRegisterBatches stores the batches behind a register entry. It does
+// nothing for products that are not scheduled (registerID 0).
+func recordRegisterBatches(tx *sql.Tx, registerID int, allocations []batchAllocation) error {
+	if registerID == 0 {
+		return nil
+	}
+	for _, a := range allocations {
+		_, err := tx.Exec(`
+			INSERT INTO controlled_register_batch (register_id, batch_fk_id, batch_no, quantity)
+			VALUES ($1, $2, $3, $4)
+		`, registerID, nullInt(a.batchID), a.batchNo, a.quantity)
+		if err != nil {
+			return fmt.Errorf("failed to record controlled register batch: %w", err)
+		}
+	}
+	return nil
+}
+
+// setProductSchedule changes a product's schedule. Every change is written
+// to the register with the stock on hand, batch by batch: a product that
+// becomes scheduled opens its register, one that is rescheduled gets a
+// 'reclassified' entry, and one that is no longer scheduled a 'closing' entry.
+func setProductSchedule(tx *sql.Tx, productID int, schedule models.DrugSchedule, userID int) error {
+	if !schedule.Valid() {
+		return fmt.Errorf("%w: unknown schedule %q", ErrInvalidInput, schedule)
+	}
+
+	var current models.DrugSchedule
+	var stock int
+	err := tx.QueryRow(
+		"SELECT COALESCE(schedule, ''), COALESCE(available_stock, 0) FROM product WHERE id = $1 AND deleted = 0 FOR UPDATE",
+		productID,
+	).Scan(&current, &stock)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get product schedule: %w", err)
+	}
+	if current == schedule {
+		return nil
+	}
+
+	_, err = tx.Exec("UPDATE product SET schedule = $1, updated_at = NOW() WHERE id = $2", nullString(string(schedule)), productID)
+	if err != nil {
+		return fmt.Errorf("failed to update product schedule: %w", err)
+	}
+
+	entryType, entrySchedule := "opening", schedule
+	note := "Register opened with the stock on hand"
+	switch {
+	case schedule == models.DrugScheduleNone:
+		entryType, entrySchedule = "closing", current
+		note = fmt.Sprintf("Register closed: no longer %s", current)
+	case current != models.DrugScheduleNone:
+		entryType = "reclassified"
+		note = fmt.Sprintf("Reclassified from %s to %s", current, schedule)
+	}
+
+	var registerID int
+	err = tx.QueryRow(`
+		INSERT INTO controlled_register (product_id, schedule, entry_type, balance, note, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, productID, entrySchedule, entryType, stock, note, nullInt(userID)).Scan(&registerID)
+	if err != nil {
+		return fmt.Errorf("failed to write controlled register %s entry: %w", entryType, err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, batch_id, quantity FROM product_batch
+		WHERE product_id = $1 AND status = 'active' AND quantity > 0
+		ORDER BY expiry_date ASC NULLS LAST, id ASC
+	`, productID)
+	if err != nil {
+		return fmt.Errorf("failed to query batches: %w", err)
+	}
+	var batches []batchAllocation
+	for rows.Next() {
+		var a batchAllocation
+		if err := rows.Scan(&a.batchID, &a.batchNo, &a.quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan batch: %w", err)
+		}
+		batches = append(batches, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return recordRegisterBatches(tx, registerID, batches)
+}
+
+// registerReference formats the document number of a ledger reference
+func registerReference(referenceType string, id int) string {
+	switch referenceType {
+	case refInvoice:
+		return formatInvoiceNo(id)
+	case refPurchase:
+		return formatPurchaseNo(id)
+	case refCustomerReturn:
+		return formatCreditNoteNo(id)
+	case refCompanyReturn:
+		return fmt.Sprintf("CR-%06d", id)
+	case refRecall:
+		return formatRecallNo(id)
+	}
+	return ""
+}
+
+// GetControlledRegister returns a scheduled product's register for a period
+// (from and to as YYYY-MM-DD, inclusive) and reconciles it with the stock
+// ledger: both must open and close on the same balance, and every ledger row
+// in the period must have its register entry.
+func GetControlledRegister(db *sql.DB, idStr, from, to string) (*models.ControlledRegisterDTO, error) {
+	id, err := parseProductID(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+
+	r := &models.ControlledRegisterDTO{
+		ProductID: fmt.Sprintf("prod_%03d", id),
+		From:      from,
+		To:        to,
+		Entries:   []models.ControlledRegisterEntryDTO{},
+	}
+	var registered bool
+	err = db.QueryRow(`
+		SELECT product_name, COALESCE(strength, ''), COALESCE(schedule, ''),
+		       EXISTS(SELECT 1 FROM controlled_register WHERE product_id = $1)
+		FROM product WHERE id = $1
+	`, id).Scan(&r.Name, &r.Strength, &r.Schedule, &registered)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+	if !registered {
+		return nil, fmt.Errorf("%w: %s is not a scheduled product", ErrInvalidInput, r.Name)
+	}
+
+	// Balances brought forward
+	err = db.QueryRow(`
+		SELECT
+			COALESCE((SELECT balance FROM controlled_register
+			          WHERE product_id = $1 AND created_at < $2::date ORDER BY id DESC LIMIT 1), 0),
+			COALESCE((SELECT new_quantity FROM product_stock_history
+			          WHERE product_id_fk = $1 AND created_at < $2::date ORDER BY id DESC LIMIT 1), 0),
+			COALESCE((SELECT new_quantity FROM product_stock_history
+			          WHERE product_id_fk = $1 AND created_at < $3::date + 1 ORDER BY id DESC LIMIT 1), 0),
+			(SELECT COUNT(*) FROM product_stock_history
+			 WHERE product_id_fk = $1 AND created_at >= $2::date AND created_at < $3::date + 1)
+	`, id, from, to).Scan(&r.OpeningBalance, &r.Reconciliation.LedgerOpening,
+		&r.Reconciliation.LedgerClosing, &r.Reconciliation.LedgerMovements)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get register balances: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, created_at, entry_type, COALESCE(reference_type, ''), reference_id,
+		       COALESCE(counterparty, ''), COALESCE(prescriber_name, ''), COALESCE(prescriber_reg_no, ''),
+		       quantity_in, quantity_out, balance, COALESCE(note, ''), user_id, stock_history_id
+		FROM controlled_register
+		WHERE product_id = $1 AND created_at >= $2::date AND created_at < $3::date + 1
+		ORDER BY id
+	`, id, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query controlled register: %w", err)
+	}
+	defer rows.Close()
+
+	r.ClosingBalance = r.OpeningBalance
+	byID := make(map[int]int)
+	var ids []int64
+	for rows.Next() {
+		var e models.ControlledRegisterEntryDTO
+		var createdAt time.Time
+		var referenceID, userID, historyID sql.NullInt64
+		if err := rows.Scan(&e.ID, &createdAt, &e.EntryType, &e.ReferenceType, &referenceID,
+			&e.Counterparty, &e.PrescriberName, &e.PrescriberRegNo,
+			&e.QuantityIn, &e.QuantityOut, &e.Balance, &e.Note, &userID, &historyID); err != nil {
+			return nil, fmt.Errorf("failed to scan controlled register entry: %w", err)
+		}
+		e.Date = createdAt.Format(time.RFC3339)
+		if referenceID.Valid {
+			v := int(referenceID.Int64)
+			e.ReferenceID = &v
+			e.Reference = registerReference(e.ReferenceType, v)
+		}
+		if userID.Valid {
+			v := int(userID.Int64)
+			e.UserID = &v
+		}
+		if historyID.Valid {
+			v := int(historyID.Int64)
+			e.StockHistoryID = &v
+			r.Reconciliation.RegisterMovements++
+		}
+		e.Batches = []models.ControlledRegisterBatchDTO{}
+
+		r.TotalIn += e.QuantityIn
+		r.TotalOut += e.QuantityOut
+		r.ClosingBalance = e.Balance
+
+		byID[e.ID] = len(r.Entries)
+		ids = append(ids, int64(e.ID))
+		r.Entries = append(r.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(ids) > 0 {
+		batchRows, err := db.Query(`
+			SELECT register_id, batch_fk_id, COALESCE(batch_no, ''), quantity
+			FROM controlled_register_batch
+			WHERE register_id = ANY($1)
+			ORDER BY id
+		`, pq.Array(ids))
+		if err != nil {
+			return nil, fmt.Errorf("failed to query controlled register batches: %w", err)
+		}
+		defer batchRows.Close()
+		for batchRows.Next() {
+			var registerID int
+			var batchID sql.NullInt64
+			var b models.ControlledRegisterBatchDTO
+			if err := batchRows.Scan(&registerID, &batchID, &b.BatchNo, &b.Quantity); err != nil {
+				return nil, fmt.Errorf("failed to scan controlled register batch: %w", err)
+			}
+			if batchID.Valid {
+				v := int(batchID.Int64)
+				b.BatchID = &v
+			}
+			e := &r.Entries[byID[registerID]]
+			e.Batches = append(e.Batches, b)
+		}
+		if err := batchRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Ledger rows the register is missing, e.g. from before the product was scheduled
+	unregistered, err := db.Query(`
+		SELECT h.id FROM product_stock_history h
+		WHERE h.product_id_fk = $1 AND h.created_at >= $2::date AND h.created_at < $3::date + 1
+		  AND NOT EXISTS (SELECT 1 FROM controlled_register cr WHERE cr.stock_history_id = h.id)
+		ORDER BY h.id
+	`, id, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unregistered movements: %w", err)
+	}
+	defer unregistered.Close()
+	r.Reconciliation.UnregisteredIDs = []int{}
+	for unregistered.Next() {
+		var historyID int
+		if err := unregistered.Scan(&historyID); err != nil {
+			return nil, fmt.Errorf("failed to scan stock history: %w", err)
+		}
+		r.Reconciliation.UnregisteredIDs = append(r.Reconciliation.UnregisteredIDs, historyID)
+	}
+	if err := unregistered.Err(); err != nil {
+		return nil, err
+	}
+
+	r.Reconciliation.Reconciled = len(r.Reconciliation.UnregisteredIDs) == 0 &&
+		r.OpeningBalance == r.Reconciliation.LedgerOpening &&
+		r.ClosingBalance == r.Reconciliation.LedgerClosing
+
+	return r, nil
+}
@@ -7,12 +7,17 @@ import (
 	"time"
 )
 
+// customerBalanceSQL sums what a customer row still owes on its invoices
+const customerBalanceSQL = `(SELECT COALESCE(SUM(i.balance), 0) FROM invoice i
+		WHERE i.customer_id_fk = customer.id AND i.deleted = 0 AND i.balance > 0)`
+
 // GetCustomers retrieves customers with pagination and search
 func GetCustomers(db *sql.DB, page, limit int, search string) ([]models.CustomerDTO, models.Pagination, error) {
 	offset := (page - 1) * limit
 
 	query := `
-		SELECT id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''), created_at
+		SELECT id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''),
+		       COALESCE(credit_limit, 0), ` + customerBalanceSQL + `, created_at
 		FROM customer
 		WHERE deleted = 0
 	`
@@ -48,7 +53,7 @@ func GetCustomers(db *sql.DB, page, limit int, search string) ([]models.Customer
 	for rows.Next() {
 		var c models.CustomerDTO
 		var createdAt time.Time
-		err := rows.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Address, &createdAt)
+		err := rows.Scan(&c.ID, &c.Name, &c.Phone, &c.Email, &c.Address, &c.CreditLimit, &c.Balance, &createdAt)
 		if err != nil {
 			return nil, models.Pagination{}, err
 		}
@@ -76,10 +81,20 @@ func GetCustomers(db *sql.DB, page, limit int, search string) ([]models.Customer
 }
 
 // CreateCustomer creates a new customer
-func CreateCustomer(db *sql.DB, req models.CreateCustomerRequest) (*models.CustomerDTO, error) {
+func CreateCustomer(db *sql.DB, actor models.Actor, req models.CreateCustomerRequest) (*models.CustomerDTO, error) {
+	if req.CreditLimit < 0 {
+		return nil, fmt.Errorf("%w: credit limit cannot be negative", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO customer (name, phone, email, address)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO customer (name, phone, email, address, credit_limit)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, created_at
 	`
 
@@ -89,31 +104,52 @@ func CreateCustomer(db *sql.DB, req models.CreateCustomerRequest) (*models.Custo
 	// Handle empty strings as NULL or empty? Requirement says nothing, but usually fine.
 	// However, phone/email are often nullable.
 
-	err := db.QueryRow(query, req.Name, req.Phone, req.Email, req.Address).Scan(&id, &createdAt)
+	err = tx.QueryRow(query, req.Name, req.Phone, req.Email, req.Address, roundMoney(req.CreditLimit)).Scan(&id, &createdAt)
 	if err != nil {
 		return nil, err
 	}
 
+	after, err := customerAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditCustomer, id, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return &models.CustomerDTO{
 		ID:          id,
 		Name:        req.Name,
 		Phone:       req.Phone,
 		Email:       req.Email,
 		Address:     req.Address,
+		CreditLimit: roundMoney(req.CreditLimit),
 		MemberSince: createdAt.Format("2006-01-02"),
 	}, nil
 }
 
 // UpdateCustomer updates an existing customer
-func UpdateCustomer(db *sql.DB, id int, req models.UpdateCustomerRequest) (*models.CustomerDTO, error) {
+func UpdateCustomer(db *sql.DB, actor models.Actor, id int, req models.UpdateCustomerRequest) (*models.CustomerDTO, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// First check if exists
 	var current models.CustomerDTO
 	var createdAt time.Time
 
-	err := db.QueryRow(`
-		SELECT id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''), created_at 
-		FROM customer WHERE id = $1 AND deleted = 0
-	`, id).Scan(&current.ID, &current.Name, &current.Phone, &current.Email, &current.Address, &createdAt)
+	err = tx.QueryRow(`
+		SELECT id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''),
+		       COALESCE(credit_limit, 0), `+customerBalanceSQL+`, created_at
+		FROM customer WHERE id = $1 AND deleted = 0 FOR UPDATE
+	`, id).Scan(&current.ID, &current.Name, &current.Phone, &current.Email, &current.Address,
+		&current.CreditLimit, &current.Balance, &createdAt)
 
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("customer not found")
@@ -151,6 +187,15 @@ func UpdateCustomer(db *sql.DB, id int, req models.UpdateCustomerRequest) (*mode
 		current.Address = *req.Address
 		idx++
 	}
+	if req.CreditLimit != nil {
+		if *req.CreditLimit < 0 {
+			return nil, fmt.Errorf("%w: credit limit cannot be negative", ErrInvalidInput)
+		}
+		query += fmt.Sprintf("credit_limit = $%d, ", idx)
+		args = append(args, roundMoney(*req.CreditLimit))
+		current.CreditLimit = roundMoney(*req.CreditLimit)
+		idx++
+	}
 
 	if len(args) == 0 {
 		current.MemberSince = createdAt.Format("2006-01-02")
@@ -162,24 +207,182 @@ func UpdateCustomer(db *sql.DB, id int, req models.UpdateCustomerRequest) (*mode
 	query += fmt.Sprintf(" WHERE id = $%d", idx)
 	args = append(args, id)
 
-	_, err = db.Exec(query, args...)
+	before, err := customerAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
+	after, err := customerAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditCustomer, id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	current.MemberSince = createdAt.Format("2006-01-02")
 	return &current, nil
 }
 
 // DeleteCustomer soft deletes a customer
-func DeleteCustomer(db *sql.DB, id int) error {
-	result, err := db.Exec("UPDATE customer SET deleted = 1, deleted_at = NOW() WHERE id = $1 AND deleted = 0", id)
+func DeleteCustomer(db *sql.DB, actor models.Actor, id int) error {
+	tx, err := db.Begin()
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRow(
+		"UPDATE customer SET deleted = 1, deleted_at = NOW() WHERE id = $1 AND deleted = 0 RETURNING name", id,
+	).Scan(&name)
+	if err == sql.ErrNoRows {
 		return fmt.Errorf("customer not found")
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, actor, auditCustomer, id, auditDelete, "", &name, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// topCustomerProducts is how many medicines the customer profile lists
+const topCustomerProducts = 10
+
+// GetCustomerDetail returns a customer's profile: purchase totals, the
+// medicines they buy most, and one page of their invoices with line items
+func GetCustomerDetail(db *sql.DB, id, page, limit int) (*models.CustomerDetailDTO, models.Pagination, error) {
+	var d models.CustomerDetailDTO
+	var createdAt time.Time
+	err := db.QueryRow(`
+		SELECT id, name, COALESCE(phone, ''), COALESCE(email, ''), COALESCE(address, ''),
+		       COALESCE(credit_limit, 0), `+customerBalanceSQL+`, created_at
+		FROM customer
+		WHERE id = $1 AND deleted = 0
+	`, id).Scan(&d.ID, &d.Name, &d.Phone, &d.Email, &d.Address, &d.CreditLimit, &d.Balance, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, models.Pagination{}, fmt.Errorf("customer not found")
+	}
+	if err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("failed to get customer: %w", err)
+	}
+	d.MemberSince = createdAt.Format("2006-01-02")
+
+	var spent float64
+	var lastVisit sql.NullTime
+	err = db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(total), 0), MAX(created_at),
+		       COALESCE((SELECT SUM(cr.total) FROM customer_return cr
+		                 JOIN invoice ri ON cr.invoice_id = ri.id
+		                 WHERE ri.customer_id_fk = $1 AND ri.deleted = 0), 0)
+		FROM invoice
+		WHERE customer_id_fk = $1 AND deleted = 0
+	`, id).Scan(&d.Stats.VisitCount, &spent, &lastVisit, &d.Stats.Returned)
+	if err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("failed to get customer stats: %w", err)
+	}
+	d.Stats.Returned = roundMoney(d.Stats.Returned)
+	d.Stats.LifetimeSpend = roundMoney(spent - d.Stats.Returned)
+	if lastVisit.Valid {
+		d.Stats.LastVisit = lastVisit.Time.Format(time.RFC3339)
+	}
+
+	d.TopProducts, err = customerTopProducts(db, id)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	rows, err := db.Query(saleColumns+`
+		WHERE i.customer_id_fk = $1 AND i.deleted = 0
+		ORDER BY i.created_at DESC, i.id DESC
+		LIMIT $2 OFFSET $3
+	`, id, limit, (page-1)*limit)
+	if err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("failed to query customer invoices: %w", err)
+	}
+	var sales []*models.SaleDTO
+	for rows.Next() {
+		s, err := scanSale(rows)
+		if err != nil {
+			rows.Close()
+			return nil, models.Pagination{}, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		sales = append(sales, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if err := attachSaleItems(db, sales); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	d.Invoices = make([]models.SaleDTO, len(sales))
+	for i, s := range sales {
+		d.Invoices[i] = *s
+	}
+
+	pagination := models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   (d.Stats.VisitCount + limit - 1) / limit,
+		TotalItems:   d.Stats.VisitCount,
+		ItemsPerPage: limit,
+	}
+	return &d, pagination, nil
+}
+
+// customerTopProducts ranks the medicines a customer has kept (sold less
+// returned units), with the pack and quantity of their latest purchase
+func customerTopProducts(db *sql.DB, customerID int) ([]models.CustomerProductDTO, error) {
+	rows, err := db.Query(`
+		WITH lines AS (
+			SELECT ii.product_id, ii.invoice_id, ii.pack_type, ii.quantity, i.created_at,
+			       COALESCE(ii.units, 0) - COALESCE((SELECT SUM(cri.units) FROM customer_return_items cri
+			                                         WHERE cri.invoice_item_id = ii.id), 0) AS units
+			FROM invoice_items ii
+			JOIN invoice i ON ii.invoice_id = i.id
+			WHERE i.customer_id_fk = $1 AND i.deleted = 0
+		)
+		SELECT l.product_id, p.product_name, COALESCE(p.strength, ''),
+		       SUM(l.units), COUNT(DISTINCT l.invoice_id), MAX(l.created_at),
+		       (ARRAY_AGG(l.pack_type::text ORDER BY l.created_at DESC, l.invoice_id DESC))[1],
+		       (ARRAY_AGG(l.quantity ORDER BY l.created_at DESC, l.invoice_id DESC))[1]
+		FROM lines l
+		JOIN product p ON l.product_id = p.id
+		GROUP BY l.product_id, p.product_name, p.strength
+		HAVING SUM(l.units) > 0
+		ORDER BY SUM(l.units) DESC, MAX(l.created_at) DESC
+		LIMIT $2
+	`, customerID, topCustomerProducts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer products: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.CustomerProductDTO{}
+	for rows.Next() {
+		var cp models.CustomerProductDTO
+		var productID int
+		var lastPurchased time.Time
+		if err := rows.Scan(&productID, &cp.Name, &cp.Strength, &cp.Units, &cp.Invoices,
+			&lastPurchased, &cp.LastPackType, &cp.LastQuantity); err != nil {
+			return nil, fmt.Errorf("failed to scan customer product: %w", err)
+		}
+		cp.ProductID = fmt.Sprintf("prod_%03d", productID)
+		cp.LastPurchasedAt = lastPurchased.Format(time.RFC3339)
+		products = append(products, cp)
+	}
+	return products, rows.Err()
 }
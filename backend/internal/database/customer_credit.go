@@ -0,0 +1,343 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// CUSTOMER CREDIT
+// What a customer owes is the sum of invoice.balance over their live
+// invoices. Payments are applied to open invoices oldest first, and credit
+// notes reduce the balance of the invoice they were raised against.
+// =====================================================
+
+// agingColumns splits open invoice balances by age: 0-30, 31-60, 61-90 and
+// over 90 days. Select it from invoice rows aliased i.
+const agingColumns = `
+	COALESCE(SUM(i.balance) FILTER (WHERE CURRENT_DATE - i.created_at::date <= 30), 0),
+	COALESCE(SUM(i.balance) FILTER (WHERE CURRENT_DATE - i.created_at::date BETWEEN 31 AND 60), 0),
+	COALESCE(SUM(i.balance) FILTER (WHERE CURRENT_DATE - i.created_at::date BETWEEN 61 AND 90), 0),
+	COALESCE(SUM(i.balance) FILTER (WHERE CURRENT_DATE - i.created_at::date > 90), 0)`
+
+func finishAging(a *models.AgingDTO) {
+	a.Current = roundMoney(a.Current)
+	a.Days60 = roundMoney(a.Days60)
+	a.Days90 = roundMoney(a.Days90)
+	a.Over90 = roundMoney(a.Over90)
+	a.Total = roundMoney(a.Current + a.Days60 + a.Days90 + a.Over90)
+}
+
+func formatReceiptNo(id int) string {
+	return fmt.Sprintf("RCT-%06d", id)
+}
+
+// customerOutstanding sums the open balances of a customer's invoices
+func customerOutstanding(q rowQueryer, customerID int) (float64, error) {
+	var outstanding float64
+	err := q.QueryRow(
+		"SELECT COALESCE(SUM(balance), 0) FROM invoice WHERE customer_id_fk = $1 AND deleted = 0 AND balance > 0",
+		customerID,
+	).Scan(&outstanding)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get customer balance: %w", err)
+	}
+	return roundMoney(outstanding), nil
+}
+
+// checkCreditLimit rejects a new balance that would take the customer past
+// their credit limit. The caller holds the customer row lock.
+func checkCreditLimit(tx *sql.Tx, customerID int, limit, newBalance float64) error {
+	outstanding, err := customerOutstanding(tx, customerID)
+	if err != nil {
+		return err
+	}
+	if roundMoney(outstanding+newBalance) > limit {
+		return fmt.Errorf("%w: customer owes %.2f of a %.2f limit, this invoice adds %.2f",
+			ErrCreditLimit, outstanding, limit, newBalance)
+	}
+	return nil
+}
+
+// CreateCustomerPayment records a payment and applies it to the customer's
+// open invoices, oldest first. A payment larger than the outstanding balance
+// is rejected.
+func CreateCustomerPayment(db *sql.DB, actor models.Actor, customerID int, req models.CustomerPaymentRequest) (*models.CustomerPaymentDTO, error) {
+	req.Amount = roundMoney(req.Amount)
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: payment amount must be positive", ErrInvalidInput)
+	}
+	if req.Method == "" {
+		req.Method = models.PaymentCash
+	}
+	if !req.Method.Valid() {
+		return nil, fmt.Errorf("%w: unknown payment method %q", ErrInvalidInput, req.Method)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the customer first, as checkout does, then their open invoices
+	var exists int
+	err = tx.QueryRow("SELECT id FROM customer WHERE id = $1 AND deleted = 0 FOR UPDATE", customerID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("customer not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(balance, 0)
+		FROM invoice
+		WHERE customer_id_fk = $1 AND deleted = 0 AND balance > 0
+		ORDER BY created_at, id
+		FOR UPDATE
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open invoices: %w", err)
+	}
+	type openInvoice struct {
+		id      int
+		balance float64
+	}
+	var open []openInvoice
+	var outstanding float64
+	for rows.Next() {
+		var inv openInvoice
+		if err := rows.Scan(&inv.id, &inv.balance); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		open = append(open, inv)
+		outstanding += inv.balance
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoices: %w", err)
+	}
+
+	outstanding = roundMoney(outstanding)
+	if req.Amount > outstanding {
+		return nil, fmt.Errorf("%w: payment of %.2f exceeds the outstanding balance of %.2f", ErrInvalidInput, req.Amount, outstanding)
+	}
+
+	payment := &models.CustomerPaymentDTO{
+		CustomerID:  customerID,
+		Amount:      req.Amount,
+		Method:      req.Method,
+		Reference:   req.Reference,
+		Notes:       req.Notes,
+		Allocations: []models.PaymentAllocationDTO{},
+	}
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO customer_payment (customer_id, amount, method, reference, notes, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, customerID, req.Amount, req.Method, nullString(req.Reference), nullString(req.Notes),
+		nullInt(actor.UserID)).Scan(&payment.ID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert payment: %w", err)
+	}
+
+	remaining := req.Amount
+	for _, inv := range open {
+		if remaining <= 0 {
+			break
+		}
+		applied := roundMoney(math.Min(remaining, inv.balance))
+		remaining = roundMoney(remaining - applied)
+		newBalance := roundMoney(inv.balance - applied)
+
+		status := models.InvoiceStatusDue
+		if newBalance <= 0 {
+			status = models.InvoiceStatusPaid
+		}
+		_, err := tx.Exec(`
+			UPDATE invoice
+			SET paid_amount = COALESCE(paid_amount, 0) + $1, balance = $2, status = $3, updated_at = NOW()
+			WHERE id = $4
+		`, applied, newBalance, status, inv.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update invoice: %w", err)
+		}
+		_, err = tx.Exec(
+			"INSERT INTO customer_payment_allocation (payment_id, invoice_id, amount) VALUES ($1, $2, $3)",
+			payment.ID, inv.id, applied,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record payment allocation: %w", err)
+		}
+
+		payment.Allocations = append(payment.Allocations, models.PaymentAllocationDTO{
+			InvoiceID: inv.id,
+			InvoiceNo: formatInvoiceNo(inv.id),
+			Amount:    applied,
+			Balance:   newBalance,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	payment.ReceiptNo = formatReceiptNo(payment.ID)
+	payment.CreatedAt = createdAt.Format(time.RFC3339)
+	payment.Balance = roundMoney(outstanding - req.Amount)
+	return payment, nil
+}
+
+// customerAging splits a customer's open balance by invoice age
+func customerAging(db *sql.DB, customerID int) (models.AgingDTO, error) {
+	var a models.AgingDTO
+	err := db.QueryRow(`SELECT `+agingColumns+`
+		FROM invoice i
+		WHERE i.customer_id_fk = $1 AND i.deleted = 0 AND i.balance > 0
+	`, customerID).Scan(&a.Current, &a.Days60, &a.Days90, &a.Over90)
+	if err != nil {
+		return a, fmt.Errorf("failed to get customer aging: %w", err)
+	}
+	finishAging(&a)
+	return a, nil
+}
+
+// GetCustomerLedger returns a customer's statement with a running balance.
+// from and to (YYYY-MM-DD, inclusive) are optional; entries before from are
+// carried in the opening balance.
+func GetCustomerLedger(db *sql.DB, customerID int, from, to string) (*models.CustomerLedgerDTO, error) {
+	ledger := &models.CustomerLedgerDTO{
+		CustomerID: customerID,
+		From:       from,
+		To:         to,
+		Entries:    []models.CustomerLedgerEntryDTO{},
+	}
+	err := db.QueryRow(
+		"SELECT name, COALESCE(credit_limit, 0) FROM customer WHERE id = $1 AND deleted = 0", customerID,
+	).Scan(&ledger.Name, &ledger.CreditLimit)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("customer not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	// What was paid at the counter is the invoice's paid amount less what
+	// later payments added to it
+	rows, err := db.Query(`
+		SELECT at, to_char(at, 'YYYY-MM-DD'), type, ref_id, debit, credit FROM (
+			SELECT i.created_at AS at, 1 AS seq, 'invoice' AS type, i.id AS ref_id,
+			       COALESCE(i.total, 0) AS debit, 0 AS credit
+			FROM invoice i
+			WHERE i.customer_id_fk = $1 AND i.deleted = 0
+			UNION ALL
+			SELECT i.created_at, 2, 'paid_at_sale', i.id, 0,
+			       COALESCE(i.paid_amount, 0) - COALESCE(a.allocated, 0)
+			FROM invoice i
+			LEFT JOIN (
+				SELECT invoice_id, SUM(amount) AS allocated
+				FROM customer_payment_allocation GROUP BY invoice_id
+			) a ON a.invoice_id = i.id
+			WHERE i.customer_id_fk = $1 AND i.deleted = 0
+			  AND COALESCE(i.paid_amount, 0) - COALESCE(a.allocated, 0) > 0
+			UNION ALL
+			SELECT cr.created_at, 3, 'return', cr.id, 0, cr.credited_amount
+			FROM customer_return cr
+			JOIN invoice i ON cr.invoice_id = i.id
+			WHERE i.customer_id_fk = $1 AND i.deleted = 0 AND cr.credited_amount > 0
+			UNION ALL
+			SELECT p.created_at, 4, 'payment', p.id, 0, p.amount
+			FROM customer_payment p
+			WHERE p.customer_id = $1
+		) entries
+		ORDER BY at, seq, ref_id
+	`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer ledger: %w", err)
+	}
+	defer rows.Close()
+
+	var balance float64
+	for rows.Next() {
+		var e models.CustomerLedgerEntryDTO
+		var at time.Time
+		var refID int
+		if err := rows.Scan(&at, &e.Date, &e.Type, &refID, &e.Debit, &e.Credit); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		balance = roundMoney(balance + e.Debit - e.Credit)
+		e.Balance = balance
+
+		if from != "" && e.Date < from {
+			ledger.OpeningBalance = balance
+			continue
+		}
+		if to != "" && e.Date > to {
+			break
+		}
+
+		switch e.Type {
+		case models.LedgerInvoice, models.LedgerPaidAtSale:
+			e.Reference = formatInvoiceNo(refID)
+		case models.LedgerReturn:
+			e.Reference = formatCreditNoteNo(refID)
+		case models.LedgerPayment:
+			e.Reference = formatReceiptNo(refID)
+		}
+		ledger.Entries = append(ledger.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger entries: %w", err)
+	}
+
+	ledger.ClosingBalance = ledger.OpeningBalance
+	if n := len(ledger.Entries); n > 0 {
+		ledger.ClosingBalance = ledger.Entries[n-1].Balance
+	}
+
+	ledger.Aging, err = customerAging(db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
+
+// GetReceivables lists customers with an outstanding balance, largest first,
+// with the balance split by invoice age
+func GetReceivables(db *sql.DB) ([]models.CustomerReceivableDTO, error) {
+	rows, err := db.Query(`
+		SELECT c.id, c.name, COALESCE(c.phone, ''), COALESCE(c.credit_limit, 0),
+		       COUNT(i.id), to_char(MIN(i.created_at), 'YYYY-MM-DD'),` + agingColumns + `
+		FROM invoice i
+		JOIN customer c ON i.customer_id_fk = c.id
+		WHERE i.deleted = 0 AND i.balance > 0
+		GROUP BY c.id, c.name, c.phone, c.credit_limit
+		ORDER BY SUM(i.balance) DESC, c.name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receivables: %w", err)
+	}
+	defer rows.Close()
+
+	receivables := []models.CustomerReceivableDTO{}
+	for rows.Next() {
+		var r models.CustomerReceivableDTO
+		if err := rows.Scan(&r.CustomerID, &r.Name, &r.Phone, &r.CreditLimit, &r.OpenInvoices, &r.OldestInvoiceAt,
+			&r.Aging.Current, &r.Aging.Days60, &r.Aging.Days90, &r.Aging.Over90); err != nil {
+			return nil, fmt.Errorf("failed to scan receivable: %w", err)
+		}
+		finishAging(&r.Aging)
+		receivables = append(receivables, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating receivables: %w", err)
+	}
+	return receivables, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// DASHBOARD
+// Sales and purchase figures cover the last N days up to today and the N days
+// before that; stock and receivable figures are as of now.
+// =====================================================
+
+// dashboardExpiryDays is how far ahead the dashboard looks for expiring batches
+const dashboardExpiryDays = 30
+
+// dashboardTopSellers is how many best sellers the dashboard lists
+const dashboardTopSellers = 5
+
+// dashboardMetric fills in the change from the previous period
+func dashboardMetric(value, previous float64) models.DashboardMetricDTO {
+	m := models.DashboardMetricDTO{Value: roundMoney(value), Previous: roundMoney(previous)}
+	if previous != 0 {
+		change := roundMoney((value - previous) / previous * 100)
+		m.Change = &change
+	}
+	return m
+}
+
+// GetDashboard computes the dashboard figures for a period
+func GetDashboard(db *sql.DB, period models.DashboardPeriod) (*models.DashboardDTO, error) {
+	days := period.Days()
+	if days == 0 {
+		return nil, fmt.Errorf("%w: unknown dashboard period %q", ErrInvalidInput, period)
+	}
+
+	d := &models.DashboardDTO{Period: period, TopSellers: []models.DashboardTopSellerDTO{}}
+
+	// $1 is the period length; the current period starts at cur_from and the
+	// previous one at prev_from, both ending the day before the next starts
+	const bounds = `
+		WITH bounds AS (
+			SELECT CURRENT_DATE - ($1::int - 1) AS cur_from,
+			       CURRENT_DATE + 1 AS cur_to,
+			       CURRENT_DATE - (2 * $1::int - 1) AS prev_from
+		)`
+
+	var sales, prevSales, invoices, prevInvoices float64
+	err := db.QueryRow(bounds+`
+		SELECT to_char(b.cur_from, 'YYYY-MM-DD'), to_char(b.cur_to - 1, 'YYYY-MM-DD'),
+		       to_char(b.prev_from, 'YYYY-MM-DD'), to_char(b.cur_from - 1, 'YYYY-MM-DD'),
+		       COALESCE(SUM(i.total) FILTER (WHERE i.created_at >= b.cur_from), 0),
+		       COUNT(i.id) FILTER (WHERE i.created_at >= b.cur_from),
+		       COALESCE(SUM(i.total) FILTER (WHERE i.created_at < b.cur_from), 0),
+		       COUNT(i.id) FILTER (WHERE i.created_at < b.cur_from)
+		FROM bounds b
+		LEFT JOIN invoice i ON i.deleted = 0 AND i.created_at >= b.prev_from AND i.created_at < b.cur_to
+		GROUP BY b.cur_from, b.cur_to, b.prev_from
+	`, days).Scan(&d.From, &d.To, &d.PreviousFrom, &d.PreviousTo, &sales, &invoices, &prevSales, &prevInvoices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales figures: %w", err)
+	}
+	d.Sales = dashboardMetric(sales, prevSales)
+	d.Invoices = dashboardMetric(invoices, prevInvoices)
+
+	var basket, prevBasket float64
+	if invoices > 0 {
+		basket = sales / invoices
+	}
+	if prevInvoices > 0 {
+		prevBasket = prevSales / prevInvoices
+	}
+	d.AverageBasket = dashboardMetric(basket, prevBasket)
+
+	var spend, prevSpend float64
+	err = db.QueryRow(bounds+`
+		SELECT COALESCE(SUM(p.total) FILTER (WHERE COALESCE(p.received_date, p.created_at::date) >= b.cur_from), 0),
+		       COALESCE(SUM(p.total) FILTER (WHERE COALESCE(p.received_date, p.created_at::date) < b.cur_from), 0)
+		FROM bounds b
+		LEFT JOIN product_stock_purchase p
+		       ON COALESCE(p.received_date, p.created_at::date) >= b.prev_from
+		      AND COALESCE(p.received_date, p.created_at::date) < b.cur_to
+	`, days).Scan(&spend, &prevSpend)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase figures: %w", err)
+	}
+	purchaseSpend := dashboardMetric(spend, prevSpend)
+	d.PurchaseSpend = &purchaseSpend
+
+	err = db.QueryRow(`
+		SELECT COALESCE(SUM(balance), 0), COUNT(*)
+		FROM invoice
+		WHERE deleted = 0 AND balance > 0
+	`).Scan(&d.Receivables.Amount, &d.Receivables.Invoices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receivables: %w", err)
+	}
+	d.Receivables.Amount = roundMoney(d.Receivables.Amount)
+
+	err = db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM product
+			 WHERE deleted = 0 AND COALESCE(available_stock, 0) < COALESCE(stock_alert, 0)),
+			(SELECT COUNT(*) FROM product_batch b
+			 JOIN product p ON b.product_id = p.id
+			 WHERE p.deleted = 0 AND b.status = 'active' AND b.quantity > 0
+			   AND b.expiry_date >= CURRENT_DATE AND b.expiry_date < CURRENT_DATE + $1::int)
+	`, dashboardExpiryDays).Scan(&d.LowStock, &d.ExpiringSoon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock alerts: %w", err)
+	}
+
+	rows, err := db.Query(bounds+`
+		SELECT p.id, p.product_name, COALESCE(p.total_sold, 0),
+		       COALESCE(SUM(ii.units) FILTER (WHERE i.created_at >= b.cur_from), 0),
+		       COALESCE(SUM(ii.units) FILTER (WHERE i.created_at < b.cur_from), 0)
+		FROM bounds b
+		CROSS JOIN (
+			SELECT id, product_name, total_sold FROM product
+			WHERE deleted = 0 AND COALESCE(total_sold, 0) > 0
+			ORDER BY total_sold DESC, id
+			LIMIT $2
+		) p
+		LEFT JOIN invoice_items ii ON ii.product_id = p.id
+		LEFT JOIN invoice i ON ii.invoice_id = i.id AND i.deleted = 0
+		     AND i.created_at >= b.prev_from AND i.created_at < b.cur_to
+		GROUP BY p.id, p.product_name, p.total_sold
+		ORDER BY p.total_sold DESC, p.id
+	`, days, dashboardTopSellers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top sellers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.DashboardTopSellerDTO
+		var id int
+		if err := rows.Scan(&id, &t.Name, &t.TotalSold, &t.PeriodSold, &t.PreviousSold); err != nil {
+			return nil, fmt.Errorf("failed to scan top seller: %w", err)
+		}
+		t.ProductID = fmt.Sprintf("prod_%03d", id)
+		d.TopSellers = append(d.TopSellers, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating top sellers: %w", err)
+	}
+
+	return d, nil
+}
@@ -148,8 +148,8 @@ func CreateProductLegacy(db *sql.DB, name, description, strength string, generic
 // GetInvoices retrieves all non-deleted invoices
 func GetInvoices(db *sql.DB) ([]models.Invoice, error) {
 	query := `
-		SELECT id, customer_id_fk, invoice_type, subtotal, discount, 
-		       total, paid_amount, balance, status, notes,
+		SELECT id, customer_id_fk, invoice_type, subtotal, discount, COALESCE(vat, 0),
+		       total, paid_amount, balance, status, COALESCE(notes, ''),
 		       created_at, updated_at, deleted_at, deleted
 		FROM invoice
 		WHERE deleted = 0
@@ -166,7 +166,7 @@ func GetInvoices(db *sql.DB) ([]models.Invoice, error) {
 	for rows.Next() {
 		var inv models.Invoice
 		err := rows.Scan(&inv.ID, &inv.CustomerIDFk, &inv.InvoiceType,
-			&inv.Subtotal, &inv.Discount, &inv.Total, &inv.PaidAmount,
+			&inv.Subtotal, &inv.Discount, &inv.VAT, &inv.Total, &inv.PaidAmount,
 			&inv.Balance, &inv.Status, &inv.Notes,
 			&inv.CreatedAt, &inv.UpdatedAt, &inv.DeletedAt, &inv.Deleted)
 		if err != nil {
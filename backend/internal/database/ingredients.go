@@ -0,0 +1,344 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// ACTIVE INGREDIENTS
+// product_ingredient links a product to each of its active ingredients with
+// a numeric strength and unit. Products saved without an ingredient list get
+// theirs read from the generic name and strength, the same way migration 023
+// parsed existing products.
+// =====================================================
+
+// ingredientSeparator splits combination generics such as
+// "Paracetamol + Caffeine" or "Amoxicillin/Clavulanic Acid"
+var ingredientSeparator = regexp.MustCompile(`(?i)\s*(?:\+|/|,|&|\band\b|\bwith\b)\s*`)
+
+// strengthSeparator splits "10mg + 10mg" or "500mg/125mg" per ingredient
+var strengthSeparator = regexp.MustCompile(`\s*(?:\+|/|,)\s*`)
+
+var strengthPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(.*)$`)
+
+// splitGeneric splits a generic name into its ingredients in order, without
+// repeats
+func splitGeneric(generic string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, part := range ingredientSeparator.Split(generic, -1) {
+		part = strings.Join(strings.Fields(part), " ")
+		if part == "" || seen[strings.ToLower(part)] {
+			continue
+		}
+		seen[strings.ToLower(part)] = true
+		names = append(names, part)
+	}
+	return names
+}
+
+// parseStrength reads "500 mg" as 500 and "mg"; text without a leading
+// number has no strength
+func parseStrength(s string) (*float64, string) {
+	m := strengthPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return nil, ""
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return nil, ""
+	}
+	return &v, strings.TrimSpace(m[2])
+}
+
+// ingredientsFromGeneric reads a product's ingredients from its generic name
+// and strength text. Strengths pair up in order when there is one per
+// ingredient ("10mg + 10mg"); a single ingredient takes the whole strength
+// ("125mg/5ml").
+func ingredientsFromGeneric(generic, strength string) []models.IngredientRequest {
+	names := splitGeneric(generic)
+	strengths := strengthSeparator.Split(strings.TrimSpace(strength), -1)
+
+	ingredients := make([]models.IngredientRequest, len(names))
+	for i, name := range names {
+		ingredients[i].Name = name
+		switch {
+		case len(names) == 1:
+			ingredients[i].Strength, ingredients[i].Unit = parseStrength(strength)
+		case len(strengths) == len(names):
+			ingredients[i].Strength, ingredients[i].Unit = parseStrength(strengths[i])
+		}
+	}
+	return ingredients
+}
+
+// ingredientNames joins ingredient names into a generic name
+func ingredientNames(ingredients []models.IngredientRequest) string {
+	names := make([]string, len(ingredients))
+	for i, ing := range ingredients {
+		names[i] = strings.Join(strings.Fields(ing.Name), " ")
+	}
+	return strings.Join(names, " + ")
+}
+
+func getOrCreateIngredient(tx *sql.Tx, name string) (int, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM ingredient WHERE lower(name) = lower($1)", name).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow("INSERT INTO ingredient (name) VALUES ($1) RETURNING id", name).Scan(&id)
+	}
+	return id, err
+}
+
+// setProductIngredients replaces a product's active ingredients
+func setProductIngredients(tx *sql.Tx, productID int, ingredients []models.IngredientRequest) error {
+	seen := map[string]bool{}
+	for i, ing := range ingredients {
+		ing.Name = strings.Join(strings.Fields(ing.Name), " ")
+		if ing.Name == "" {
+			return fmt.Errorf("%w: ingredient name is required", ErrInvalidInput)
+		}
+		if seen[strings.ToLower(ing.Name)] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidInput, ing.Name)
+		}
+		seen[strings.ToLower(ing.Name)] = true
+		if ing.Strength != nil && *ing.Strength < 0 {
+			return fmt.Errorf("%w: %s strength cannot be negative", ErrInvalidInput, ing.Name)
+		}
+		if ing.Strength == nil && ing.Unit != "" {
+			return fmt.Errorf("%w: %s has a unit but no strength", ErrInvalidInput, ing.Name)
+		}
+		ingredients[i] = ing
+	}
+
+	if _, err := tx.Exec("DELETE FROM product_ingredient WHERE product_id = $1", productID); err != nil {
+		return fmt.Errorf("failed to clear ingredients: %w", err)
+	}
+	for i, ing := range ingredients {
+		ingredientID, err := getOrCreateIngredient(tx, ing.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get ingredient: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO product_ingredient (product_id, ingredient_id, strength, strength_unit, position)
+			VALUES ($1, $2, $3, $4, $5)
+		`, productID, ingredientID, ing.Strength, nullString(strings.TrimSpace(ing.Unit)), i+1)
+		if err != nil {
+			return fmt.Errorf("failed to link ingredient %s: %w", ing.Name, err)
+		}
+	}
+	return nil
+}
+
+// productIngredients loads the active ingredients of products, in order
+func productIngredients(q queryer, productIDs []int) (map[int][]models.ProductIngredientDTO, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := q.Query(`
+		SELECT pi.product_id, i.id, i.name, pi.strength::float8, COALESCE(pi.strength_unit, '')
+		FROM product_ingredient pi
+		JOIN ingredient i ON i.id = pi.ingredient_id
+		WHERE pi.product_id = ANY($1)
+		ORDER BY pi.product_id, pi.position, i.name
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ingredients: %w", err)
+	}
+	defer rows.Close()
+
+	ingredients := make(map[int][]models.ProductIngredientDTO)
+	for rows.Next() {
+		var productID int
+		var ing models.ProductIngredientDTO
+		var strength sql.NullFloat64
+		if err := rows.Scan(&productID, &ing.IngredientID, &ing.Name, &strength, &ing.Unit); err != nil {
+			return nil, fmt.Errorf("failed to scan ingredient: %w", err)
+		}
+		if strength.Valid {
+			ing.Strength = &strength.Float64
+		}
+		ingredients[productID] = append(ingredients[productID], ing)
+	}
+	return ingredients, rows.Err()
+}
+
+// withIngredients returns a product's ingredients, never nil
+func withIngredients(ingredients []models.ProductIngredientDTO) []models.ProductIngredientDTO {
+	if ingredients == nil {
+		return []models.ProductIngredientDTO{}
+	}
+	return ingredients
+}
+
+// GetIngredients lists active ingredients with the number of products
+// containing each, optionally filtered by name
+func GetIngredients(db *sql.DB, page, limit int, search string) ([]models.IngredientDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if search != "" {
+		args = append(args, "%"+search+"%")
+		whereClause += fmt.Sprintf(" AND i.name ILIKE $%d", len(args))
+	}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM ingredient i"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := `
+		SELECT i.id, i.name,
+		       (SELECT COUNT(*) FROM product_ingredient pi JOIN product p ON p.id = pi.product_id
+		        WHERE pi.ingredient_id = i.id AND p.deleted = 0)
+		FROM ingredient i` + whereClause +
+		fmt.Sprintf(" ORDER BY i.name LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	ingredients := []models.IngredientDTO{}
+	for rows.Next() {
+		var ing models.IngredientDTO
+		if err := rows.Scan(&ing.ID, &ing.Name, &ing.ProductCount); err != nil {
+			return nil, models.Pagination{}, fmt.Errorf("failed to scan ingredient: %w", err)
+		}
+		ingredients = append(ingredients, ing)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	totalPages := (totalItems + limit - 1) / limit
+	return ingredients, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
+
+// CreateIngredient adds an active ingredient
+func CreateIngredient(db *sql.DB, req models.CreateIngredientRequest) (*models.IngredientDTO, error) {
+	name := strings.Join(strings.Fields(req.Name), " ")
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM ingredient WHERE lower(name) = lower($1))", name).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ingredient: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: ingredient %s already exists", ErrInvalidInput, name)
+	}
+
+	ing := models.IngredientDTO{Name: name}
+	err = db.QueryRow("INSERT INTO ingredient (name) VALUES ($1) RETURNING id", name).Scan(&ing.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ingredient: %w", err)
+	}
+	return &ing, nil
+}
+
+// GetProductsByIngredients lists products containing every named
+// ingredient. With exact set, products with any other ingredient are left
+// out, so "rosuvastatin" alone skips the Rosuvastatin + Ezetimibe combination.
+func GetProductsByIngredients(db *sql.DB, names []string, exact bool, page, limit int) ([]models.IngredientProductDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	seen := map[string]bool{}
+	var wanted []string
+	for _, name := range names {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			wanted = append(wanted, name)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, models.Pagination{}, fmt.Errorf("%w: at least one ingredient is required", ErrInvalidInput)
+	}
+
+	whereClause := `
+		WHERE p.deleted = 0 AND p.id IN (
+			SELECT pi.product_id FROM product_ingredient pi
+			JOIN ingredient i ON i.id = pi.ingredient_id
+			WHERE lower(i.name) = ANY($1)
+			GROUP BY pi.product_id
+			HAVING COUNT(*) = $2
+		)`
+	if exact {
+		whereClause += " AND (SELECT COUNT(*) FROM product_ingredient x WHERE x.product_id = p.id) = $2"
+	}
+	args := []interface{}{pq.Array(wanted), len(wanted)}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM product p"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT p.id, p.product_name, COALESCE(g.generic_name, ''), COALESCE(p.strength, ''),
+		       COALESCE(p.unit_price, 0), COALESCE(p.available_stock, 0), p.requires_prescription
+		FROM product p
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id`+whereClause+`
+		ORDER BY p.product_name, p.id
+		LIMIT $3 OFFSET $4
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	products := []models.IngredientProductDTO{}
+	var ids []int
+	for rows.Next() {
+		var p models.IngredientProductDTO
+		var id int
+		if err := rows.Scan(&id, &p.Name, &p.GenericName, &p.Strength, &p.Price, &p.InStock, &p.RequiresPrescription); err != nil {
+			return nil, models.Pagination{}, fmt.Errorf("failed to scan product: %w", err)
+		}
+		p.ID = fmt.Sprintf("prod_%03d", id)
+		products = append(products, p)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	rows.Close()
+
+	ingredients, err := productIngredients(db, ids)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	for i := range products {
+		products[i].Ingredients = withIngredients(ingredients[ids[i]])
+	}
+
+	totalPages := (totalItems + limit - 1) / limit
+	return products, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
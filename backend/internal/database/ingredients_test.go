@@ -0,0 +1,99 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"pharmacy-backend/internal/models"
+)
+
+func TestSplitGeneric(t *testing.T) {
+	tests := []struct {
+		generic string
+		want    []string
+	}{
+		{"Paracetamol", []string{"Paracetamol"}},
+		{"Rosuvastatin + Ezetimibe", []string{"Rosuvastatin", "Ezetimibe"}},
+		{"Amoxicillin/Clavulanic  Acid", []string{"Amoxicillin", "Clavulanic Acid"}},
+		{"Paracetamol and Caffeine", []string{"Paracetamol", "Caffeine"}},
+		{"Calcium with Vitamin D3", []string{"Calcium", "Vitamin D3"}},
+		{"Zinc, Iron & Folic Acid", []string{"Zinc", "Iron", "Folic Acid"}},
+		{"Paracetamol + paracetamol", []string{"Paracetamol"}},
+		{"Mandelamine", []string{"Mandelamine"}}, // "and" only as a word
+		{" + ", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitGeneric(tt.generic); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitGeneric(%q) = %q, want %q", tt.generic, got, tt.want)
+		}
+	}
+}
+
+func TestParseStrength(t *testing.T) {
+	tests := []struct {
+		s        string
+		strength *float64
+		unit     string
+	}{
+		{"500mg", ptr(500), "mg"},
+		{" 500 MG ", ptr(500), "MG"},
+		{"0.5%", ptr(0.5), "%"},
+		{"125mg/5ml", ptr(125), "mg/5ml"},
+		{"10", ptr(10), ""},
+		{"mg", nil, ""},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		strength, unit := parseStrength(tt.s)
+		if !reflect.DeepEqual(strength, tt.strength) || unit != tt.unit {
+			t.Errorf("parseStrength(%q) = %v, %q; want %v, %q", tt.s, deref(strength), unit, deref(tt.strength), tt.unit)
+		}
+	}
+}
+
+func TestIngredientsFromGeneric(t *testing.T) {
+	tests := []struct {
+		generic, strength string
+		want              []models.IngredientRequest
+	}{
+		{"Paracetamol", "500mg", []models.IngredientRequest{
+			{Name: "Paracetamol", Strength: ptr(500), Unit: "mg"},
+		}},
+		{"Amoxicillin", "125mg/5ml", []models.IngredientRequest{
+			{Name: "Amoxicillin", Strength: ptr(125), Unit: "mg/5ml"},
+		}},
+		{"Rosuvastatin + Ezetimibe", "10mg + 20mg", []models.IngredientRequest{
+			{Name: "Rosuvastatin", Strength: ptr(10), Unit: "mg"},
+			{Name: "Ezetimibe", Strength: ptr(20), Unit: "mg"},
+		}},
+		// One strength for two ingredients can't be split
+		{"Paracetamol + Caffeine", "565mg", []models.IngredientRequest{
+			{Name: "Paracetamol"},
+			{Name: "Caffeine"},
+		}},
+		{"Omeprazole", "", []models.IngredientRequest{{Name: "Omeprazole"}}},
+		{"", "500mg", []models.IngredientRequest{}},
+	}
+	for _, tt := range tests {
+		if got := ingredientsFromGeneric(tt.generic, tt.strength); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ingredientsFromGeneric(%q, %q) = %+v, want %+v", tt.generic, tt.strength, got, tt.want)
+		}
+	}
+}
+
+func TestIngredientNames(t *testing.T) {
+	got := ingredientNames([]models.IngredientRequest{{Name: " Rosuvastatin "}, {Name: "Clavulanic   Acid"}})
+	if want := "Rosuvastatin + Clavulanic Acid"; got != want {
+		t.Errorf("ingredientNames() = %q, want %q", got, want)
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+func deref(v *float64) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
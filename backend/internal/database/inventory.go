@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
-	"time"
 
 	"pharmacy-backend/internal/models"
 )
@@ -27,10 +26,12 @@ func GetMedicines(db *sql.DB, page, limit int, search, status, rack, sort string
 	var args []interface{}
 	argCounter := 1
 
+	var searchFilter productSearch
 	if search != "" {
-		whereClause += fmt.Sprintf(" AND (p.product_name ILIKE $%d OR p.product_code ILIKE $%d OR g.generic_name ILIKE $%d)", argCounter, argCounter, argCounter)
-		args = append(args, "%"+search+"%")
-		argCounter++
+		searchFilter = newProductSearch(search, argCounter)
+		whereClause += " AND " + searchFilter.where
+		args = append(args, searchFilter.args...)
+		argCounter += len(searchFilter.args)
 	}
 
 	if status != "" {
@@ -70,7 +71,7 @@ func GetMedicines(db *sql.DB, page, limit int, search, status, rack, sort string
 			p.available_stock, p.status,
 			c.category_name, pt.type_name,
 			p.total_purchase, p.total_sold,
-			p.barcode, p.stock_alert
+			p.barcode, p.stock_alert, p.requires_prescription, COALESCE(p.schedule, '')
 	` + joins + whereClause
 
 	// Build ORDER BY clause based on sort parameter
@@ -93,6 +94,11 @@ func GetMedicines(db *sql.DB, page, limit int, search, status, rack, sort string
 	case "date_desc":
 		orderBy += "p.created_at DESC, p.id DESC"
 	default:
+		if search != "" {
+			// Searches default to best match first
+			orderBy += searchFilter.rank + " DESC, p.product_name ASC, p.id ASC"
+			break
+		}
 		// Default: newest first
 		orderBy += "p.created_at DESC, p.id DESC"
 	}
@@ -130,7 +136,7 @@ func GetMedicines(db *sql.DB, page, limit int, search, status, rack, sort string
 			&p.InStock, &p.StockStatus,
 			&catName, &typeName,
 			&p.TotalPurchase, &p.TotalSold,
-			&barcode, &p.StockAlert,
+			&barcode, &p.StockAlert, &p.RequiresPrescription, &p.Schedule,
 		)
 		if err != nil {
 			return nil, models.Pagination{}, err
@@ -189,23 +195,13 @@ func GetMedicines(db *sql.DB, page, limit int, search, status, rack, sort string
 }
 
 func fillExtraData(db *sql.DB, p *models.ProductResponse, productID int) {
-	packQuery := `SELECT pack_type, units_per_pack, selling_price FROM product_packaging WHERE product_id = $1`
-	rows, err := db.Query(packQuery, productID)
+	levels, err := productPackagingLevels(db, []int{productID})
 	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var pType string
-			var units int
-			var price float64
-			rows.Scan(&pType, &units, &price)
-			if pType == "strip" {
-				p.PackSize.Strip = units
-				p.PackPrice.Strip = price
-			} else if pType == "box" {
-				p.PackSize.Box = units
-				p.PackPrice.Box = price
-			}
-		}
+		applyPackaging(p, levels[productID])
+	}
+	ingredients, err := productIngredients(db, []int{productID})
+	if err == nil {
+		p.Ingredients = withIngredients(ingredients[productID])
 	}
 
 	supQuery := `
@@ -221,90 +217,25 @@ func fillExtraData(db *sql.DB, p *models.ProductResponse, productID int) {
 		p.SupplierContact = sContact.String
 	}
 
-	batchQuery := `SELECT batch_id, expiry_date, purchase_date FROM product_batch WHERE product_id = $1 ORDER BY expiry_date ASC LIMIT 1`
+	// Show the batch that will be sold next (FEFO), falling back to the earliest-expiring one
+	batchQuery := `
+		SELECT batch_id, expiry_date, purchase_date FROM product_batch
+		WHERE product_id = $1
+		ORDER BY (quantity > 0 AND status = 'active' AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)) DESC,
+		         expiry_date ASC NULLS LAST, id ASC
+		LIMIT 1`
 	var bId string
-	var expDate, purDate time.Time
+	var expDate, purDate sql.NullTime
 	err = db.QueryRow(batchQuery, productID).Scan(&bId, &expDate, &purDate)
 	if err == nil {
 		p.BatchID = bId
-		p.ExpiryDate = expDate.Format("2006-01-02")
-		p.PurchaseDate = purDate.Format("2006-01-02")
-	}
-}
-
-// CreateMedicine adds a new medicine
-func CreateMedicine(db *sql.DB, req models.CreateProductRequest) (*models.ProductResponse, error) {
-	tx, err := db.Begin()
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	genericID := getOrCreateID(tx, "generic_name", "generic_name", req.GenericName)
-	rackID := getOrCreateID(tx, "rack", "rack_name", req.RackNo)
-	catID := getOrCreateID(tx, "category", "category_name", req.Category)
-	typeID := getOrCreateID(tx, "product_type", "type_name", req.Type)
-
-	var supplierID int
-	if req.Supplier != "" {
-		err := tx.QueryRow("SELECT id FROM supplier WHERE name = $1", req.Supplier).Scan(&supplierID)
-		if err == sql.ErrNoRows {
-			err = tx.QueryRow("INSERT INTO supplier (name, contact) VALUES ($1, $2) RETURNING id", req.Supplier, req.SupplierContact).Scan(&supplierID)
-			if err != nil {
-				return nil, err
-			}
-		} else if err != nil {
-			return nil, err
+		if expDate.Valid {
+			p.ExpiryDate = expDate.Time.Format("2006-01-02")
 		}
-	}
-
-	var productID int
-	query := `
-		INSERT INTO product (
-			product_name, product_description, strength, manufacture,
-			generic_fk_id, rack_fk_id, category_fk_id, product_type_fk_id,
-			unit_price, unit_mrp, unit_cost_price, discount_percent,
-			available_stock, stock_alert, status, product_code
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-		RETURNING id
-	`
-	pCode := req.Name // simplified
-
-	err = tx.QueryRow(query,
-		req.Name, req.Description, req.Strength, req.Manufacture,
-		nullInt(genericID), nullInt(rackID), nullInt(catID), nullInt(typeID),
-		req.Price, req.MRP, req.BuyingPrice, req.Discount,
-		req.InStock, 10, "Active", pCode,
-	).Scan(&productID)
-	if err != nil {
-		return nil, fmt.Errorf("failed insert product: %v", err)
-	}
-
-	_, _ = tx.Exec(`INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price, mrp, cost_price) 
-		VALUES ($1, 'unit', 1, $2, $3, $4)`, productID, req.Price, req.MRP, req.BuyingPrice)
-
-	if req.PackSize.Strip > 0 {
-		_, _ = tx.Exec(`INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price) 
-			VALUES ($1, 'strip', $2, $3)`, productID, req.PackSize.Strip, req.PackPrice.Strip)
-	}
-	if req.PackSize.Box > 0 {
-		_, _ = tx.Exec(`INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price) 
-			VALUES ($1, 'box', $2, $3)`, productID, req.PackSize.Box, req.PackPrice.Box)
-	}
-
-	if supplierID > 0 {
-		_, err = tx.Exec(`INSERT INTO product_supplier (product_id, supplier_id, is_primary, buying_price) 
-			VALUES ($1, $2, true, $3)`, productID, supplierID, req.BuyingPrice)
-		if err != nil {
-			return nil, err
+		if purDate.Valid {
+			p.PurchaseDate = purDate.Time.Format("2006-01-02")
 		}
 	}
-
-	if err := tx.Commit(); err != nil {
-		return nil, err
-	}
-
-	return &models.ProductResponse{ID: strconv.Itoa(productID), Name: req.Name}, nil
 }
 
 // UpdateMedicine updates an existing medicine
@@ -328,8 +259,11 @@ func UpdateMedicine(db *sql.DB, idStr string, req models.UpdateProductRequest) e
 		}
 	}
 	if req.InStock != nil {
-		_, err := tx.Exec("UPDATE product SET available_stock = $1 WHERE id = $2", *req.InStock, id)
-		if err != nil {
+		note := ""
+		if req.StockNote != nil {
+			note = *req.StockNote
+		}
+		if err := setStockLevel(tx, id, *req.InStock, adjustmentReason(req.StockReason), note, 0); err != nil {
 			return err
 		}
 	}
@@ -339,14 +273,13 @@ func UpdateMedicine(db *sql.DB, idStr string, req models.UpdateProductRequest) e
 }
 
 // DeleteMedicine soft deletes a medicine
-func DeleteMedicine(db *sql.DB, idStr string) error {
-	id, err := strconv.Atoi(idStr)
+func DeleteMedicine(db *sql.DB, actor models.Actor, idStr string) error {
+	id, err := parseProductID(idStr)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
 	}
 
-	_, err = db.Exec("UPDATE product SET deleted = 1, deleted_at = NOW() WHERE id = $1", id)
-	return err
+	return softDeleteProduct(db, actor, id)
 }
 
 // GetRacks fetches all racks
@@ -401,18 +334,6 @@ func GetGenerics(db *sql.DB) ([]models.GenericName, error) {
 	return generics, nil
 }
 
-func getOrCreateID(tx *sql.Tx, table, col, val string) int {
-	if val == "" {
-		return 0
-	}
-	var id int
-	err := tx.QueryRow(fmt.Sprintf("SELECT id FROM %s WHERE %s = $1", table, col), val).Scan(&id)
-	if err == sql.ErrNoRows {
-		tx.QueryRow(fmt.Sprintf("INSERT INTO %s (%s) VALUES ($1) RETURNING id", table, col), val).Scan(&id)
-	}
-	return id
-}
-
 func nullInt(i int) interface{} {
 	if i == 0 {
 		return nil
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// PACKAGING
+// Stock is kept in base units. product_packaging holds any number of
+// packaging levels per product (strip, box, bottle, carton, ... from the
+// pack_unit table), each with the units it holds and what it sells for.
+// Every level holds a whole number of the next smaller one. Every pack
+// quantity is converted to units here.
+// =====================================================
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	rowQueryer
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// packLevel is one packaging level of a product
+type packLevel struct {
+	packType     models.PackType
+	name, plural string
+	units        int
+	price, mrp   float64
+	barcode      string
+}
+
+// productPackagingLevels loads the packaging levels of products, smallest
+// first. Levels without a positive unit count are not sold and left out.
+func productPackagingLevels(q queryer, productIDs []int) (map[int][]packLevel, error) {
+	ids := make([]int64, len(productIDs))
+	for i, id := range productIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := q.Query(`
+		SELECT pp.product_id, pp.pack_type, COALESCE(pu.name, pp.pack_type), COALESCE(pu.plural_name, pp.pack_type),
+		       pp.units_per_pack, COALESCE(pp.selling_price, 0), COALESCE(pp.mrp, 0), COALESCE(pp.barcode, '')
+		FROM product_packaging pp
+		LEFT JOIN pack_unit pu ON pu.code = pp.pack_type
+		WHERE pp.product_id = ANY($1) AND pp.units_per_pack > 0
+		ORDER BY pp.product_id, pp.units_per_pack, pp.pack_type
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query packaging: %w", err)
+	}
+	defer rows.Close()
+
+	levels := make(map[int][]packLevel)
+	for rows.Next() {
+		var productID int
+		var l packLevel
+		if err := rows.Scan(&productID, &l.packType, &l.name, &l.plural, &l.units, &l.price, &l.mrp, &l.barcode); err != nil {
+			return nil, fmt.Errorf("failed to scan packaging: %w", err)
+		}
+		levels[productID] = append(levels[productID], l)
+	}
+	return levels, rows.Err()
+}
+
+// packaging is what a product is sold in and for how much
+type packaging struct {
+	productName string
+	unitPrice   float64     // one loose unit
+	levels      []packLevel // packs above the unit, smallest first
+}
+
+// loadPackaging reads a product's packaging. A product without a 'unit'
+// packaging row is still sold by the unit at its unit price; without a unit
+// price, loose units are priced as a share of the smallest opened pack.
+func loadPackaging(q queryer, productID int) (packaging, error) {
+	var p packaging
+	var productPrice sql.NullFloat64
+	err := q.QueryRow("SELECT product_name, unit_price FROM product WHERE id = $1 AND deleted = 0", productID).
+		Scan(&p.productName, &productPrice)
+	if err == sql.ErrNoRows {
+		return packaging{}, fmt.Errorf("product %d not found", productID)
+	}
+	if err != nil {
+		return packaging{}, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	levels, err := productPackagingLevels(q, []int{productID})
+	if err != nil {
+		return packaging{}, err
+	}
+	var unitRow *packLevel
+	for i, l := range levels[productID] {
+		if l.packType == models.PackTypeUnit {
+			unitRow = &levels[productID][i]
+			continue
+		}
+		p.levels = append(p.levels, l)
+	}
+
+	switch {
+	case unitRow != nil:
+		p.unitPrice = unitRow.price
+	case productPrice.Float64 > 0:
+		p.unitPrice = productPrice.Float64
+	default:
+		for _, l := range p.levels {
+			if l.price > 0 {
+				p.unitPrice = roundMoney(l.price / float64(l.units))
+				break
+			}
+		}
+	}
+	return p, nil
+}
+
+// level returns the packaging level of packType; ok is false when the
+// product is not packed that way
+func (p packaging) level(packType models.PackType) (l packLevel, ok bool) {
+	if packType == models.PackTypeUnit {
+		return packLevel{packType: packType, units: 1, price: p.unitPrice}, true
+	}
+	for _, l := range p.levels {
+		if l.packType == packType {
+			return l, true
+		}
+	}
+	return packLevel{}, false
+}
+
+// unitsPer returns how many base units one pack of packType holds
+func (p packaging) unitsPer(packType models.PackType) (units int, ok bool) {
+	l, ok := p.level(packType)
+	return l.units, ok
+}
+
+// packPrice returns the selling price of one pack of packType
+func (p packaging) packPrice(packType models.PackType) float64 {
+	l, _ := p.level(packType)
+	return l.price
+}
+
+// toUnits converts a quantity of packType to base units. Part packs are
+// fine as long as they come to whole units, e.g. half a strip of 10.
+func (p packaging) toUnits(packType models.PackType, quantity float64) (int, error) {
+	unitsPerPack, ok := p.unitsPer(packType)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s is not sold by %s", ErrInvalidInput, p.productName, packType)
+	}
+	u := quantity * float64(unitsPerPack)
+	if math.Abs(u-math.Round(u)) > 1e-9 {
+		return 0, fmt.Errorf("%w: %.2f %s of %s is not a whole number of units", ErrInvalidInput, quantity, packType, p.productName)
+	}
+	return int(math.Round(u)), nil
+}
+
+// validatePackLevels checks a product's packaging: known shape, no pack type
+// twice, a unit holds one unit, and sorted by size every level holds a whole
+// number of the one below it (box >= strip >= unit)
+func validatePackLevels(levels []models.PackagingRequest) error {
+	seen := map[models.PackType]bool{}
+	sizes := []int{1}
+	for _, l := range levels {
+		if l.PackType == "" {
+			return fmt.Errorf("%w: packaging needs a pack type", ErrInvalidInput)
+		}
+		if seen[l.PackType] {
+			return fmt.Errorf("%w: %s packaging is listed twice", ErrInvalidInput, l.PackType)
+		}
+		seen[l.PackType] = true
+		if l.UnitsPerPack <= 0 {
+			return fmt.Errorf("%w: a %s must hold at least one unit", ErrInvalidInput, l.PackType)
+		}
+		if l.Price < 0 || l.MRP < 0 {
+			return fmt.Errorf("%w: %s price cannot be negative", ErrInvalidInput, l.PackType)
+		}
+		if l.PackType == models.PackTypeUnit {
+			if l.UnitsPerPack != 1 {
+				return fmt.Errorf("%w: a unit holds exactly one unit", ErrInvalidInput)
+			}
+			if l.Barcode != "" {
+				return fmt.Errorf("%w: the unit barcode is the product barcode", ErrInvalidInput)
+			}
+			continue
+		}
+		sizes = append(sizes, l.UnitsPerPack)
+	}
+
+	sort.Ints(sizes)
+	for i := 1; i < len(sizes); i++ {
+		if sizes[i]%sizes[i-1] != 0 {
+			return fmt.Errorf("%w: a pack of %d units does not hold a whole number of %d-unit packs", ErrInvalidInput, sizes[i], sizes[i-1])
+		}
+	}
+	return nil
+}
+
+// validatePackSize checks the legacy strip and box sizes: not negative, and
+// a box holds a whole number of strips
+func validatePackSize(size models.PackSize) error {
+	if size.Strip < 0 || size.Box < 0 {
+		return fmt.Errorf("%w: pack sizes cannot be negative", ErrInvalidInput)
+	}
+	if size.Strip > 0 && size.Box > 0 && size.Box < size.Strip {
+		return fmt.Errorf("%w: a box (%d units) cannot hold fewer units than a strip (%d)", ErrInvalidInput, size.Box, size.Strip)
+	}
+	var levels []models.PackagingRequest
+	if size.Strip > 0 {
+		levels = append(levels, models.PackagingRequest{PackType: models.PackTypeStrip, UnitsPerPack: size.Strip})
+	}
+	if size.Box > 0 {
+		levels = append(levels, models.PackagingRequest{PackType: models.PackTypeBox, UnitsPerPack: size.Box})
+	}
+	return validatePackLevels(levels)
+}
+
+// setPackaging replaces every packaging level of a product. Pack costs
+// follow the product's unit cost price.
+func setPackaging(tx *sql.Tx, productID int, levels []models.PackagingRequest) error {
+	if err := validatePackLevels(levels); err != nil {
+		return err
+	}
+
+	packTypes := make([]string, len(levels))
+	for i, l := range levels {
+		var known bool
+		err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM pack_unit WHERE code = $1)", l.PackType).Scan(&known)
+		if err != nil {
+			return fmt.Errorf("failed to get pack unit: %w", err)
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown pack type %q", ErrInvalidInput, l.PackType)
+		}
+		packTypes[i] = string(l.PackType)
+	}
+
+	_, err := tx.Exec("DELETE FROM product_packaging WHERE product_id = $1 AND NOT (pack_type = ANY($2))",
+		productID, pq.Array(packTypes))
+	if err != nil {
+		return fmt.Errorf("failed to remove packaging: %w", err)
+	}
+
+	for _, l := range levels {
+		mrp := l.MRP
+		if mrp == 0 {
+			mrp = l.Price
+		}
+		_, err := tx.Exec(`
+			INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price, mrp, cost_price)
+			VALUES ($1, $2, $3, $4, $5, $3 * (SELECT COALESCE(unit_cost_price, 0) FROM product WHERE id = $1))
+			ON CONFLICT (product_id, pack_type)
+			DO UPDATE SET units_per_pack = EXCLUDED.units_per_pack, selling_price = EXCLUDED.selling_price,
+			              mrp = EXCLUDED.mrp, cost_price = EXCLUDED.cost_price, updated_at = NOW()
+		`, productID, l.PackType, l.UnitsPerPack, l.Price, mrp)
+		if err != nil {
+			return fmt.Errorf("failed to save %s packaging: %w", l.PackType, err)
+		}
+	}
+
+	for _, l := range levels {
+		if l.PackType == models.PackTypeUnit {
+			continue
+		}
+		if err := setPackBarcode(tx, productID, l.PackType, l.Barcode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyPackaging fills in a product response's packaging list, unit first,
+// and its stock broken down by pack. packSize, packPrice and packBarcode
+// still carry the strip and box levels for older clients.
+func applyPackaging(p *models.ProductResponse, levels []packLevel) {
+	unit := models.PackagingDTO{
+		PackType:     models.PackTypeUnit,
+		Name:         "unit",
+		UnitsPerPack: 1,
+		Price:        p.Price,
+		MRP:          p.MRP,
+		Barcode:      p.Barcode,
+	}
+	var packs []packLevel
+	for _, l := range levels {
+		if l.packType == models.PackTypeUnit {
+			unit.Name, unit.Price, unit.MRP = l.name, l.price, l.mrp
+			continue
+		}
+		packs = append(packs, l)
+	}
+
+	p.PackSize, p.PackPrice, p.PackBarcode = models.PackSize{}, models.PackPrice{}, models.PackBarcode{}
+	p.Packaging = []models.PackagingDTO{unit}
+	for _, l := range packs {
+		switch l.packType {
+		case models.PackTypeStrip:
+			p.PackSize.Strip, p.PackPrice.Strip, p.PackBarcode.Strip = l.units, l.price, l.barcode
+		case models.PackTypeBox:
+			p.PackSize.Box, p.PackPrice.Box, p.PackBarcode.Box = l.units, l.price, l.barcode
+		}
+		p.Packaging = append(p.Packaging, models.PackagingDTO{
+			PackType:     l.packType,
+			Name:         l.name,
+			UnitsPerPack: l.units,
+			Price:        l.price,
+			MRP:          l.mrp,
+			Barcode:      l.barcode,
+		})
+	}
+	p.Availability = packAvailability(p.InStock, packs)
+}
+
+// packAvailability breaks stock down into whole packs, largest first, then
+// loose units, e.g. "3 boxes, 2 strips, 4 units". A one-unit pack such as a
+// bottle names the loose units.
+func packAvailability(units int, levels []packLevel) models.PackAvailability {
+	a := models.PackAvailability{Packs: []models.PackCount{}}
+	loose := packLevel{packType: models.PackTypeUnit, name: "unit", plural: "units", units: 1}
+	remaining := max(units, 0)
+
+	var parts []string
+	for i := len(levels) - 1; i >= 0; i-- {
+		l := levels[i]
+		if l.units == 1 {
+			loose = l
+			continue
+		}
+		count := remaining / l.units
+		remaining %= l.units
+		switch l.packType {
+		case models.PackTypeBox:
+			a.Boxes = count
+		case models.PackTypeStrip:
+			a.Strips = count
+		}
+		if count > 0 {
+			a.Packs = append(a.Packs, models.PackCount{PackType: l.packType, Count: count})
+			parts = append(parts, countLabel(count, l.name, l.plural))
+		}
+	}
+
+	a.Units = remaining
+	if remaining > 0 || len(parts) == 0 {
+		if remaining > 0 {
+			a.Packs = append(a.Packs, models.PackCount{PackType: loose.packType, Count: remaining})
+		}
+		parts = append(parts, countLabel(remaining, loose.name, loose.plural))
+	}
+	a.Label = strings.Join(parts, ", ")
+	return a
+}
+
+func countLabel(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// GetPackUnits lists the pack types products can be sold in
+func GetPackUnits(db *sql.DB) ([]models.PackUnit, error) {
+	rows, err := db.Query("SELECT code, name, plural_name FROM pack_unit ORDER BY code")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pack units: %w", err)
+	}
+	defer rows.Close()
+
+	units := []models.PackUnit{}
+	for rows.Next() {
+		var u models.PackUnit
+		if err := rows.Scan(&u.Code, &u.Name, &u.PluralName); err != nil {
+			return nil, fmt.Errorf("failed to scan pack unit: %w", err)
+		}
+		units = append(units, u)
+	}
+	return units, rows.Err()
+}
+
+// CreatePackUnit adds a pack type, e.g. {"code": "pouch", "name": "pouch"}.
+// The plural defaults to the name with an "s".
+func CreatePackUnit(db *sql.DB, req models.PackUnit) (*models.PackUnit, error) {
+	req.Code = models.PackType(strings.ToLower(strings.TrimSpace(string(req.Code))))
+	req.Name = strings.TrimSpace(req.Name)
+	req.PluralName = strings.TrimSpace(req.PluralName)
+	if req.Code == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrInvalidInput)
+	}
+	if req.Name == "" {
+		req.Name = string(req.Code)
+	}
+	if req.PluralName == "" {
+		req.PluralName = req.Name + "s"
+	}
+
+	res, err := db.Exec(`
+		INSERT INTO pack_unit (code, name, plural_name) VALUES ($1, $2, $3)
+		ON CONFLICT (code) DO NOTHING
+	`, req.Code, req.Name, req.PluralName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pack unit: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: pack unit %s already exists", ErrInvalidInput, req.Code)
+	}
+	return &req, nil
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"pharmacy-backend/internal/models"
+)
+
+// stripsAndBoxes is a product sold loose, in strips of 10 and boxes of 100
+var stripsAndBoxes = packaging{
+	productName: "Napa 500mg",
+	unitPrice:   1.2,
+	levels: []packLevel{
+		{packType: models.PackTypeStrip, name: "strip", plural: "strips", units: 10, price: 12},
+		{packType: models.PackTypeBox, name: "box", plural: "boxes", units: 100, price: 115},
+	},
+}
+
+func TestToUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		packType models.PackType
+		quantity float64
+		want     int
+		wantErr  bool
+	}{
+		{"units", models.PackTypeUnit, 7, 7, false},
+		{"strips", models.PackTypeStrip, 3, 30, false},
+		{"boxes", models.PackTypeBox, 2, 200, false},
+		{"half a strip", models.PackTypeStrip, 0.5, 5, false},
+		{"part strip in tenths", models.PackTypeStrip, 0.3, 3, false},
+		{"part strip not whole units", models.PackTypeStrip, 0.25, 0, true},
+		{"part unit", models.PackTypeUnit, 1.5, 0, true},
+		{"pack type not sold", models.PackType("bottle"), 1, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stripsAndBoxes.toUnits(tt.packType, tt.quantity)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Fatalf("toUnits(%s, %v) error = %v, want ErrInvalidInput", tt.packType, tt.quantity, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toUnits(%s, %v) unexpected error: %v", tt.packType, tt.quantity, err)
+			}
+			if got != tt.want {
+				t.Errorf("toUnits(%s, %v) = %d, want %d", tt.packType, tt.quantity, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePackLevels(t *testing.T) {
+	level := func(packType models.PackType, units int) models.PackagingRequest {
+		return models.PackagingRequest{PackType: packType, UnitsPerPack: units}
+	}
+	tests := []struct {
+		name    string
+		levels  []models.PackagingRequest
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"strip and box", []models.PackagingRequest{level(models.PackTypeStrip, 10), level(models.PackTypeBox, 100)}, false},
+		{"unit row", []models.PackagingRequest{level(models.PackTypeUnit, 1), level(models.PackTypeStrip, 10)}, false},
+		{"any order", []models.PackagingRequest{level(models.PackTypeBox, 60), level(models.PackTypeStrip, 10)}, false},
+		{"box not whole strips", []models.PackagingRequest{level(models.PackTypeStrip, 10), level(models.PackTypeBox, 25)}, true},
+		{"missing pack type", []models.PackagingRequest{level("", 10)}, true},
+		{"listed twice", []models.PackagingRequest{level(models.PackTypeStrip, 10), level(models.PackTypeStrip, 10)}, true},
+		{"empty pack", []models.PackagingRequest{level(models.PackTypeStrip, 0)}, true},
+		{"unit of two", []models.PackagingRequest{level(models.PackTypeUnit, 2)}, true},
+		{"negative price", []models.PackagingRequest{{PackType: models.PackTypeStrip, UnitsPerPack: 10, Price: -1}}, true},
+		{"unit barcode", []models.PackagingRequest{{PackType: models.PackTypeUnit, UnitsPerPack: 1, Barcode: "2000000000015"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePackLevels(tt.levels)
+			if tt.wantErr && !errors.Is(err, ErrInvalidInput) {
+				t.Fatalf("validatePackLevels() error = %v, want ErrInvalidInput", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("validatePackLevels() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestPackAvailability(t *testing.T) {
+	bottle := []packLevel{{packType: "bottle", name: "bottle", plural: "bottles", units: 1}}
+	cartons := []packLevel{
+		{packType: "sachet", name: "sachet", plural: "sachets", units: 1},
+		{packType: "carton", name: "carton", plural: "cartons", units: 24},
+	}
+	tests := []struct {
+		name   string
+		units  int
+		levels []packLevel
+		want   models.PackAvailability
+	}{
+		{"boxes, strips and units", 324, stripsAndBoxes.levels, models.PackAvailability{
+			Packs: []models.PackCount{
+				{PackType: models.PackTypeBox, Count: 3},
+				{PackType: models.PackTypeStrip, Count: 2},
+				{PackType: models.PackTypeUnit, Count: 4},
+			},
+			Boxes: 3, Strips: 2, Units: 4, Label: "3 boxes, 2 strips, 4 units",
+		}},
+		{"one of each", 111, stripsAndBoxes.levels, models.PackAvailability{
+			Packs: []models.PackCount{
+				{PackType: models.PackTypeBox, Count: 1},
+				{PackType: models.PackTypeStrip, Count: 1},
+				{PackType: models.PackTypeUnit, Count: 1},
+			},
+			Boxes: 1, Strips: 1, Units: 1, Label: "1 box, 1 strip, 1 unit",
+		}},
+		{"whole strips", 20, stripsAndBoxes.levels, models.PackAvailability{
+			Packs:  []models.PackCount{{PackType: models.PackTypeStrip, Count: 2}},
+			Strips: 2, Label: "2 strips",
+		}},
+		{"loose units only", 7, nil, models.PackAvailability{
+			Packs: []models.PackCount{{PackType: models.PackTypeUnit, Count: 7}},
+			Units: 7, Label: "7 units",
+		}},
+		{"out of stock", 0, stripsAndBoxes.levels, models.PackAvailability{
+			Packs: []models.PackCount{}, Label: "0 units",
+		}},
+		{"negative stock", -5, stripsAndBoxes.levels, models.PackAvailability{
+			Packs: []models.PackCount{}, Label: "0 units",
+		}},
+		{"bottles name the units", 3, bottle, models.PackAvailability{
+			Packs: []models.PackCount{{PackType: "bottle", Count: 3}},
+			Units: 3, Label: "3 bottles",
+		}},
+		{"custom pack units", 50, cartons, models.PackAvailability{
+			Packs: []models.PackCount{{PackType: "carton", Count: 2}, {PackType: "sachet", Count: 2}},
+			Units: 2, Label: "2 cartons, 2 sachets",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := packAvailability(tt.units, tt.levels)
+			if got.Label != tt.want.Label || got.Boxes != tt.want.Boxes || got.Strips != tt.want.Strips || got.Units != tt.want.Units {
+				t.Errorf("packAvailability(%d) = %+v, want %+v", tt.units, got, tt.want)
+			}
+			if len(got.Packs) != len(tt.want.Packs) {
+				t.Fatalf("packAvailability(%d).Packs = %v, want %v", tt.units, got.Packs, tt.want.Packs)
+			}
+			for i := range got.Packs {
+				if got.Packs[i] != tt.want.Packs[i] {
+					t.Errorf("packAvailability(%d).Packs[%d] = %v, want %v", tt.units, i, got.Packs[i], tt.want.Packs[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// PRESCRIPTIONS
+// A prescription item allows 1 + refills fills of up to quantity units each.
+// Checkout refuses requires_prescription products without a prescription
+// item that still has fills left.
+// =====================================================
+
+func formatPrescriptionNo(id int) string {
+	return fmt.Sprintf("RX-%06d", id)
+}
+
+// CreatePrescription records a prescription and the medicines on it
+func CreatePrescription(db *sql.DB, actor models.Actor, req models.CreatePrescriptionRequest) (*models.PrescriptionDTO, error) {
+	req.PrescriberName = strings.TrimSpace(req.PrescriberName)
+	req.PrescriberRegNo = strings.TrimSpace(req.PrescriberRegNo)
+	req.PatientName = strings.TrimSpace(req.PatientName)
+	if req.PrescriberName == "" || req.PrescriberRegNo == "" {
+		return nil, fmt.Errorf("%w: prescriber name and registration number are required", ErrInvalidInput)
+	}
+	issueDate, err := time.Parse("2006-01-02", req.IssueDate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: issueDate must be YYYY-MM-DD", ErrInvalidInput)
+	}
+	if issueDate.After(time.Now()) {
+		return nil, fmt.Errorf("%w: issueDate cannot be in the future", ErrInvalidInput)
+	}
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: prescription has no items", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var customerName string
+	if req.CustomerID != nil {
+		err = tx.QueryRow("SELECT name FROM customer WHERE id = $1 AND deleted = 0", *req.CustomerID).Scan(&customerName)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("customer not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get customer: %w", err)
+		}
+		if req.PatientName == "" {
+			req.PatientName = customerName
+		}
+	}
+	if req.PatientName == "" {
+		return nil, fmt.Errorf("%w: patientName or customerId is required", ErrInvalidInput)
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO prescription (
+			customer_id_fk, patient_name, prescriber_name, prescriber_reg_no,
+			issue_date, notes, image, user_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, req.CustomerID, req.PatientName, req.PrescriberName, req.PrescriberRegNo,
+		issueDate, nullString(req.Notes), nullString(req.Image), nullInt(actor.UserID),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert prescription: %w", err)
+	}
+
+	seen := make(map[int]bool)
+	for _, item := range req.Items {
+		productID, err := parseProductID(item.ProductID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid product ID %q", ErrInvalidInput, item.ProductID)
+		}
+		if item.Quantity <= 0 || item.Refills < 0 {
+			return nil, fmt.Errorf("%w: quantity must be greater than zero and refills cannot be negative", ErrInvalidInput)
+		}
+		if seen[productID] {
+			return nil, fmt.Errorf("%w: %s is prescribed more than once", ErrInvalidInput, item.ProductID)
+		}
+		seen[productID] = true
+
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM product WHERE id = $1 AND deleted = 0)", productID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to check product: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("product %s not found", item.ProductID)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO prescription_item (prescription_id, product_id, dosage, quantity, refills)
+			VALUES ($1, $2, $3, $4, $5)
+		`, id, productID, nullString(strings.TrimSpace(item.Dosage)), item.Quantity, item.Refills)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert prescription item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetPrescriptionByID(db, id)
+}
+
+// prescriptionColumns selects a prescription for scanPrescription
+const prescriptionColumns = `
+	SELECT rx.id, rx.customer_id_fk, c.name, rx.patient_name, rx.prescriber_name, rx.prescriber_reg_no,
+	       rx.issue_date, rx.notes, COALESCE(rx.image, '') <> '', rx.created_at
+	FROM prescription rx
+	LEFT JOIN customer c ON rx.customer_id_fk = c.id`
+
+func scanPrescription(row rowScanner) (*models.PrescriptionDTO, error) {
+	var rx models.PrescriptionDTO
+	var customerID sql.NullInt64
+	var customerName, notes sql.NullString
+	var issueDate, createdAt time.Time
+
+	if err := row.Scan(&rx.ID, &customerID, &customerName, &rx.PatientName, &rx.PrescriberName,
+		&rx.PrescriberRegNo, &issueDate, &notes, &rx.HasImage, &createdAt); err != nil {
+		return nil, err
+	}
+
+	rx.PrescriptionNo = formatPrescriptionNo(rx.ID)
+	if customerID.Valid {
+		cid := int(customerID.Int64)
+		rx.CustomerID = &cid
+	}
+	rx.CustomerName = customerName.String
+	rx.Notes = notes.String
+	rx.IssueDate = issueDate.Format("2006-01-02")
+	rx.CreatedAt = createdAt.Format(time.RFC3339)
+	rx.Items = []models.PrescriptionItemDTO{}
+	return &rx, nil
+}
+
+// attachPrescriptionItems loads the items of the given prescriptions in one query
+func attachPrescriptionItems(db *sql.DB, prescriptions []*models.PrescriptionDTO) error {
+	if len(prescriptions) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.PrescriptionDTO, len(prescriptions))
+	ids := make([]int64, len(prescriptions))
+	for i, rx := range prescriptions {
+		byID[rx.ID] = rx
+		ids[i] = int64(rx.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT pi.prescription_id, pi.id, pi.product_id, p.product_name, COALESCE(p.strength, ''),
+		       COALESCE(pi.dosage, ''), pi.quantity, pi.refills, pi.fills_dispensed
+		FROM prescription_item pi
+		JOIN product p ON pi.product_id = p.id
+		WHERE pi.prescription_id = ANY($1)
+		ORDER BY pi.prescription_id, pi.id
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query prescription items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.PrescriptionItemDTO
+		var prescriptionID, productID int
+		if err := rows.Scan(&prescriptionID, &item.ID, &productID, &item.ProductName, &item.Strength,
+			&item.Dosage, &item.Quantity, &item.Refills, &item.FillsDispensed); err != nil {
+			return fmt.Errorf("failed to scan prescription item: %w", err)
+		}
+		item.ProductID = fmt.Sprintf("prod_%03d", productID)
+		item.RemainingFills = max(item.Refills+1-item.FillsDispensed, 0)
+		if rx := byID[prescriptionID]; rx != nil {
+			rx.Items = append(rx.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// GetPrescriptions lists prescriptions, newest first. customerID and search
+// (patient, prescriber or registration number) filter the results when set.
+func GetPrescriptions(db *sql.DB, page, limit, customerID int, search string) ([]models.PrescriptionDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if customerID > 0 {
+		args = append(args, customerID)
+		whereClause += fmt.Sprintf(" AND rx.customer_id_fk = $%d", len(args))
+	}
+	if search != "" {
+		args = append(args, "%"+search+"%")
+		whereClause += fmt.Sprintf(" AND (rx.patient_name ILIKE $%d OR rx.prescriber_name ILIKE $%d OR rx.prescriber_reg_no ILIKE $%d)",
+			len(args), len(args), len(args))
+	}
+
+	var totalItems int
+	if err := db.QueryRow("SELECT COUNT(*) FROM prescription rx"+whereClause, args...).Scan(&totalItems); err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("failed to count prescriptions: %w", err)
+	}
+
+	query := prescriptionColumns + whereClause +
+		fmt.Sprintf(" ORDER BY rx.created_at DESC, rx.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	rows, err := db.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("failed to query prescriptions: %w", err)
+	}
+	var list []*models.PrescriptionDTO
+	for rows.Next() {
+		rx, err := scanPrescription(rows)
+		if err != nil {
+			rows.Close()
+			return nil, models.Pagination{}, fmt.Errorf("failed to scan prescription: %w", err)
+		}
+		list = append(list, rx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if err := attachPrescriptionItems(db, list); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	prescriptions := make([]models.PrescriptionDTO, len(list))
+	for i, rx := range list {
+		prescriptions[i] = *rx
+	}
+
+	pagination := models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   (totalItems + limit - 1) / limit,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}
+	return prescriptions, pagination, nil
+}
+
+// GetPrescriptionByID returns a prescription with its scanned image and the
+// invoice lines dispensed against it
+func GetPrescriptionByID(db *sql.DB, id int) (*models.PrescriptionDTO, error) {
+	rx, err := scanPrescription(db.QueryRow(prescriptionColumns+" WHERE rx.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prescription not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prescription: %w", err)
+	}
+	if err := attachPrescriptionItems(db, []*models.PrescriptionDTO{rx}); err != nil {
+		return nil, err
+	}
+
+	if rx.HasImage {
+		if err := db.QueryRow("SELECT image FROM prescription WHERE id = $1", id).Scan(&rx.Image); err != nil {
+			return nil, fmt.Errorf("failed to get prescription image: %w", err)
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT i.id, ii.product_id, p.product_name, ii.pack_type, ii.quantity, COALESCE(ii.units, 0), i.created_at
+		FROM invoice_items ii
+		JOIN prescription_item pi ON ii.prescription_item_id = pi.id
+		JOIN invoice i ON ii.invoice_id = i.id
+		JOIN product p ON ii.product_id = p.id
+		WHERE pi.prescription_id = $1 AND i.deleted = 0
+		ORDER BY i.created_at, ii.id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prescription fills: %w", err)
+	}
+	defer rows.Close()
+
+	rx.Fills = []models.PrescriptionFillDTO{}
+	for rows.Next() {
+		var f models.PrescriptionFillDTO
+		var productID int
+		var dispensedAt time.Time
+		if err := rows.Scan(&f.InvoiceID, &productID, &f.ProductName, &f.PackType, &f.Quantity, &f.Units, &dispensedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prescription fill: %w", err)
+		}
+		f.InvoiceNo = formatInvoiceNo(f.InvoiceID)
+		f.ProductID = fmt.Sprintf("prod_%03d", productID)
+		f.DispensedAt = dispensedAt.Format(time.RFC3339)
+		rx.Fills = append(rx.Fills, f)
+	}
+	return rx, rows.Err()
+}
+
+// rxFill is one prescription item being filled by a sale
+type rxFill struct {
+	itemID   int
+	quantity int // units allowed per fill
+	units    int // units in this sale
+}
+
+// dispensePrescriptions links cart lines to the prescription items they fill
+// and uses up one fill per item. Prescription-only products must name a
+// prescription; several lines of the same product share one fill.
+func dispensePrescriptions(tx *sql.Tx, customerID *int, lines []saleLine) error {
+	type key struct{ prescriptionID, productID int }
+	fills := make(map[key]*rxFill)
+
+	for i := range lines {
+		line := &lines[i]
+		if line.prescriptionID == 0 {
+			if line.requiresPrescription {
+				return fmt.Errorf("%w: %s is sold on prescription only", ErrPrescription, line.productName)
+			}
+			continue
+		}
+
+		k := key{line.prescriptionID, line.productID}
+		f := fills[k]
+		if f == nil {
+			var rxCustomer sql.NullInt64
+			err := tx.QueryRow("SELECT customer_id_fk FROM prescription WHERE id = $1", line.prescriptionID).Scan(&rxCustomer)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("prescription %s not found", formatPrescriptionNo(line.prescriptionID))
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get prescription: %w", err)
+			}
+			if rxCustomer.Valid && customerID != nil && int64(*customerID) != rxCustomer.Int64 {
+				return fmt.Errorf("%w: prescription %s belongs to another customer", ErrInvalidInput, formatPrescriptionNo(line.prescriptionID))
+			}
+
+			var refills, dispensed int
+			f = &rxFill{}
+			err = tx.QueryRow(`
+				SELECT id, quantity, refills, fills_dispensed
+				FROM prescription_item
+				WHERE prescription_id = $1 AND product_id = $2
+				FOR UPDATE
+			`, line.prescriptionID, line.productID).Scan(&f.itemID, &f.quantity, &refills, &dispensed)
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: prescription %s does not include %s", ErrInvalidInput, formatPrescriptionNo(line.prescriptionID), line.productName)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to get prescription item: %w", err)
+			}
+			if dispensed >= refills+1 {
+				return fmt.Errorf("%w: prescription %s has no fills left for %s", ErrPrescription, formatPrescriptionNo(line.prescriptionID), line.productName)
+			}
+			fills[k] = f
+		}
+
+		f.units += line.units
+		if f.units > f.quantity {
+			return fmt.Errorf("%w: %d units of %s exceed the %d prescribed per fill", ErrInvalidInput, f.units, line.productName, f.quantity)
+		}
+		line.prescriptionItemID = f.itemID
+	}
+
+	for _, f := range fills {
+		if _, err := tx.Exec("UPDATE prescription_item SET fills_dispensed = fills_dispensed + 1 WHERE id = $1", f.itemID); err != nil {
+			return fmt.Errorf("failed to update prescription fills: %w", err)
+		}
+	}
+	return nil
+}
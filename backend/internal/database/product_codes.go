@@ -0,0 +1,372 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// PRODUCT CODES
+// Codes are built from a pattern such as "{category}-{generic}-{seq}".
+// Tokens take an optional length, e.g. "{name:5}" or "{seq:6}":
+//   {category} first letters of the category (3)
+//   {generic}  generic name abbreviation, or the product name without one (3)
+//   {name}     first letters of the product name (4)
+//   {strength} strength without spaces or punctuation, e.g. 500MG
+//   {seq}      running counter per distinct prefix, zero padded (4)
+// Empty tokens drop out together with their separator.
+// =====================================================
+
+// DefaultProductCodePattern is used when PRODUCT_CODE_PATTERN is not set
+const DefaultProductCodePattern = "{category}-{generic}-{seq}"
+
+// seqMarker stands in for the counter in a rendered code prefix. Patterns
+// cannot contain literal braces, so it never clashes with real text.
+const seqMarker = "{seq}"
+
+var productCodeTokenWidths = map[string]int{
+	"category": 3,
+	"generic":  3,
+	"name":     4,
+	"strength": 0,
+	"seq":      4,
+}
+
+// codeSegment is either literal text or a token of a product code pattern
+type codeSegment struct {
+	literal string
+	token   string
+	width   int
+}
+
+var productCodePattern, _ = parseProductCodePattern(DefaultProductCodePattern)
+
+// SetProductCodePattern sets the pattern used for new product codes
+func SetProductCodePattern(pattern string) error {
+	segments, err := parseProductCodePattern(pattern)
+	if err != nil {
+		return err
+	}
+	productCodePattern = segments
+	return nil
+}
+
+func parseProductCodePattern(pattern string) ([]codeSegment, error) {
+	var segments []codeSegment
+	hasSeq, hasName := false, false
+
+	rest := pattern
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			segments = append(segments, codeSegment{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return nil, fmt.Errorf("product code pattern %q has an unmatched '}'", pattern)
+		}
+		if open > 0 {
+			segments = append(segments, codeSegment{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("product code pattern %q has an unclosed '{'", pattern)
+		}
+
+		token, widthStr, hasWidth := strings.Cut(rest[open+1:open+end], ":")
+		width, known := productCodeTokenWidths[token]
+		if !known {
+			return nil, fmt.Errorf("product code pattern %q has unknown token {%s}", pattern, token)
+		}
+		if hasWidth {
+			n, err := strconv.Atoi(widthStr)
+			if err != nil || n < 1 || n > 12 {
+				return nil, fmt.Errorf("product code pattern %q has an invalid length for {%s}", pattern, token)
+			}
+			width = n
+		}
+		if token == "seq" {
+			if hasSeq {
+				return nil, fmt.Errorf("product code pattern %q has more than one {seq}", pattern)
+			}
+			hasSeq = true
+		}
+		hasName = hasName || token == "name"
+
+		segments = append(segments, codeSegment{token: token, width: width})
+		rest = rest[open+end+1:]
+	}
+
+	// Without a counter or the name most products would share one code
+	if !hasSeq && !hasName {
+		return nil, fmt.Errorf("product code pattern %q needs {seq} or {name}", pattern)
+	}
+	return segments, nil
+}
+
+// productCodeInput holds the product fields a code is built from
+type productCodeInput struct {
+	name     string
+	strength string
+	category string
+	generic  string
+}
+
+// renderProductCodePrefix fills in every token except the counter, which is
+// left as seqMarker. It returns the counter width, 0 when there is none.
+func renderProductCodePrefix(in productCodeInput) (string, int) {
+	var b strings.Builder
+	seqWidth := 0
+	for _, seg := range productCodePattern {
+		switch seg.token {
+		case "":
+			b.WriteString(seg.literal)
+		case "category":
+			b.WriteString(abbreviate(in.category, seg.width))
+		case "generic":
+			generic := in.generic
+			if strings.TrimSpace(generic) == "" {
+				generic = in.name
+			}
+			b.WriteString(abbreviateWords(generic, seg.width))
+		case "name":
+			b.WriteString(abbreviate(in.name, seg.width))
+		case "strength":
+			b.WriteString(abbreviate(in.strength, seg.width))
+		case "seq":
+			b.WriteString(seqMarker)
+			seqWidth = seg.width
+		}
+	}
+	return collapseSeparators(b.String()), seqWidth
+}
+
+// abbreviate keeps the first n letters and digits of s (all when n is 0),
+// uppercased. Combining marks stay with their letter, so scripts such as
+// Bangla are never cut in the middle of a character.
+func abbreviate(s string, n int) string {
+	var b strings.Builder
+	count := 0
+	kept := false
+	for _, r := range s {
+		if unicode.Is(unicode.M, r) {
+			if kept {
+				b.WriteRune(r)
+			}
+			continue
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			kept = false
+			continue
+		}
+		if n > 0 && count == n {
+			break
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		count++
+		kept = true
+	}
+	return b.String()
+}
+
+// abbreviateWords uses the initials of multi-word names
+// ("Amoxicillin Clavulanic Acid" -> "ACA") and the first letters otherwise
+func abbreviateWords(s string, n int) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.M, r)
+	})
+	if len(words) < 2 {
+		return abbreviate(s, n)
+	}
+
+	var b strings.Builder
+	for i, word := range words {
+		if n > 0 && i == n {
+			break
+		}
+		b.WriteString(abbreviate(word, 1))
+	}
+	return b.String()
+}
+
+// collapseSeparators drops the separators left around empty tokens
+func collapseSeparators(s string) string {
+	isSep := func(r rune) bool { return strings.ContainsRune("-_/. ", r) }
+
+	var b strings.Builder
+	var prev rune
+	for _, r := range s {
+		if isSep(r) && (b.Len() == 0 || isSep(prev)) {
+			continue
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return strings.TrimRightFunc(b.String(), isSep)
+}
+
+// nextProductCode builds the next free code for a product. The counter for the
+// code's prefix is incremented in the same transaction; codes that are taken
+// already (e.g. set by hand) are skipped.
+func nextProductCode(tx *sql.Tx, productID int, in productCodeInput) (string, error) {
+	prefix, seqWidth := renderProductCodePrefix(in)
+
+	for attempt := 1; attempt <= 100; attempt++ {
+		code := prefix
+		if seqWidth > 0 {
+			var n int
+			err := tx.QueryRow(`
+				INSERT INTO product_code_counter (prefix, last_value) VALUES ($1, 1)
+				ON CONFLICT (prefix) DO UPDATE
+				SET last_value = product_code_counter.last_value + 1, updated_at = NOW()
+				RETURNING last_value
+			`, prefix).Scan(&n)
+			if err != nil {
+				return "", fmt.Errorf("failed to increment product code counter: %w", err)
+			}
+			code = strings.Replace(prefix, seqMarker, fmt.Sprintf("%0*d", seqWidth, n), 1)
+		} else if attempt > 1 {
+			code = fmt.Sprintf("%s-%d", prefix, attempt)
+		}
+
+		var taken bool
+		err := tx.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM product WHERE product_code = $1 AND deleted = 0 AND id <> $2)",
+			code, productID,
+		).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check product code: %w", err)
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", fmt.Errorf("failed to find a free product code for %q", in.name)
+}
+
+// RegenerateProductCodes assigns new codes from the current pattern, in
+// product ID order. Without product IDs every live product is renumbered and
+// the counters start again from 1; OnlyMissing limits it to products that
+// have no code yet.
+func RegenerateProductCodes(db *sql.DB, actor models.Actor, req models.RegenerateProductCodesRequest) ([]models.ProductCodeChange, error) {
+	ids := make([]int64, 0, len(req.ProductIDs))
+	seen := make(map[int]bool)
+	for _, idStr := range req.ProductIDs {
+		id, err := parseProductID(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid product ID %q", ErrInvalidInput, idStr)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, int64(id))
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	renumberAll := len(ids) == 0 && !req.OnlyMissing
+	if renumberAll {
+		// Keep new products from taking numbers while the counters restart
+		if _, err := tx.Exec("LOCK TABLE product_code_counter IN EXCLUSIVE MODE"); err != nil {
+			return nil, fmt.Errorf("failed to lock product code counters: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM product_code_counter"); err != nil {
+			return nil, fmt.Errorf("failed to reset product code counters: %w", err)
+		}
+	}
+
+	query := `
+		SELECT p.id, p.product_code, p.product_name, COALESCE(p.strength, ''),
+		       COALESCE(c.category_name, ''), COALESCE(g.generic_name, '')
+		FROM product p
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		WHERE p.deleted = 0
+	`
+	var args []interface{}
+	if len(ids) > 0 {
+		args = append(args, pq.Array(ids))
+		query += fmt.Sprintf(" AND p.id = ANY($%d)", len(args))
+	}
+	if req.OnlyMissing {
+		query += " AND COALESCE(p.product_code, '') = ''"
+	}
+	query += " ORDER BY p.id FOR UPDATE OF p"
+
+	type target struct {
+		id      int
+		oldCode sql.NullString
+		input   productCodeInput
+	}
+	var targets []target
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products: %w", err)
+	}
+	for rows.Next() {
+		var t target
+		if err := rows.Scan(&t.id, &t.oldCode, &t.input.name, &t.input.strength, &t.input.category, &t.input.generic); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan product: %w", err)
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 && !req.OnlyMissing && len(targets) != len(ids) {
+		return nil, fmt.Errorf("one or more products not found")
+	}
+
+	// Free the old codes first so products can swap codes within one run
+	targetIDs := make([]int64, len(targets))
+	for i, t := range targets {
+		targetIDs[i] = int64(t.id)
+	}
+	if _, err := tx.Exec("UPDATE product SET product_code = NULL WHERE id = ANY($1)", pq.Array(targetIDs)); err != nil {
+		return nil, fmt.Errorf("failed to clear product codes: %w", err)
+	}
+
+	changes := []models.ProductCodeChange{}
+	for _, t := range targets {
+		code, err := nextProductCode(tx, t.id, t.input)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("UPDATE product SET product_code = $1, updated_at = NOW() WHERE id = $2", code, t.id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update product code: %w", err)
+		}
+
+		if t.oldCode.String == code {
+			continue
+		}
+		if err := writeAudit(tx, actor, auditProduct, t.id, auditUpdate, "productCode", auditValue(t.oldCode), &code); err != nil {
+			return nil, err
+		}
+		changes = append(changes, models.ProductCodeChange{
+			ID:      fmt.Sprintf("prod_%03d", t.id),
+			Name:    t.input.name,
+			OldCode: t.oldCode.String,
+			NewCode: code,
+		})
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return changes, nil
+}
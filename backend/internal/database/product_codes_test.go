@@ -0,0 +1,128 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProductCodePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []codeSegment
+		wantErr bool
+	}{
+		{"{category}-{generic}-{seq}", []codeSegment{
+			{token: "category", width: 3}, {literal: "-"}, {token: "generic", width: 3}, {literal: "-"}, {token: "seq", width: 4},
+		}, false},
+		{"RX{name:5}/{seq:6}", []codeSegment{
+			{literal: "RX"}, {token: "name", width: 5}, {literal: "/"}, {token: "seq", width: 6},
+		}, false},
+		{"{name}{strength}", []codeSegment{{token: "name", width: 4}, {token: "strength"}}, false},
+		{"{category}-{seq}-X", []codeSegment{
+			{token: "category", width: 3}, {literal: "-"}, {token: "seq", width: 4}, {literal: "-X"},
+		}, false},
+		{"{category}-{generic}", nil, true}, // neither {seq} nor {name}
+		{"{seq}-{seq}", nil, true},
+		{"{colour}-{seq}", nil, true},
+		{"{seq:0}", nil, true},
+		{"{seq:13}", nil, true},
+		{"{seq:x}", nil, true},
+		{"{seq", nil, true},
+		{"seq}", nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := parseProductCodePattern(tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseProductCodePattern(%q) = %v, want an error", tt.pattern, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProductCodePattern(%q) unexpected error: %v", tt.pattern, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProductCodePattern(%q) = %+v, want %+v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAbbreviate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Analgesics", 3, "ANA"},
+		{"Napa Extra", 0, "NAPAEXTRA"},
+		{"500 mg", 0, "500MG"},
+		{"Ab", 4, "AB"},
+		{"", 3, ""},
+		{"Café-Crème", 4, "CAFÉ"},
+		// Vowel signs and the virama are combining marks and stay with
+		// their consonant
+		{"প্যারাসিটামল", 3, "প্যারা"},
+		{"ক্লিনিক", 2, "ক্লি"},
+	}
+	for _, tt := range tests {
+		if got := abbreviate(tt.s, tt.n); got != tt.want {
+			t.Errorf("abbreviate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAbbreviateWords(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"Amoxicillin Clavulanic Acid", 3, "ACA"},
+		{"Amoxicillin + Clavulanic Acid", 2, "AC"},
+		{"Paracetamol", 3, "PAR"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := abbreviateWords(tt.s, tt.n); got != tt.want {
+			t.Errorf("abbreviateWords(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRenderProductCodePrefix(t *testing.T) {
+	saved := productCodePattern
+	defer func() { productCodePattern = saved }()
+
+	tests := []struct {
+		pattern  string
+		in       productCodeInput
+		want     string
+		seqWidth int
+	}{
+		{"{category}-{generic}-{seq}",
+			productCodeInput{name: "Napa", category: "Analgesics", generic: "Paracetamol"}, "ANA-PAR-{seq}", 4},
+		{"{category}-{generic}-{seq}",
+			productCodeInput{name: "Napa", generic: "Paracetamol"}, "PAR-{seq}", 4},
+		{"{category}-{generic}-{seq}",
+			productCodeInput{name: "Napa Extra", category: "Analgesics"}, "ANA-NE-{seq}", 4},
+		{"{name:6}-{strength}",
+			productCodeInput{name: "Seclo 20", strength: "20 mg"}, "SECLO2-20MG", 0},
+		{"{name}-{strength}",
+			productCodeInput{name: "Seclo"}, "SECL", 0},
+	}
+	for _, tt := range tests {
+		segments, err := parseProductCodePattern(tt.pattern)
+		if err != nil {
+			t.Fatalf("parseProductCodePattern(%q): %v", tt.pattern, err)
+		}
+		productCodePattern = segments
+		got, seqWidth := renderProductCodePrefix(tt.in)
+		if got != tt.want || seqWidth != tt.seqWidth {
+			t.Errorf("renderProductCodePrefix(%+v) with %q = %q, %d; want %q, %d",
+				tt.in, tt.pattern, got, seqWidth, tt.want, tt.seqWidth)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"pharmacy-backend/internal/models"
 )
@@ -19,7 +20,9 @@ func GetAllProducts(db *sql.DB) ([]models.ProductResponse, error) {
 			SELECT 
 				product_id,
 				MAX(CASE WHEN pack_type = 'strip' THEN units_per_pack ELSE 0 END) as strip_units,
-				MAX(CASE WHEN pack_type = 'box' THEN units_per_pack ELSE 0 END) as box_units
+				MAX(CASE WHEN pack_type = 'box' THEN units_per_pack ELSE 0 END) as box_units,
+				MAX(CASE WHEN pack_type = 'strip' THEN barcode END) as strip_barcode,
+				MAX(CASE WHEN pack_type = 'box' THEN barcode END) as box_barcode
 			FROM product_packaging
 			GROUP BY product_id
 		),
@@ -78,7 +81,11 @@ func GetAllProducts(db *sql.DB) ([]models.ProductResponse, error) {
 			COALESCE(pps.strip_units, 0) as strip_units,
 			COALESCE(pps.box_units, 0) as box_units,
 			COALESCE(ppp.strip_price, 0) as strip_price,
-			COALESCE(ppp.box_price, 0) as box_price
+			COALESCE(ppp.box_price, 0) as box_price,
+			COALESCE(pps.strip_barcode, '') as strip_barcode,
+			COALESCE(pps.box_barcode, '') as box_barcode,
+			p.requires_prescription,
+			COALESCE(p.schedule, '') as schedule
 		FROM product p
 		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
 		LEFT JOIN rack r ON p.rack_fk_id = r.id
@@ -99,6 +106,7 @@ func GetAllProducts(db *sql.DB) ([]models.ProductResponse, error) {
 	defer rows.Close()
 
 	var products []models.ProductResponse
+	var ids []int
 	for rows.Next() {
 		var p models.ProductResponse
 		var id, srlNo int
@@ -119,6 +127,8 @@ func GetAllProducts(db *sql.DB) ([]models.ProductResponse, error) {
 			&p.BuyingPrice,
 			&stripUnits, &boxUnits,
 			&stripPrice, &boxPrice,
+			&p.PackBarcode.Strip, &p.PackBarcode.Box,
+			&p.RequiresPrescription, &p.Schedule,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan product row: %w", err)
@@ -148,12 +158,26 @@ func GetAllProducts(db *sql.DB) ([]models.ProductResponse, error) {
 		p.ProfitMargin = calculateProfitMargin(p.Price, p.BuyingPrice)
 
 		products = append(products, p)
+		ids = append(ids, id)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating product rows: %w", err)
 	}
 
+	levels, err := productPackagingLevels(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	ingredients, err := productIngredients(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range products {
+		applyPackaging(&products[i], levels[ids[i]])
+		products[i].Ingredients = withIngredients(ingredients[ids[i]])
+	}
+
 	return products, nil
 }
 
@@ -170,7 +194,9 @@ func GetProductResponseByID(db *sql.DB, idStr string) (*models.ProductResponse,
 			SELECT 
 				product_id,
 				MAX(CASE WHEN pack_type = 'strip' THEN units_per_pack ELSE 0 END) as strip_units,
-				MAX(CASE WHEN pack_type = 'box' THEN units_per_pack ELSE 0 END) as box_units
+				MAX(CASE WHEN pack_type = 'box' THEN units_per_pack ELSE 0 END) as box_units,
+				MAX(CASE WHEN pack_type = 'strip' THEN barcode END) as strip_barcode,
+				MAX(CASE WHEN pack_type = 'box' THEN barcode END) as box_barcode
 			FROM product_packaging
 			WHERE product_id = $1
 			GROUP BY product_id
@@ -231,7 +257,11 @@ func GetProductResponseByID(db *sql.DB, idStr string) (*models.ProductResponse,
 			COALESCE(pps.strip_units, 0) as strip_units,
 			COALESCE(pps.box_units, 0) as box_units,
 			COALESCE(ppp.strip_price, 0) as strip_price,
-			COALESCE(ppp.box_price, 0) as box_price
+			COALESCE(ppp.box_price, 0) as box_price,
+			COALESCE(pps.strip_barcode, '') as strip_barcode,
+			COALESCE(pps.box_barcode, '') as box_barcode,
+			p.requires_prescription,
+			COALESCE(p.schedule, '') as schedule
 		FROM product p
 		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
 		LEFT JOIN rack r ON p.rack_fk_id = r.id
@@ -263,6 +293,8 @@ func GetProductResponseByID(db *sql.DB, idStr string) (*models.ProductResponse,
 		&p.BuyingPrice,
 		&stripUnits, &boxUnits,
 		&stripPrice, &boxPrice,
+		&p.PackBarcode.Strip, &p.PackBarcode.Box,
+		&p.RequiresPrescription, &p.Schedule,
 	)
 
 	if err == sql.ErrNoRows {
@@ -282,6 +314,16 @@ func GetProductResponseByID(db *sql.DB, idStr string) (*models.ProductResponse,
 
 	p.PackSize = models.PackSize{Strip: stripUnits, Box: boxUnits}
 	p.PackPrice = models.PackPrice{Strip: stripPrice, Box: boxPrice}
+	levels, err := productPackagingLevels(db, []int{dbID})
+	if err != nil {
+		return nil, err
+	}
+	applyPackaging(&p, levels[dbID])
+	ingredients, err := productIngredients(db, []int{dbID})
+	if err != nil {
+		return nil, err
+	}
+	p.Ingredients = withIngredients(ingredients[dbID])
 	p.StockStatus = calculateStockStatus(p.InStock)
 	p.ProfitMargin = calculateProfitMargin(p.Price, p.BuyingPrice)
 
@@ -289,14 +331,28 @@ func GetProductResponseByID(db *sql.DB, idStr string) (*models.ProductResponse,
 }
 
 // CreateNewProduct creates a new product with all related data
-func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.ProductResponse, error) {
+func CreateNewProduct(db *sql.DB, actor models.Actor, req models.CreateProductRequest) (*models.ProductResponse, error) {
+	if !req.Schedule.Valid() {
+		return nil, fmt.Errorf("%w: unknown schedule %q", ErrInvalidInput, req.Schedule)
+	}
+	if req.Packaging != nil {
+		if err := validatePackLevels(req.Packaging); err != nil {
+			return nil, err
+		}
+	} else if err := validatePackSize(req.PackSize); err != nil {
+		return nil, err
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// 1. Get or create generic name
+	// 1. Get or create generic name; an ingredient list names it if not given
+	if req.GenericName == "" && len(req.Ingredients) > 0 {
+		req.GenericName = ingredientNames(req.Ingredients)
+	}
 	var genericID *int
 	if req.GenericName != "" {
 		id, err := getOrCreateGenericName(tx, req.GenericName)
@@ -336,9 +392,27 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		categoryID = &id
 	}
 
-	// 5. Generate barcode and product code
-	barcode := generateBarcode()
-	productCode := generateProductCode(req.Name, req.Strength)
+	// 5. Validate or generate barcode, then the product code
+	barcode := strings.TrimSpace(req.Barcode)
+	if barcode != "" {
+		if err := checkBarcode(tx, 0, models.PackTypeUnit, barcode); err != nil {
+			return nil, err
+		}
+	} else {
+		barcode, err = generateBarcode(tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	productCode, err := nextProductCode(tx, 0, productCodeInput{
+		name:     req.Name,
+		strength: req.Strength,
+		category: req.Category,
+		generic:  req.GenericName,
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	// 6. Insert product
 	productQuery := `
@@ -347,8 +421,8 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 			strength, manufacture, generic_fk_id, rack_fk_id, 
 			product_type_fk_id, category_fk_id,
 			unit_price, unit_mrp, unit_cost_price, discount_percent,
-			available_stock, total_purchase, total_sold
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, 0, 0)
+			requires_prescription, schedule, available_stock, total_purchase, total_sold
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, 0, 0, 0)
 		RETURNING id
 	`
 
@@ -358,15 +432,24 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		req.Strength, req.Manufacture, genericID, rackID,
 		productTypeID, categoryID,
 		req.Price, req.MRP, req.BuyingPrice, req.Discount,
-		req.InStock,
+		req.RequiresPrescription, nullString(string(req.Schedule)),
 	).Scan(&productID)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert product: %w", err)
 	}
 
-	// 7. Insert packaging
-	if req.PackSize.Strip > 0 || req.PackPrice.Strip > 0 {
+	// 7. Book initial stock as an opening balance (ledger row + OPENING batch)
+	if err := adjustStock(tx, productID, req.InStock, models.AdjustmentOpeningBalance, "", actor.UserID); err != nil {
+		return nil, err
+	}
+
+	// 8. Insert packaging
+	if req.Packaging != nil {
+		if err := setPackaging(tx, productID, req.Packaging); err != nil {
+			return nil, err
+		}
+	} else if req.PackSize.Strip > 0 || req.PackPrice.Strip > 0 {
 		_, err = tx.Exec(`
 			INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price, mrp, cost_price)
 			VALUES ($1, 'strip', $2, $3, $4, $5)
@@ -376,7 +459,7 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		}
 	}
 
-	if req.PackSize.Box > 0 || req.PackPrice.Box > 0 {
+	if req.Packaging == nil && (req.PackSize.Box > 0 || req.PackPrice.Box > 0) {
 		_, err = tx.Exec(`
 			INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price, mrp, cost_price)
 			VALUES ($1, 'box', $2, $3, $4, $5)
@@ -386,7 +469,13 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		}
 	}
 
-	// 8. Create supplier and link if provided
+	if req.Packaging == nil && req.PackBarcode != (models.PackBarcode{}) {
+		if err := setPackBarcodes(tx, productID, req.PackBarcode); err != nil {
+			return nil, err
+		}
+	}
+
+	// 9. Create supplier and link if provided
 	if req.Supplier != "" {
 		supplierID, err := getOrCreateSupplier(tx, req.Supplier, req.SupplierContact)
 		if err != nil {
@@ -401,6 +490,23 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		}
 	}
 
+	// 10. Link active ingredients
+	ingredients := req.Ingredients
+	if ingredients == nil {
+		ingredients = ingredientsFromGeneric(req.GenericName, req.Strength)
+	}
+	if err := setProductIngredients(tx, productID, ingredients); err != nil {
+		return nil, err
+	}
+
+	created, err := productAuditSnapshot(tx, productID)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditProduct, productID, nil, created); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -433,15 +539,27 @@ func CreateNewProduct(db *sql.DB, req models.CreateProductRequest) (*models.Prod
 		SupplierContact: req.SupplierContact,
 		BuyingPrice:     req.BuyingPrice,
 		ProfitMargin:    calculateProfitMargin(req.Price, req.BuyingPrice),
-		PackSize:        req.PackSize,
-		PackPrice:       req.PackPrice,
+
+		RequiresPrescription: req.RequiresPrescription,
+		Schedule:             req.Schedule,
+	}
+
+	levels, err := productPackagingLevels(db, []int{productID})
+	if err != nil {
+		return nil, err
+	}
+	applyPackaging(response, levels[productID])
+	linked, err := productIngredients(db, []int{productID})
+	if err != nil {
+		return nil, err
 	}
+	response.Ingredients = withIngredients(linked[productID])
 
 	return response, nil
 }
 
 // UpdateExistingProduct updates a product and related data
-func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductRequest) (*models.ProductResponse, error) {
+func UpdateExistingProduct(db *sql.DB, actor models.Actor, idStr string, req models.UpdateProductRequest) (*models.ProductResponse, error) {
 	id, err := parseProductID(idStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid product ID: %w", err)
@@ -463,6 +581,11 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 	}
 	defer tx.Rollback()
 
+	before, err := productAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update product fields if provided
 	if req.Name != nil {
 		_, err = tx.Exec("UPDATE product SET product_name = $1, updated_at = NOW() WHERE id = $2", *req.Name, id)
@@ -512,14 +635,33 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 			return nil, err
 		}
 	}
-	if req.InStock != nil {
-		_, err = tx.Exec("UPDATE product SET available_stock = $1, updated_at = NOW() WHERE id = $2", *req.InStock, id)
+	if req.RequiresPrescription != nil {
+		_, err = tx.Exec("UPDATE product SET requires_prescription = $1, updated_at = NOW() WHERE id = $2", *req.RequiresPrescription, id)
 		if err != nil {
 			return nil, err
 		}
 	}
+	if req.Schedule != nil {
+		if err := setProductSchedule(tx, id, *req.Schedule, actor.UserID); err != nil {
+			return nil, err
+		}
+	}
+	if req.InStock != nil {
+		// Stock is never overwritten; the difference is posted as an adjustment
+		note := ""
+		if req.StockNote != nil {
+			note = *req.StockNote
+		}
+		if err := setStockLevel(tx, id, *req.InStock, adjustmentReason(req.StockReason), note, actor.UserID); err != nil {
+			return nil, err
+		}
+	}
 
-	// Update generic name if provided
+	// Update generic name if provided; a new ingredient list renames it
+	if req.GenericName == nil && len(req.Ingredients) > 0 {
+		name := ingredientNames(req.Ingredients)
+		req.GenericName = &name
+	}
 	if req.GenericName != nil && *req.GenericName != "" {
 		genericID, err := getOrCreateGenericName(tx, *req.GenericName)
 		if err != nil {
@@ -571,9 +713,17 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 		}
 	}
 
-	// Update packaging if provided
-	if req.PackSize != nil || req.PackPrice != nil {
+	// Update packaging if provided; a packaging list replaces every level
+	if req.Packaging != nil {
+		if err := setPackaging(tx, id, req.Packaging); err != nil {
+			return nil, err
+		}
+	} else if req.PackSize != nil || req.PackPrice != nil {
 		if req.PackSize != nil && req.PackPrice != nil {
+			if err := validatePackSize(*req.PackSize); err != nil {
+				return nil, err
+			}
+
 			// Update strip packaging
 			_, err = tx.Exec(`
 				INSERT INTO product_packaging (product_id, pack_type, units_per_pack, selling_price, mrp, cost_price)
@@ -598,6 +748,18 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 		}
 	}
 
+	// Update barcodes if provided
+	if req.Barcode != nil {
+		if err := setProductBarcode(tx, id, *req.Barcode); err != nil {
+			return nil, err
+		}
+	}
+	if req.PackBarcode != nil && req.Packaging == nil {
+		if err := setPackBarcodes(tx, id, *req.PackBarcode); err != nil {
+			return nil, err
+		}
+	}
+
 	// Update supplier if provided
 	if req.Supplier != nil && *req.Supplier != "" {
 		contact := ""
@@ -629,6 +791,35 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 		}
 	}
 
+	// Update active ingredients; a new generic name or strength re-reads them
+	if req.Ingredients != nil {
+		if err := setProductIngredients(tx, id, req.Ingredients); err != nil {
+			return nil, err
+		}
+	} else if req.GenericName != nil || req.Strength != nil {
+		var generic, strength string
+		err := tx.QueryRow(`
+			SELECT COALESCE(g.generic_name, ''), COALESCE(p.strength, '')
+			FROM product p
+			LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+			WHERE p.id = $1
+		`, id).Scan(&generic, &strength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get generic name: %w", err)
+		}
+		if err := setProductIngredients(tx, id, ingredientsFromGeneric(generic, strength)); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := productAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditProduct, id, before, after); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -638,7 +829,7 @@ func UpdateExistingProduct(db *sql.DB, idStr string, req models.UpdateProductReq
 }
 
 // DeleteProduct soft deletes a product
-func DeleteProduct(db *sql.DB, idStr string) error {
+func DeleteProduct(db *sql.DB, actor models.Actor, idStr string) error {
 	id, err := parseProductID(idStr)
 	if err != nil {
 		return fmt.Errorf("invalid product ID: %w", err)
@@ -654,12 +845,32 @@ func DeleteProduct(db *sql.DB, idStr string) error {
 		return fmt.Errorf("product not found")
 	}
 
-	_, err = db.Exec("UPDATE product SET deleted = 1, deleted_at = NOW() WHERE id = $1", id)
+	return softDeleteProduct(db, actor, id)
+}
+
+// softDeleteProduct marks a product deleted and records it in the audit log
+func softDeleteProduct(db *sql.DB, actor models.Actor, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRow(
+		"UPDATE product SET deleted = 1, deleted_at = NOW() WHERE id = $1 AND deleted = 0 RETURNING product_name", id,
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
 	if err != nil {
 		return fmt.Errorf("failed to delete product: %w", err)
 	}
+	if err := writeAudit(tx, actor, auditProduct, id, auditDelete, "", &name, nil); err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit()
 }
 
 // =====================================================
@@ -720,30 +931,6 @@ func getOrCreateSupplier(tx *sql.Tx, name, contact string) (int, error) {
 	return id, err
 }
 
-func generateBarcode() string {
-	// Generate a simple barcode (in production, use proper barcode generation)
-	return fmt.Sprintf("890%010d", generateRandomNumber())
-}
-
-func generateProductCode(name, strength string) string {
-	// Generate product code from name and strength
-	code := ""
-	if len(name) >= 4 {
-		code = name[:4]
-	} else {
-		code = name
-	}
-	if strength != "" {
-		code += strength[:min(len(strength), 3)]
-	}
-	return code
-}
-
-func generateRandomNumber() int64 {
-	// In production, use crypto/rand or time-based unique ID
-	return 1234567890
-}
-
 func min(a, b int) int {
 	if a < b {
 		return a
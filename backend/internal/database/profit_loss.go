@@ -0,0 +1,496 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// PROFIT AND LOSS
+// Revenue comes from invoice lines net of discounts and VAT, cost of goods
+// sold from the batch costs recorded when the units were sold. Lines sold
+// before batch tracking fall back to the product's unit cost price.
+// =====================================================
+
+// Breakdown keys for rows without a category, generic or supplier
+const (
+	plNoCategory = "Uncategorized"
+	plNoGeneric  = "No generic"
+	plNoSupplier = "Unassigned"
+)
+
+// writeOffDamagedReturn is the write-off reason for damaged customer returns
+const writeOffDamagedReturn = "damaged_return"
+
+// plFact is one amount placed on every breakdown of the report
+type plFact struct {
+	day, category, generic, supplier string
+	sales, returns, cogs             float64
+	writeOffs, supplierReturnLoss    float64
+}
+
+// plBreakdown sums facts by one key
+type plBreakdown map[string]*models.ProfitLossLineDTO
+
+func (b plBreakdown) add(key string, f plFact) {
+	line, ok := b[key]
+	if !ok {
+		line = &models.ProfitLossLineDTO{Key: key}
+		b[key] = line
+	}
+	addProfitLoss(line, f)
+}
+
+func addProfitLoss(line *models.ProfitLossLineDTO, f plFact) {
+	line.Sales += f.sales
+	line.Returns += f.returns
+	line.COGS += f.cogs
+	line.WriteOffs += f.writeOffs
+	line.SupplierReturnLoss += f.supplierReturnLoss
+}
+
+// finishProfitLoss rounds the sums and works out the derived figures
+func finishProfitLoss(line *models.ProfitLossLineDTO) {
+	line.Sales = roundMoney(line.Sales)
+	line.Returns = roundMoney(line.Returns)
+	line.COGS = roundMoney(line.COGS)
+	line.WriteOffs = roundMoney(line.WriteOffs)
+	line.SupplierReturnLoss = roundMoney(line.SupplierReturnLoss)
+
+	line.NetRevenue = roundMoney(line.Sales - line.Returns)
+	line.GrossProfit = roundMoney(line.NetRevenue - line.COGS)
+	line.NetProfit = roundMoney(line.GrossProfit - line.WriteOffs - line.SupplierReturnLoss)
+	if line.NetRevenue != 0 {
+		line.GrossMargin = roundMoney(line.GrossProfit / line.NetRevenue * 100)
+		line.NetMargin = roundMoney(line.NetProfit / line.NetRevenue * 100)
+	}
+}
+
+// sorted returns the breakdown by key (byKey) or by net revenue, largest first
+func (b plBreakdown) sorted(byKey bool) []models.ProfitLossLineDTO {
+	lines := make([]models.ProfitLossLineDTO, 0, len(b))
+	for _, line := range b {
+		finishProfitLoss(line)
+		lines = append(lines, *line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if !byKey && lines[i].NetRevenue != lines[j].NetRevenue {
+			return lines[i].NetRevenue > lines[j].NetRevenue
+		}
+		return lines[i].Key < lines[j].Key
+	})
+	return lines
+}
+
+// profitLossBuilder collects facts into the summary and the breakdowns
+type profitLossBuilder struct {
+	summary    models.ProfitLossLineDTO
+	writeOffs  map[string]float64
+	byDay      plBreakdown
+	byCategory plBreakdown
+	byGeneric  plBreakdown
+	bySupplier plBreakdown
+}
+
+func newProfitLossBuilder() *profitLossBuilder {
+	return &profitLossBuilder{
+		writeOffs:  map[string]float64{},
+		byDay:      plBreakdown{},
+		byCategory: plBreakdown{},
+		byGeneric:  plBreakdown{},
+		bySupplier: plBreakdown{},
+	}
+}
+
+func (b *profitLossBuilder) add(f plFact) {
+	if f.category == "" {
+		f.category = plNoCategory
+	}
+	if f.generic == "" {
+		f.generic = plNoGeneric
+	}
+	if f.supplier == "" {
+		f.supplier = plNoSupplier
+	}
+	addProfitLoss(&b.summary, f)
+	b.byDay.add(f.day, f)
+	b.byCategory.add(f.category, f)
+	b.byGeneric.add(f.generic, f)
+	b.bySupplier.add(f.supplier, f)
+}
+
+func (b *profitLossBuilder) addWriteOff(reason string, f plFact) {
+	b.writeOffs[reason] += f.writeOffs
+	b.add(f)
+}
+
+// plAllocation is the part of a sold or returned line that came from one batch
+type plAllocation struct {
+	quantity  int
+	costPrice float64
+	supplier  string
+}
+
+// supplierLabel names a batch supplier, falling back to its ID
+func supplierLabel(id sql.NullInt64, name string) string {
+	if name != "" {
+		return name
+	}
+	if id.Valid {
+		return fmt.Sprintf("SUP-%03d", id.Int64)
+	}
+	return ""
+}
+
+// queryPLAllocations loads batch allocations keyed by invoice or return item
+func queryPLAllocations(db *sql.DB, query string, args ...interface{}) (map[int][]plAllocation, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch allocations: %w", err)
+	}
+	defer rows.Close()
+
+	allocations := map[int][]plAllocation{}
+	for rows.Next() {
+		var itemID int
+		var a plAllocation
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&itemID, &a.quantity, &a.costPrice, &supplierID, &a.supplier); err != nil {
+			return nil, fmt.Errorf("failed to scan batch allocation: %w", err)
+		}
+		a.supplier = supplierLabel(supplierID, a.supplier)
+		allocations[itemID] = append(allocations[itemID], a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batch allocations: %w", err)
+	}
+	return allocations, nil
+}
+
+// splitBySupplier spreads a line's revenue over the batches its units came
+// from and costs each batch at its own price. Units without a batch are
+// costed at fallbackCost.
+func splitBySupplier(base plFact, revenue float64, units int, allocations []plAllocation, fallbackCost float64) []plFact {
+	if units <= 0 {
+		for _, a := range allocations {
+			units += a.quantity
+		}
+	}
+
+	var facts []plFact
+	remaining := units
+	for _, a := range allocations {
+		f := base
+		f.supplier = a.supplier
+		if units > 0 {
+			f.sales = revenue * float64(a.quantity) / float64(units)
+		}
+		f.cogs = float64(a.quantity) * a.costPrice
+		facts = append(facts, f)
+		remaining -= a.quantity
+	}
+
+	if remaining > 0 || len(facts) == 0 {
+		f := base
+		f.supplier = ""
+		if units > 0 {
+			f.sales = revenue * float64(max(remaining, 0)) / float64(units)
+		} else {
+			f.sales = revenue
+		}
+		f.cogs = float64(max(remaining, 0)) * fallbackCost
+		facts = append(facts, f)
+	}
+	return facts
+}
+
+// GetProfitLossReport builds the profit and loss statement for the days from
+// through to (YYYY-MM-DD, inclusive)
+func GetProfitLossReport(db *sql.DB, from, to string) (*models.ProfitLossReportDTO, error) {
+	b := newProfitLossBuilder()
+
+	if err := addSalesToProfitLoss(db, b, from, to); err != nil {
+		return nil, err
+	}
+	if err := addReturnsToProfitLoss(db, b, from, to); err != nil {
+		return nil, err
+	}
+	if err := addWriteOffsToProfitLoss(db, b, from, to); err != nil {
+		return nil, err
+	}
+	if err := addCompanyReturnsToProfitLoss(db, b, from, to); err != nil {
+		return nil, err
+	}
+
+	finishProfitLoss(&b.summary)
+	for reason, amount := range b.writeOffs {
+		b.writeOffs[reason] = roundMoney(amount)
+	}
+
+	return &models.ProfitLossReportDTO{
+		From:              from,
+		To:                to,
+		Summary:           b.summary,
+		WriteOffsByReason: b.writeOffs,
+		ByDay:             b.byDay.sorted(true),
+		ByCategory:        b.byCategory.sorted(false),
+		ByGeneric:         b.byGeneric.sorted(false),
+		BySupplier:        b.bySupplier.sorted(false),
+	}, nil
+}
+
+// addSalesToProfitLoss adds invoice lines. The invoice-level discount is
+// spread over the lines in proportion to their net amounts.
+func addSalesToProfitLoss(db *sql.DB, b *profitLossBuilder, from, to string) error {
+	allocations, err := queryPLAllocations(db, `
+		SELECT iib.invoice_item_id, iib.quantity, COALESCE(iib.cost_price, 0), bt.supplier_id, COALESCE(s.name, '')
+		FROM invoice_item_batches iib
+		JOIN invoice_items ii ON iib.invoice_item_id = ii.id
+		JOIN invoice i ON ii.invoice_id = i.id
+		LEFT JOIN product_batch bt ON iib.batch_fk_id = bt.id
+		LEFT JOIN supplier s ON bt.supplier_id = s.id
+		WHERE i.deleted = 0 AND i.created_at >= $1::date AND i.created_at < $2::date + 1
+		ORDER BY iib.id
+	`, from, to)
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Query(`
+		SELECT i.id, to_char(i.created_at, 'YYYY-MM-DD'), COALESCE(i.total, 0) - COALESCE(i.vat, 0),
+		       ii.id, COALESCE(ii.total, 0) - COALESCE(ii.vat, 0),
+		       CASE WHEN ii.units > 0 THEN ii.units
+		            ELSE ROUND(ii.quantity * COALESCE(pp.units_per_pack, 1))::int END,
+		       COALESCE(p.unit_cost_price, 0), COALESCE(c.category_name, ''), COALESCE(g.generic_name, '')
+		FROM invoice i
+		JOIN invoice_items ii ON ii.invoice_id = i.id
+		JOIN product p ON ii.product_id = p.id
+		LEFT JOIN product_packaging pp ON pp.product_id = ii.product_id AND pp.pack_type = ii.pack_type
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		WHERE i.deleted = 0 AND i.created_at >= $1::date AND i.created_at < $2::date + 1
+		ORDER BY i.id, ii.id
+	`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query sales: %w", err)
+	}
+	defer rows.Close()
+
+	type saleLine struct {
+		itemID   int
+		lineNet  float64
+		units    int
+		unitCost float64
+		base     plFact
+	}
+	type saleInvoice struct {
+		net   float64
+		lines []saleLine
+	}
+
+	var invoices []*saleInvoice
+	current, currentID := (*saleInvoice)(nil), 0
+	for rows.Next() {
+		var invoiceID int
+		var invoiceNet float64
+		var l saleLine
+		if err := rows.Scan(&invoiceID, &l.base.day, &invoiceNet, &l.itemID, &l.lineNet, &l.units,
+			&l.unitCost, &l.base.category, &l.base.generic); err != nil {
+			return fmt.Errorf("failed to scan sale: %w", err)
+		}
+		if current == nil || invoiceID != currentID {
+			current, currentID = &saleInvoice{net: invoiceNet}, invoiceID
+			invoices = append(invoices, current)
+		}
+		current.lines = append(current.lines, l)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating sales: %w", err)
+	}
+
+	for _, inv := range invoices {
+		// Invoices from before per-line totals were stored are split by units
+		weight := func(l saleLine) float64 { return l.lineNet }
+		var total float64
+		for _, l := range inv.lines {
+			total += l.lineNet
+		}
+		if total == 0 {
+			weight = func(l saleLine) float64 { return float64(l.units) }
+			for _, l := range inv.lines {
+				total += float64(l.units)
+			}
+		}
+
+		for _, l := range inv.lines {
+			revenue := 0.0
+			if total != 0 {
+				revenue = inv.net * weight(l) / total
+			}
+			for _, f := range splitBySupplier(l.base, revenue, l.units, allocations[l.itemID], l.unitCost) {
+				b.add(f)
+			}
+		}
+	}
+	return nil
+}
+
+// addReturnsToProfitLoss takes customer returns off revenue and cost of goods
+// sold on the day of the return. The cost of damaged units, which are not
+// restocked, is a write-off.
+func addReturnsToProfitLoss(db *sql.DB, b *profitLossBuilder, from, to string) error {
+	allocations, err := queryPLAllocations(db, `
+		SELECT crib.return_item_id, crib.quantity, COALESCE(crib.cost_price, 0), bt.supplier_id, COALESCE(s.name, '')
+		FROM customer_return_item_batches crib
+		JOIN customer_return_items cri ON crib.return_item_id = cri.id
+		JOIN customer_return cr ON cri.return_id = cr.id
+		LEFT JOIN product_batch bt ON crib.batch_fk_id = bt.id
+		LEFT JOIN supplier s ON bt.supplier_id = s.id
+		WHERE cr.created_at >= $1::date AND cr.created_at < $2::date + 1
+		ORDER BY crib.id
+	`, from, to)
+	if err != nil {
+		return err
+	}
+
+	// A return is worth its share of the line's net amount after the
+	// invoice-level discount, the same way sales are valued
+	rows, err := db.Query(`
+		SELECT cri.id, to_char(cr.created_at, 'YYYY-MM-DD'), cri.units, COALESCE(cri.damaged, false),
+		       COALESCE(ii.total, 0) - COALESCE(ii.vat, 0), ii.units,
+		       COALESCE(i.total, 0) - COALESCE(i.vat, 0),
+		       (SELECT SUM(COALESCE(x.total, 0) - COALESCE(x.vat, 0)) FROM invoice_items x WHERE x.invoice_id = i.id),
+		       COALESCE(p.unit_cost_price, 0), COALESCE(c.category_name, ''), COALESCE(g.generic_name, '')
+		FROM customer_return cr
+		JOIN customer_return_items cri ON cri.return_id = cr.id
+		JOIN invoice_items ii ON cri.invoice_item_id = ii.id
+		JOIN invoice i ON ii.invoice_id = i.id
+		JOIN product p ON cri.product_id = p.id
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		WHERE i.deleted = 0 AND cr.created_at >= $1::date AND cr.created_at < $2::date + 1
+		ORDER BY cri.id
+	`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query customer returns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID, units, soldUnits int
+		var damaged bool
+		var lineNet, invoiceNet, linesNet, unitCost float64
+		var base plFact
+		if err := rows.Scan(&itemID, &base.day, &units, &damaged, &lineNet, &soldUnits,
+			&invoiceNet, &linesNet, &unitCost, &base.category, &base.generic); err != nil {
+			return fmt.Errorf("failed to scan customer return: %w", err)
+		}
+
+		value := 0.0
+		if soldUnits > 0 && linesNet != 0 {
+			value = lineNet * invoiceNet / linesNet * float64(units) / float64(soldUnits)
+		}
+
+		for _, f := range splitBySupplier(base, value, units, allocations[itemID], unitCost) {
+			f.returns, f.sales = f.sales, 0
+			f.cogs = -f.cogs
+			if damaged {
+				f.writeOffs = -f.cogs
+				b.addWriteOff(writeOffDamagedReturn, f)
+			} else {
+				b.add(f)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating customer returns: %w", err)
+	}
+	return nil
+}
+
+// addWriteOffsToProfitLoss adds stock written off by adjustments and from
+// quarantined batches
+func addWriteOffsToProfitLoss(db *sql.DB, b *profitLossBuilder, from, to string) error {
+	rows, err := db.Query(`
+		SELECT to_char(w.created_at, 'YYYY-MM-DD'), w.reason, COALESCE(w.amount, 0),
+		       COALESCE(c.category_name, ''), COALESCE(g.generic_name, ''), bt.supplier_id, COALESCE(s.name, '')
+		FROM stock_write_off w
+		JOIN product p ON w.product_id = p.id
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		LEFT JOIN product_batch bt ON w.batch_fk_id = bt.id
+		LEFT JOIN supplier s ON bt.supplier_id = s.id
+		WHERE w.created_at >= $1::date AND w.created_at < $2::date + 1
+	`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query write-offs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f plFact
+		var reason string
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&f.day, &reason, &f.writeOffs, &f.category, &f.generic, &supplierID, &f.supplier); err != nil {
+			return fmt.Errorf("failed to scan write-off: %w", err)
+		}
+		f.supplier = supplierLabel(supplierID, f.supplier)
+		b.addWriteOff(reason, f)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating write-offs: %w", err)
+	}
+	return nil
+}
+
+// addCompanyReturnsToProfitLoss adds the credit a supplier did not give for
+// goods sent back, on the day the return was settled. The shortfall is spread
+// over the returned lines by their expected amounts.
+func addCompanyReturnsToProfitLoss(db *sql.DB, b *profitLossBuilder, from, to string) error {
+	rows, err := db.Query(`
+		SELECT to_char(cr.settled_at, 'YYYY-MM-DD'),
+		       COALESCE(cr.expected_credit, 0) - COALESCE(cr.settled_amount, 0),
+		       COALESCE(cri.amount, 0),
+		       SUM(COALESCE(cri.amount, 0)) OVER (PARTITION BY cr.id),
+		       COUNT(*) OVER (PARTITION BY cr.id),
+		       cr.supplier_id, COALESCE(s.name, ''),
+		       COALESCE(c.category_name, ''), COALESCE(g.generic_name, '')
+		FROM company_return cr
+		JOIN company_return_items cri ON cri.return_id = cr.id
+		JOIN product p ON cri.product_id = p.id
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		LEFT JOIN supplier s ON cr.supplier_id = s.id
+		WHERE cr.status = 'settled' AND cr.settled_at >= $1::date AND cr.settled_at < $2::date + 1
+		  AND cr.expected_credit <> cr.settled_amount
+	`, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query company returns: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f plFact
+		var shortfall, amount, returnTotal float64
+		var lines int
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&f.day, &shortfall, &amount, &returnTotal, &lines,
+			&supplierID, &f.supplier, &f.category, &f.generic); err != nil {
+			return fmt.Errorf("failed to scan company return: %w", err)
+		}
+		f.supplier = supplierLabel(supplierID, f.supplier)
+		if returnTotal != 0 {
+			f.supplierReturnLoss = shortfall * amount / returnTotal
+		} else {
+			f.supplierReturnLoss = shortfall / float64(lines)
+		}
+		b.add(f)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating company returns: %w", err)
+	}
+	return nil
+}
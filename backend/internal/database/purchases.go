@@ -0,0 +1,416 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// PURCHASES / GOODS RECEIPT (GRN)
+// =====================================================
+
+// CreatePurchase records a supplier delivery. Every line becomes (or tops up) a
+// product_batch, increases available_stock and total_purchase, writes a
+// 'purchase' stock history row and updates the supplier's buying price.
+// Return credit left unapplied for the supplier goes against what is due.
+func CreatePurchase(db *sql.DB, actor models.Actor, supplierID int, req models.CreatePurchaseRequest) (*models.PurchaseDTO, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: purchase has no items", ErrInvalidInput)
+	}
+	if req.PaidAmount < 0 {
+		return nil, fmt.Errorf("%w: paid amount must be positive", ErrInvalidInput)
+	}
+
+	receivedDate := time.Now()
+	if req.ReceivedDate != "" {
+		d, err := time.Parse("2006-01-02", req.ReceivedDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: receivedDate must be YYYY-MM-DD", ErrInvalidInput)
+		}
+		receivedDate = d
+	}
+
+	var orderedDate *time.Time
+	if req.OrderedDate != "" {
+		d, err := time.Parse("2006-01-02", req.OrderedDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: orderedDate must be YYYY-MM-DD", ErrInvalidInput)
+		}
+		if d.After(receivedDate) {
+			return nil, fmt.Errorf("%w: orderedDate cannot be after receivedDate", ErrInvalidInput)
+		}
+		orderedDate = &d
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var supplierName string
+	err = tx.QueryRow("SELECT name FROM supplier WHERE id = $1 AND deleted = 0", supplierID).Scan(&supplierName)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("supplier not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+
+	// 1. Insert the purchase header; totals are filled in once the lines are known
+	var purchaseID int
+	err = tx.QueryRow(`
+		INSERT INTO product_stock_purchase (supplier_id, invoice_ref, ordered_date, received_date, notes, total, user_id)
+		VALUES ($1, $2, $3, $4, $5, 0, $6)
+		RETURNING id
+	`, supplierID, req.InvoiceRef, orderedDate, receivedDate, req.Notes, nullInt(actor.UserID)).Scan(&purchaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert purchase: %w", err)
+	}
+
+	// 2. Receive every line
+	var total float64
+	items := make([]models.PurchaseItemDTO, 0, len(req.Items))
+	for i, item := range req.Items {
+		line, err := receivePurchaseLine(tx, actor, purchaseID, supplierID, receivedDate, i+1, item)
+		if err != nil {
+			return nil, err
+		}
+		total += line.Total
+		items = append(items, *line)
+	}
+
+	// 3. Totals and payment status
+	totalAmount := roundMoney(total)
+	paid := roundMoney(math.Min(req.PaidAmount, totalAmount))
+	due := roundMoney(totalAmount - paid)
+	status := purchaseStatus(paid, due)
+
+	_, err = tx.Exec(`
+		UPDATE product_stock_purchase SET total = $1, paid_amount = $2, due = $3, purchase_status = $4
+		WHERE id = $5
+	`, totalAmount, paid, due, status, purchaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update purchase totals: %w", err)
+	}
+
+	// 4. Net any return credit the supplier still owes us against the purchase
+	if err := applyUnappliedCredit(tx, supplierID); err != nil {
+		return nil, err
+	}
+	err = tx.QueryRow(
+		"SELECT COALESCE(paid_amount, 0), COALESCE(due, 0), purchase_status FROM product_stock_purchase WHERE id = $1",
+		purchaseID,
+	).Scan(&paid, &due, &status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase totals: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.PurchaseDTO{
+		ID:             purchaseID,
+		SupplierID:     fmt.Sprintf("SUP-%03d", supplierID),
+		SupplierName:   supplierName,
+		InvoiceRef:     req.InvoiceRef,
+		OrderedDate:    req.OrderedDate,
+		ReceivedDate:   receivedDate.Format("2006-01-02"),
+		Total:          totalAmount,
+		PaidAmount:     paid,
+		Due:            due,
+		PurchaseStatus: status,
+		Notes:          req.Notes,
+		Items:          items,
+	}, nil
+}
+
+// receivePurchaseLine books one delivered line into a batch and into stock
+func receivePurchaseLine(tx *sql.Tx, actor models.Actor, purchaseID, supplierID int, receivedDate time.Time, lineNo int, item models.PurchaseItemRequest) (*models.PurchaseItemDTO, error) {
+	productID, err := parseProductID(item.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID %q", ErrInvalidInput, item.ProductID)
+	}
+	if item.Quantity <= 0 {
+		return nil, fmt.Errorf("%w: quantity must be greater than zero", ErrInvalidInput)
+	}
+	if item.CostPrice < 0 {
+		return nil, fmt.Errorf("%w: cost price must be positive", ErrInvalidInput)
+	}
+
+	packType := item.PackType
+	if packType == "" {
+		packType = models.PackTypeUnit
+	}
+
+	pk, err := loadPackaging(tx, productID)
+	if err != nil {
+		return nil, err
+	}
+	productName := pk.productName
+	units, err := pk.toUnits(packType, item.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	if units == 0 {
+		return nil, fmt.Errorf("%w: %.2f %s of %s is not a whole number of units", ErrInvalidInput, item.Quantity, packType, productName)
+	}
+	unitsPerPack, _ := pk.unitsPer(packType)
+
+	var expiry *time.Time
+	if item.ExpiryDate != "" {
+		d, err := time.Parse("2006-01-02", item.ExpiryDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: expiryDate must be YYYY-MM-DD", ErrInvalidInput)
+		}
+		if d.Before(receivedDate.Truncate(24 * time.Hour)) {
+			return nil, fmt.Errorf("%w: batch of %s is already expired", ErrInvalidInput, productName)
+		}
+		expiry = &d
+	}
+
+	batchNo := item.BatchNo
+	if batchNo == "" {
+		batchNo = fmt.Sprintf("GRN%d-%d", purchaseID, lineNo)
+	}
+
+	unitCost := roundCost(item.CostPrice / float64(unitsPerPack))
+	lineTotal := roundMoney(item.CostPrice * item.Quantity)
+
+	// 1. Stock, totals and history (locks the product row first)
+	entry, err := recordStockMovement(tx, stockMovement{
+		productID:     productID,
+		change:        units,
+		changeType:    models.StockChangePurchase,
+		referenceType: refPurchase,
+		referenceID:   purchaseID,
+		expiry:        expiry,
+		userID:        actor.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE product SET total_purchase = COALESCE(total_purchase, 0) + $1 WHERE id = $2", units, productID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update total purchase: %w", err)
+	}
+
+	// 2. Create the batch, or top up the same active batch from the same
+	// supplier at the weighted average cost of the units on it
+	var batchID, batchQty int
+	var batchCost float64
+	err = tx.QueryRow(`
+		SELECT id, GREATEST(COALESCE(quantity, 0), 0), COALESCE(cost_price, 0) FROM product_batch
+		WHERE product_id = $1 AND batch_id = $2 AND supplier_id = $3 AND status = 'active'
+		FOR UPDATE
+	`, productID, batchNo, supplierID).Scan(&batchID, &batchQty, &batchCost)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(`
+			INSERT INTO product_batch (product_id, batch_id, quantity, expiry_date, purchase_date, supplier_id, cost_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, productID, batchNo, units, expiry, receivedDate, supplierID, unitCost).Scan(&batchID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	} else {
+		averageCost := roundCost((float64(batchQty)*batchCost + float64(units)*unitCost) / float64(batchQty+units))
+		_, err = tx.Exec(`
+			UPDATE product_batch
+			SET quantity = quantity + $1, expiry_date = COALESCE($2, expiry_date), cost_price = $3, updated_at = NOW()
+			WHERE id = $4
+		`, units, expiry, averageCost, batchID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update batch: %w", err)
+		}
+	}
+	received := []batchAllocation{{batchID: batchID, batchNo: batchNo, quantity: units, costPrice: unitCost}}
+	if err := recordRegisterBatches(tx, entry.registerID, received); err != nil {
+		return nil, err
+	}
+
+	// 3. Latest buying price for this supplier; first supplier becomes primary
+	_, err = tx.Exec(`
+		INSERT INTO product_supplier (product_id, supplier_id, is_primary, buying_price)
+		VALUES ($1, $2, NOT EXISTS (SELECT 1 FROM product_supplier WHERE product_id = $1), $3)
+		ON CONFLICT (product_id, supplier_id) DO UPDATE SET buying_price = EXCLUDED.buying_price
+	`, productID, supplierID, unitCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update supplier buying price: %w", err)
+	}
+
+	// 4. Purchase line
+	var itemID int
+	err = tx.QueryRow(`
+		INSERT INTO product_stock_purchase_items (
+			psp_fk_id, product_id, pack_type, quantity, units,
+			batch_no, expiry_date, cost_price, total, batch_fk_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, purchaseID, productID, packType, item.Quantity, units,
+		batchNo, expiry, item.CostPrice, lineTotal, batchID,
+	).Scan(&itemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert purchase item: %w", err)
+	}
+
+	line := &models.PurchaseItemDTO{
+		ID:          itemID,
+		ProductID:   fmt.Sprintf("prod_%03d", productID),
+		ProductName: productName,
+		PackType:    packType,
+		Quantity:    item.Quantity,
+		Units:       units,
+		BatchID:     batchID,
+		BatchNo:     batchNo,
+		CostPrice:   item.CostPrice,
+		Total:       lineTotal,
+	}
+	if expiry != nil {
+		line.ExpiryDate = expiry.Format("2006-01-02")
+	}
+	return line, nil
+}
+
+// GetPurchases lists goods receipts, newest first, optionally for one supplier
+func GetPurchases(db *sql.DB, page, limit, supplierID int) ([]models.PurchaseDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if supplierID > 0 {
+		whereClause += " AND psp.supplier_id = $1"
+		args = append(args, supplierID)
+	}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM product_stock_purchase psp"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := `
+		SELECT psp.id, psp.supplier_id, COALESCE(s.name, ''), COALESCE(psp.invoice_ref, ''),
+		       psp.ordered_date,
+		       COALESCE(psp.received_date, psp.created_at::date), psp.total,
+		       COALESCE(psp.paid_amount, 0), COALESCE(psp.due, 0), psp.purchase_status, COALESCE(psp.notes, '')
+		FROM product_stock_purchase psp
+		LEFT JOIN supplier s ON psp.supplier_id = s.id` + whereClause +
+		fmt.Sprintf(" ORDER BY psp.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	purchases := []models.PurchaseDTO{}
+	for rows.Next() {
+		p, err := scanPurchase(rows)
+		if err != nil {
+			return nil, models.Pagination{}, err
+		}
+		purchases = append(purchases, *p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (totalItems + limit - 1) / limit
+	}
+
+	return purchases, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
+
+// GetPurchaseByID retrieves a goods receipt with its lines
+func GetPurchaseByID(db *sql.DB, id int) (*models.PurchaseDTO, error) {
+	row := db.QueryRow(`
+		SELECT psp.id, psp.supplier_id, COALESCE(s.name, ''), COALESCE(psp.invoice_ref, ''),
+		       psp.ordered_date,
+		       COALESCE(psp.received_date, psp.created_at::date), psp.total,
+		       COALESCE(psp.paid_amount, 0), COALESCE(psp.due, 0), psp.purchase_status, COALESCE(psp.notes, '')
+		FROM product_stock_purchase psp
+		LEFT JOIN supplier s ON psp.supplier_id = s.id
+		WHERE psp.id = $1
+	`, id)
+	p, err := scanPurchase(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("purchase not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get purchase: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT pi.id, pi.product_id, p.product_name, pi.pack_type, pi.quantity, COALESCE(pi.units, 0),
+		       COALESCE(pi.batch_fk_id, 0), COALESCE(pi.batch_no, ''), pi.expiry_date,
+		       COALESCE(pi.cost_price, 0), COALESCE(pi.total, 0)
+		FROM product_stock_purchase_items pi
+		JOIN product p ON pi.product_id = p.id
+		WHERE pi.psp_fk_id = $1
+		ORDER BY pi.id
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchase items: %w", err)
+	}
+	defer rows.Close()
+
+	p.Items = []models.PurchaseItemDTO{}
+	for rows.Next() {
+		var item models.PurchaseItemDTO
+		var productID int
+		var expiry sql.NullTime
+		if err := rows.Scan(&item.ID, &productID, &item.ProductName, &item.PackType, &item.Quantity, &item.Units,
+			&item.BatchID, &item.BatchNo, &expiry, &item.CostPrice, &item.Total); err != nil {
+			return nil, fmt.Errorf("failed to scan purchase item: %w", err)
+		}
+		item.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if expiry.Valid {
+			item.ExpiryDate = expiry.Time.Format("2006-01-02")
+		}
+		p.Items = append(p.Items, item)
+	}
+
+	return p, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPurchase(row rowScanner) (*models.PurchaseDTO, error) {
+	var p models.PurchaseDTO
+	var supplierID sql.NullInt64
+	var orderedDate sql.NullTime
+	var receivedDate time.Time
+	var status sql.NullString
+	if err := row.Scan(&p.ID, &supplierID, &p.SupplierName, &p.InvoiceRef, &orderedDate, &receivedDate,
+		&p.Total, &p.PaidAmount, &p.Due, &status, &p.Notes); err != nil {
+		return nil, err
+	}
+	if supplierID.Valid {
+		p.SupplierID = fmt.Sprintf("SUP-%03d", supplierID.Int64)
+	}
+	if orderedDate.Valid {
+		p.OrderedDate = orderedDate.Time.Format("2006-01-02")
+	}
+	p.ReceivedDate = receivedDate.Format("2006-01-02")
+	p.PurchaseStatus = models.PurchaseStatus(status.String)
+	return &p, nil
+}
@@ -0,0 +1,455 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// BATCH RECALLS
+// A recall blocks batches from sale, traces the invoices that received
+// them through invoice_item_batches and returns the remaining stock to
+// each batch's supplier.
+// =====================================================
+
+const refRecall = "recall"
+
+func formatRecallNo(id int) string {
+	return fmt.Sprintf("RCL-%06d", id)
+}
+
+// CreateRecall recalls batches of a product. Active stock leaves
+// available_stock through a 'recall' ledger movement, every batch is marked
+// recalled, and what is left of each batch goes on a company return to its
+// supplier, one return per supplier.
+func CreateRecall(db *sql.DB, actor models.Actor, req models.CreateRecallRequest) (*models.RecallDTO, error) {
+	productID, err := parseProductID(req.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+	var batchNos []string
+	seen := map[string]bool{}
+	for _, no := range req.BatchNos {
+		no = strings.TrimSpace(no)
+		if no != "" && !seen[no] {
+			seen[no] = true
+			batchNos = append(batchNos, no)
+		}
+	}
+	if len(batchNos) == 0 {
+		return nil, fmt.Errorf("%w: at least one batch number is required", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the product before its batches, as every stock movement does
+	err = tx.QueryRow("SELECT id FROM product WHERE id = $1 AND deleted = 0 FOR UPDATE", productID).Scan(&productID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock product: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, batch_id, COALESCE(quantity, 0), status, supplier_id
+		FROM product_batch
+		WHERE product_id = $1 AND batch_id = ANY($2)
+		ORDER BY id
+		FOR UPDATE
+	`, productID, pq.Array(batchNos))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+	type target struct {
+		batchID, quantity, supplierID int
+		batchNo                       string
+		status                        models.BatchStatus
+	}
+	var targets []target
+	found := map[string]bool{}
+	for rows.Next() {
+		var t target
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&t.batchID, &t.batchNo, &t.quantity, &t.status, &supplierID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+		if supplierID.Valid {
+			t.supplierID = int(supplierID.Int64)
+		}
+		targets = append(targets, t)
+		found[t.batchNo] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batches: %w", err)
+	}
+	for _, no := range batchNos {
+		if !found[no] {
+			return nil, fmt.Errorf("batch %s not found", no)
+		}
+	}
+	for _, t := range targets {
+		if t.status == models.BatchStatusRecalled {
+			return nil, fmt.Errorf("%w: batch %s is already recalled", ErrInvalidInput, t.batchNo)
+		}
+	}
+
+	var recallID int
+	err = tx.QueryRow(`
+		INSERT INTO recall (product_id, reference, reason, user_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, productID, nullString(strings.TrimSpace(req.Reference)), nullString(strings.TrimSpace(req.Reason)),
+		nullInt(actor.UserID)).Scan(&recallID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert recall: %w", err)
+	}
+
+	note := "Recall " + formatRecallNo(recallID)
+	if ref := strings.TrimSpace(req.Reference); ref != "" {
+		note += " (" + ref + ")"
+	}
+
+	returns := map[int][]models.CompanyReturnItemRequest{}
+	var suppliers []int
+	for _, t := range targets {
+		// Quarantined units already left available stock
+		if t.status == models.BatchStatusActive && t.quantity > 0 {
+			entry, err := recordStockMovement(tx, stockMovement{
+				productID:     productID,
+				change:        -t.quantity,
+				changeType:    models.StockChangeRecall,
+				referenceType: refRecall,
+				referenceID:   recallID,
+				note:          note,
+				userID:        actor.UserID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			recalled := []batchAllocation{{batchID: t.batchID, batchNo: t.batchNo, quantity: t.quantity}}
+			if err := recordRegisterBatches(tx, entry.registerID, recalled); err != nil {
+				return nil, err
+			}
+		}
+
+		_, err := tx.Exec("UPDATE product_batch SET status = 'recalled', updated_at = NOW() WHERE id = $1", t.batchID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to recall batch %s: %w", t.batchNo, err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO recall_batch (recall_id, batch_fk_id, quantity, previous_status)
+			VALUES ($1, $2, $3, $4)
+		`, recallID, t.batchID, t.quantity, string(t.status))
+		if err != nil {
+			return nil, fmt.Errorf("failed to record recalled batch %s: %w", t.batchNo, err)
+		}
+
+		if t.quantity > 0 && t.supplierID > 0 {
+			if _, ok := returns[t.supplierID]; !ok {
+				suppliers = append(suppliers, t.supplierID)
+			}
+			returns[t.supplierID] = append(returns[t.supplierID], models.CompanyReturnItemRequest{BatchID: t.batchID})
+		}
+	}
+
+	for _, supplierID := range suppliers {
+		_, err := createCompanyReturn(tx, actor, supplierID, recallID, models.CreateCompanyReturnRequest{
+			Reason: models.CompanyReturnRecalled,
+			Notes:  note,
+			Items:  returns[supplierID],
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetRecallByID(db, recallID)
+}
+
+const recallColumns = `
+	SELECT rc.id, rc.product_id, p.product_name, COALESCE(rc.reference, ''), COALESCE(rc.reason, ''), rc.created_at
+	FROM recall rc
+	JOIN product p ON rc.product_id = p.id`
+
+func scanRecall(row rowScanner) (*models.RecallDTO, error) {
+	var rc models.RecallDTO
+	var productID int
+	var createdAt time.Time
+	if err := row.Scan(&rc.ID, &productID, &rc.ProductName, &rc.Reference, &rc.Reason, &createdAt); err != nil {
+		return nil, err
+	}
+	rc.RecallNo = formatRecallNo(rc.ID)
+	rc.ProductID = fmt.Sprintf("prod_%03d", productID)
+	rc.CreatedAt = createdAt.Format(time.RFC3339)
+	rc.Batches = []models.RecallBatchDTO{}
+	return &rc, nil
+}
+
+// attachRecallBatches loads the batches of each recall, with the units sold
+// from them and returned by customers
+func attachRecallBatches(db *sql.DB, recalls []*models.RecallDTO) error {
+	if len(recalls) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(recalls))
+	byID := make(map[int]*models.RecallDTO, len(recalls))
+	for i, rc := range recalls {
+		ids[i] = int64(rc.ID)
+		byID[rc.ID] = rc
+	}
+
+	rows, err := db.Query(`
+		SELECT rb.recall_id, b.id, b.batch_id, b.expiry_date, b.supplier_id, COALESCE(s.name, ''),
+		       COALESCE(rb.previous_status, ''), rb.quantity, COALESCE(b.quantity, 0),
+		       COALESCE(sold.quantity, 0), COALESCE(sold.returned, 0)
+		FROM recall_batch rb
+		JOIN product_batch b ON rb.batch_fk_id = b.id
+		LEFT JOIN supplier s ON b.supplier_id = s.id
+		LEFT JOIN (
+			SELECT iib.batch_fk_id, SUM(iib.quantity) AS quantity, SUM(iib.returned_quantity) AS returned
+			FROM invoice_item_batches iib
+			JOIN invoice_items ii ON iib.invoice_item_id = ii.id
+			JOIN invoice i ON ii.invoice_id = i.id
+			WHERE i.deleted = 0
+			  AND iib.batch_fk_id IN (SELECT batch_fk_id FROM recall_batch WHERE recall_id = ANY($1))
+			GROUP BY iib.batch_fk_id
+		) sold ON sold.batch_fk_id = b.id
+		WHERE rb.recall_id = ANY($1)
+		ORDER BY rb.id
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query recalled batches: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recallID int
+		var b models.RecallBatchDTO
+		var expiry sql.NullTime
+		var supplierID sql.NullInt64
+		if err := rows.Scan(&recallID, &b.BatchID, &b.BatchNo, &expiry, &supplierID, &b.SupplierName,
+			&b.PreviousStatus, &b.Quantity, &b.Remaining, &b.Sold, &b.Returned); err != nil {
+			return fmt.Errorf("failed to scan recalled batch: %w", err)
+		}
+		if expiry.Valid {
+			b.ExpiryDate = expiry.Time.Format("2006-01-02")
+		}
+		if supplierID.Valid {
+			b.SupplierID = fmt.Sprintf("SUP-%03d", supplierID.Int64)
+		}
+		rc := byID[recallID]
+		rc.Batches = append(rc.Batches, b)
+	}
+
+	return rows.Err()
+}
+
+// GetRecalls lists recalls, newest first, optionally for one product
+func GetRecalls(db *sql.DB, page, limit, productID int) ([]models.RecallDTO, models.Pagination, error) {
+	offset := (page - 1) * limit
+
+	whereClause := " WHERE 1 = 1"
+	var args []interface{}
+	if productID > 0 {
+		args = append(args, productID)
+		whereClause += fmt.Sprintf(" AND rc.product_id = $%d", len(args))
+	}
+
+	var totalItems int
+	err := db.QueryRow("SELECT COUNT(*) FROM recall rc"+whereClause, args...).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	query := recallColumns + whereClause +
+		fmt.Sprintf(" ORDER BY rc.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	var list []*models.RecallDTO
+	for rows.Next() {
+		rc, err := scanRecall(rows)
+		if err != nil {
+			return nil, models.Pagination{}, fmt.Errorf("failed to scan recall: %w", err)
+		}
+		list = append(list, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+	rows.Close()
+
+	if err := attachRecallBatches(db, list); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	recalls := make([]models.RecallDTO, len(list))
+	for i, rc := range list {
+		recalls[i] = *rc
+	}
+
+	totalPages := (totalItems + limit - 1) / limit
+	return recalls, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
+
+// GetRecallByID retrieves a recall with the stock to pull per rack, every
+// invoice and customer that received the recalled batches, and the company
+// returns raised for the remaining stock
+func GetRecallByID(db *sql.DB, id int) (*models.RecallDTO, error) {
+	rc, err := scanRecall(db.QueryRow(recallColumns+" WHERE rc.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("recall not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recall: %w", err)
+	}
+	if err := attachRecallBatches(db, []*models.RecallDTO{rc}); err != nil {
+		return nil, err
+	}
+
+	// Racks are kept per product, so the recalled units sit on the product's rack
+	var rack models.RecallRackDTO
+	err = db.QueryRow(`
+		SELECT COALESCE(r.rack_name, ''), COALESCE(r.rack_location, '')
+		FROM recall rc
+		JOIN product p ON rc.product_id = p.id
+		LEFT JOIN rack r ON p.rack_fk_id = r.id
+		WHERE rc.id = $1
+	`, id).Scan(&rack.Rack, &rack.Location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product rack: %w", err)
+	}
+	if rack.Rack == "" {
+		rack.Rack = "Unassigned"
+	}
+	rack.BatchNos = []string{}
+	for _, b := range rc.Batches {
+		if b.Quantity > 0 {
+			rack.Quantity += b.Quantity
+			rack.BatchNos = append(rack.BatchNos, b.BatchNo)
+		}
+	}
+	rc.Racks = []models.RecallRackDTO{}
+	if rack.Quantity > 0 {
+		rc.Racks = append(rc.Racks, rack)
+	}
+
+	if err := attachRecallSales(db, rc); err != nil {
+		return nil, err
+	}
+
+	returnRows, err := db.Query("SELECT id FROM company_return WHERE recall_id = $1 ORDER BY id", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recall returns: %w", err)
+	}
+	var returnIDs []int
+	for returnRows.Next() {
+		var returnID int
+		if err := returnRows.Scan(&returnID); err != nil {
+			returnRows.Close()
+			return nil, fmt.Errorf("failed to scan company return: %w", err)
+		}
+		returnIDs = append(returnIDs, returnID)
+	}
+	returnRows.Close()
+	if err := returnRows.Err(); err != nil {
+		return nil, err
+	}
+
+	rc.CompanyReturns = []models.CompanyReturnDTO{}
+	returned := map[int]bool{}
+	for _, returnID := range returnIDs {
+		ret, err := GetCompanyReturnByID(db, returnID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range ret.Items {
+			returned[item.BatchID] = true
+		}
+		rc.CompanyReturns = append(rc.CompanyReturns, *ret)
+	}
+	rc.Unreturned = []string{}
+	for _, b := range rc.Batches {
+		if b.Quantity > 0 && !returned[b.BatchID] {
+			rc.Unreturned = append(rc.Unreturned, b.BatchNo)
+		}
+	}
+
+	return rc, nil
+}
+
+// attachRecallSales lists the invoice lines that drew units from a recall's
+// batches, newest first, with the customers to contact
+func attachRecallSales(db *sql.DB, rc *models.RecallDTO) error {
+	rows, err := db.Query(`
+		SELECT i.id, i.created_at, b.batch_id, iib.quantity, iib.returned_quantity,
+		       i.customer_id_fk, COALESCE(c.name, ''), COALESCE(c.phone, ''),
+		       COALESCE(c.email, ''), COALESCE(c.address, '')
+		FROM recall_batch rb
+		JOIN product_batch b ON rb.batch_fk_id = b.id
+		JOIN invoice_item_batches iib ON iib.batch_fk_id = b.id
+		JOIN invoice_items ii ON iib.invoice_item_id = ii.id
+		JOIN invoice i ON ii.invoice_id = i.id
+		LEFT JOIN customer c ON i.customer_id_fk = c.id
+		WHERE rb.recall_id = $1 AND i.deleted = 0
+		ORDER BY i.created_at DESC, iib.id DESC
+	`, rc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to query recalled batch sales: %w", err)
+	}
+	defer rows.Close()
+
+	rc.Sales = []models.RecallSaleDTO{}
+	customers := map[int]bool{}
+	walkIns := map[int]bool{}
+	for rows.Next() {
+		var s models.RecallSaleDTO
+		var soldAt time.Time
+		var customerID sql.NullInt64
+		if err := rows.Scan(&s.InvoiceID, &soldAt, &s.BatchNo, &s.Quantity, &s.Returned,
+			&customerID, &s.CustomerName, &s.CustomerPhone, &s.CustomerEmail, &s.CustomerAddress); err != nil {
+			return fmt.Errorf("failed to scan recalled batch sale: %w", err)
+		}
+		s.InvoiceNo = formatInvoiceNo(s.InvoiceID)
+		s.SoldAt = soldAt.Format(time.RFC3339)
+		if customerID.Valid {
+			cid := int(customerID.Int64)
+			s.CustomerID = &cid
+			customers[cid] = true
+		} else {
+			walkIns[s.InvoiceID] = true
+		}
+		rc.Sales = append(rc.Sales, s)
+	}
+	rc.CustomersAffected = len(customers)
+	rc.WalkInSales = len(walkIns)
+
+	return rows.Err()
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// REPORTS
+// =====================================================
+
+// expiryBuckets lists the buckets in report order
+var expiryBuckets = []models.ExpiryBucket{
+	models.ExpiryBucketExpired,
+	models.ExpiryBucketWithin30,
+	models.ExpiryBucketWithin60,
+	models.ExpiryBucketWithin90,
+	models.ExpiryBucketLater,
+	models.ExpiryBucketNoExpiry,
+}
+
+// expiryBucketFor places a batch by the days left until it expires
+func expiryBucketFor(daysLeft sql.NullInt64) models.ExpiryBucket {
+	switch {
+	case !daysLeft.Valid:
+		return models.ExpiryBucketNoExpiry
+	case daysLeft.Int64 < 0:
+		return models.ExpiryBucketExpired
+	case daysLeft.Int64 < 30:
+		return models.ExpiryBucketWithin30
+	case daysLeft.Int64 < 60:
+		return models.ExpiryBucketWithin60
+	case daysLeft.Int64 < 90:
+		return models.ExpiryBucketWithin90
+	}
+	return models.ExpiryBucketLater
+}
+
+// GetExpiryReport lists every batch with stock left, earliest expiry first.
+// rack, supplierID, category and status filter both the summary and the
+// batches; bucket only narrows the batch list.
+func GetExpiryReport(db *sql.DB, rack string, supplierID int, category string, status models.BatchStatus, bucket models.ExpiryBucket) (*models.ExpiryReportDTO, error) {
+	whereClause := " WHERE b.quantity > 0 AND p.deleted = 0"
+	var args []interface{}
+	if rack != "" {
+		args = append(args, rack)
+		whereClause += fmt.Sprintf(" AND LOWER(r.rack_name) = LOWER($%d)", len(args))
+	}
+	if supplierID > 0 {
+		args = append(args, supplierID)
+		whereClause += fmt.Sprintf(" AND b.supplier_id = $%d", len(args))
+	}
+	if category != "" {
+		args = append(args, category)
+		whereClause += fmt.Sprintf(" AND LOWER(c.category_name) = LOWER($%d)", len(args))
+	}
+	if status != "" {
+		args = append(args, status)
+		whereClause += fmt.Sprintf(" AND b.status = $%d", len(args))
+	}
+
+	rows, err := db.Query(`
+		SELECT b.id, b.batch_id, p.id, p.product_name, COALESCE(p.product_code, ''),
+		       COALESCE(p.strength, ''), COALESCE(pt.type_name, ''), COALESCE(c.category_name, ''),
+		       COALESCE(r.rack_name, ''), b.supplier_id, COALESCE(s.name, ''),
+		       b.expiry_date, b.expiry_date - CURRENT_DATE, b.status,
+		       b.quantity, COALESCE(b.cost_price, 0)
+		FROM product_batch b
+		JOIN product p ON b.product_id = p.id
+		LEFT JOIN product_type pt ON p.product_type_fk_id = pt.id
+		LEFT JOIN category c ON p.category_fk_id = c.id
+		LEFT JOIN rack r ON p.rack_fk_id = r.id
+		LEFT JOIN supplier s ON b.supplier_id = s.id`+whereClause+`
+		ORDER BY b.expiry_date ASC NULLS LAST, p.product_name, b.id
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batches: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[models.ExpiryBucket]*models.ExpiryBucketSummaryDTO{}
+	for _, b := range expiryBuckets {
+		totals[b] = &models.ExpiryBucketSummaryDTO{Bucket: b}
+	}
+
+	report := &models.ExpiryReportDTO{
+		AsOf:    time.Now().Format("2006-01-02"),
+		Batches: []models.ExpiryReportBatchDTO{},
+	}
+	for rows.Next() {
+		var b models.ExpiryReportBatchDTO
+		var productID int
+		var batchSupplier, daysLeft sql.NullInt64
+		var expiry sql.NullTime
+		if err := rows.Scan(&b.BatchID, &b.BatchNo, &productID, &b.ProductName, &b.ProductCode,
+			&b.Strength, &b.Type, &b.Category, &b.RackNo, &batchSupplier, &b.Supplier,
+			&expiry, &daysLeft, &b.Status, &b.Quantity, &b.CostPrice); err != nil {
+			return nil, fmt.Errorf("failed to scan batch: %w", err)
+		}
+
+		b.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if batchSupplier.Valid {
+			b.SupplierID = fmt.Sprintf("SUP-%03d", batchSupplier.Int64)
+		}
+		if expiry.Valid {
+			b.ExpiryDate = expiry.Time.Format("2006-01-02")
+			days := int(daysLeft.Int64)
+			b.DaysLeft = &days
+		}
+		b.Bucket = expiryBucketFor(daysLeft)
+		b.Value = roundMoney(float64(b.Quantity) * b.CostPrice)
+
+		t := totals[b.Bucket]
+		t.Batches++
+		t.Units += b.Quantity
+		t.Value = roundMoney(t.Value + b.Value)
+
+		if bucket == "" || bucket == b.Bucket {
+			report.Batches = append(report.Batches, b)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating batches: %w", err)
+	}
+
+	for _, b := range expiryBuckets {
+		report.Summary = append(report.Summary, *totals[b])
+	}
+	report.AtRiskValue = roundMoney(totals[models.ExpiryBucketExpired].Value +
+		totals[models.ExpiryBucketWithin30].Value +
+		totals[models.ExpiryBucketWithin60].Value +
+		totals[models.ExpiryBucketWithin90].Value)
+
+	return report, nil
+}
@@ -0,0 +1,430 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// CUSTOMER RETURNS (CREDIT NOTES)
+// =====================================================
+
+const refCustomerReturn = "customer_return"
+
+// returnLine is a requested return after it has been checked against the invoice line
+type returnLine struct {
+	invoiceItemID int
+	productID     int
+	productName   string
+	packType      models.PackType
+	quantity      float64
+	units         int
+	amount        float64
+	damaged       bool
+}
+
+// CreateCustomerReturn posts a return against an invoice. Each line may return
+// up to what was sold minus earlier returns. Undamaged units go back into the
+// batches they were sold from; damaged units are recorded but not restocked.
+// The credit first reduces the invoice balance, the rest is refunded in cash.
+func CreateCustomerReturn(db *sql.DB, actor models.Actor, invoiceID int, req models.CreateCustomerReturnRequest) (*models.CustomerReturnDTO, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: no items to return", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Lock the invoice
+	var customerID sql.NullInt64
+	var invoiceTotal, balance float64
+	err = tx.QueryRow(`
+		SELECT customer_id_fk, COALESCE(total, 0), COALESCE(balance, 0)
+		FROM invoice WHERE id = $1 AND deleted = 0
+		FOR UPDATE
+	`, invoiceID).Scan(&customerID, &invoiceTotal, &balance)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	// Invoice-level discount is spread over the lines in proportion to their totals
+	var linesTotal float64
+	err = tx.QueryRow("SELECT COALESCE(SUM(total), 0) FROM invoice_items WHERE invoice_id = $1", invoiceID).Scan(&linesTotal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice lines: %w", err)
+	}
+	ratio := 1.0
+	if linesTotal > 0 {
+		ratio = invoiceTotal / linesTotal
+	}
+
+	// 2. Check every line against what is still returnable
+	lines := make([]returnLine, 0, len(req.Items))
+	requested := map[int]float64{}
+	requestedUnits := map[int]int{}
+	var total float64
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: return quantity must be positive", ErrInvalidInput)
+		}
+
+		line := returnLine{invoiceItemID: item.InvoiceItemID, quantity: item.Quantity, damaged: item.Damaged}
+		var soldQty float64
+		var soldUnits, returnedUnits int
+		var lineTotal, returnedQty float64
+		err := tx.QueryRow(`
+			SELECT ii.product_id, p.product_name, ii.pack_type, ii.quantity,
+			       COALESCE(ii.units, 0), COALESCE(ii.total, 0),
+			       COALESCE((SELECT SUM(cri.quantity) FROM customer_return_items cri WHERE cri.invoice_item_id = ii.id), 0),
+			       COALESCE((SELECT SUM(cri.units) FROM customer_return_items cri WHERE cri.invoice_item_id = ii.id), 0)
+			FROM invoice_items ii
+			JOIN product p ON ii.product_id = p.id
+			WHERE ii.id = $1 AND ii.invoice_id = $2
+		`, item.InvoiceItemID, invoiceID).Scan(&line.productID, &line.productName, &line.packType, &soldQty,
+			&soldUnits, &lineTotal, &returnedQty, &returnedUnits)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: item %d is not on invoice %s", ErrInvalidInput, item.InvoiceItemID, formatInvoiceNo(invoiceID))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get invoice item: %w", err)
+		}
+
+		requested[item.InvoiceItemID] += item.Quantity
+		if returnable := soldQty - returnedQty; requested[item.InvoiceItemID] > returnable+1e-9 {
+			return nil, fmt.Errorf("%w: item %d has %.2f %s returnable, %.2f requested",
+				ErrInvalidInput, item.InvoiceItemID, math.Max(returnable, 0), line.packType, requested[item.InvoiceItemID])
+		}
+
+		// Part packs must come to whole units, so repeated part returns can't
+		// add up to more units than were sold
+		if soldQty > 0 {
+			u := float64(soldUnits) * item.Quantity / soldQty
+			if math.Abs(u-math.Round(u)) > 1e-9 {
+				return nil, fmt.Errorf("%w: item %d return of %.2f %s is not a whole number of units",
+					ErrInvalidInput, item.InvoiceItemID, item.Quantity, line.packType)
+			}
+			line.units = int(math.Round(u))
+			line.amount = roundMoney(lineTotal * ratio * item.Quantity / soldQty)
+		}
+		if line.units <= 0 {
+			return nil, fmt.Errorf("%w: item %d return quantity is less than one unit", ErrInvalidInput, item.InvoiceItemID)
+		}
+		requestedUnits[item.InvoiceItemID] += line.units
+		if returnable := soldUnits - returnedUnits; requestedUnits[item.InvoiceItemID] > returnable {
+			return nil, fmt.Errorf("%w: item %d has %d units returnable, %d requested",
+				ErrInvalidInput, item.InvoiceItemID, max(returnable, 0), requestedUnits[item.InvoiceItemID])
+		}
+		total += line.amount
+		lines = append(lines, line)
+	}
+
+	// 3. Split the credit between the outstanding balance and a cash refund
+	total = roundMoney(total)
+	credited := roundMoney(math.Min(total, balance))
+	refund := roundMoney(total - credited)
+
+	var returnID int
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO customer_return (invoice_id, customer_id_fk, total, credited_amount, refund_amount, reason, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, invoiceID, customerID, total, credited, refund, nullString(req.Reason), nullInt(actor.UserID)).Scan(&returnID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert customer return: %w", err)
+	}
+
+	// 4. Insert items and put stock back
+	items := make([]models.CustomerReturnItemDTO, 0, len(lines))
+	for _, line := range lines {
+		var itemID int
+		err := tx.QueryRow(`
+			INSERT INTO customer_return_items (return_id, invoice_item_id, product_id, pack_type, quantity, units, amount, damaged)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, returnID, line.invoiceItemID, line.productID, line.packType, line.quantity, line.units, line.amount, line.damaged,
+		).Scan(&itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert customer return item: %w", err)
+		}
+
+		// Lock and restock the product first, then the batches
+		var registerID int
+		if !line.damaged {
+			entry, err := recordStockMovement(tx, stockMovement{
+				productID:     line.productID,
+				change:        line.units,
+				changeType:    models.StockChangeCustomerReturn,
+				referenceType: refCustomerReturn,
+				referenceID:   returnID,
+				userID:        actor.UserID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			registerID = entry.registerID
+		}
+
+		if err := returnToBatches(tx, actor, returnID, itemID, registerID, line); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("UPDATE product SET total_sold = GREATEST(COALESCE(total_sold, 0) - $1, 0) WHERE id = $2", line.units, line.productID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update total sold: %w", err)
+		}
+
+		items = append(items, models.CustomerReturnItemDTO{
+			ID:            itemID,
+			InvoiceItemID: line.invoiceItemID,
+			ProductID:     fmt.Sprintf("prod_%03d", line.productID),
+			ProductName:   line.productName,
+			PackType:      line.packType,
+			Quantity:      line.quantity,
+			Units:         line.units,
+			Amount:        line.amount,
+			Damaged:       line.damaged,
+		})
+	}
+
+	// 5. Apply the credit to the invoice
+	newBalance := roundMoney(balance - credited)
+	status := models.InvoiceStatusPaid
+	if newBalance > 0 {
+		status = models.InvoiceStatusDue
+	}
+	_, err = tx.Exec(`
+		UPDATE invoice
+		SET returned_amount = COALESCE(returned_amount, 0) + $1, balance = $2, status = $3, updated_at = NOW()
+		WHERE id = $4
+	`, total, newBalance, status, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update invoice: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	ret := &models.CustomerReturnDTO{
+		ID:             returnID,
+		CreditNoteNo:   formatCreditNoteNo(returnID),
+		InvoiceID:      invoiceID,
+		InvoiceNo:      formatInvoiceNo(invoiceID),
+		Total:          total,
+		CreditedAmount: credited,
+		RefundAmount:   refund,
+		Reason:         req.Reason,
+		CreatedAt:      createdAt.Format(time.RFC3339),
+		Items:          items,
+	}
+	if customerID.Valid {
+		cid := int(customerID.Int64)
+		ret.CustomerID = &cid
+	}
+	return ret, nil
+}
+
+// returnToBatches marks returned units against the batches the invoice line
+// was sold from, most recent allocation first, and restocks those batches
+// unless the goods are damaged. Units sold before batch tracking go to a
+// RETURNED batch; a tracked line never returns more than its allocations.
+// Units going back to a quarantined or recalled batch stay blocked and are
+// taken out of available stock again. Restocked batches are added to the
+// controlled register entry registerID, if any.
+func returnToBatches(tx *sql.Tx, actor models.Actor, returnID, returnItemID, registerID int, line returnLine) error {
+	rows, err := tx.Query(`
+		SELECT iib.id, iib.batch_fk_id, COALESCE(pb.batch_id, ''), iib.quantity - iib.returned_quantity, COALESCE(iib.cost_price, 0)
+		FROM invoice_item_batches iib
+		LEFT JOIN product_batch pb ON iib.batch_fk_id = pb.id
+		WHERE iib.invoice_item_id = $1 AND iib.quantity > iib.returned_quantity
+		ORDER BY iib.id DESC
+		FOR UPDATE
+	`, line.invoiceItemID)
+	if err != nil {
+		return fmt.Errorf("failed to query batch allocations: %w", err)
+	}
+
+	type allocation struct {
+		id, batchID, quantity int
+		batchNo               string
+		costPrice             float64
+	}
+	var allocations []allocation
+	remaining := line.units
+	for rows.Next() && remaining > 0 {
+		var a allocation
+		var open int
+		if err := rows.Scan(&a.id, &a.batchID, &a.batchNo, &open, &a.costPrice); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan batch allocation: %w", err)
+		}
+		a.quantity = min(open, remaining)
+		remaining -= a.quantity
+		allocations = append(allocations, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating batch allocations: %w", err)
+	}
+
+	for _, a := range allocations {
+		_, err := tx.Exec("UPDATE invoice_item_batches SET returned_quantity = returned_quantity + $1 WHERE id = $2", a.quantity, a.id)
+		if err != nil {
+			return fmt.Errorf("failed to update batch allocation: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO customer_return_item_batches (return_item_id, batch_fk_id, quantity, cost_price)
+			VALUES ($1, $2, $3, $4)
+		`, returnItemID, a.batchID, a.quantity, a.costPrice)
+		if err != nil {
+			return fmt.Errorf("failed to record returned batch: %w", err)
+		}
+		if !line.damaged {
+			var status models.BatchStatus
+			err = tx.QueryRow(
+				"UPDATE product_batch SET quantity = quantity + $1, updated_at = NOW() WHERE id = $2 RETURNING status",
+				a.quantity, a.batchID,
+			).Scan(&status)
+			if err != nil {
+				return fmt.Errorf("failed to restock batch: %w", err)
+			}
+			restocked := []batchAllocation{{batchID: a.batchID, batchNo: a.batchNo, quantity: a.quantity}}
+			if err := recordRegisterBatches(tx, registerID, restocked); err != nil {
+				return err
+			}
+			if status != models.BatchStatusActive {
+				entry, err := recordStockMovement(tx, stockMovement{
+					productID:     line.productID,
+					change:        -a.quantity,
+					changeType:    models.StockChangeQuarantine,
+					referenceType: refCustomerReturn,
+					referenceID:   returnID,
+					note:          "Returned to a " + string(status) + " batch",
+					userID:        actor.UserID,
+				})
+				if err != nil {
+					return err
+				}
+				if err := recordRegisterBatches(tx, entry.registerID, restocked); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if remaining == 0 {
+		return nil
+	}
+	// Only lines sold before batch tracking have no allocations to return to
+	var tracked bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM invoice_item_batches WHERE invoice_item_id = $1)", line.invoiceItemID).Scan(&tracked)
+	if err != nil {
+		return fmt.Errorf("failed to get batch allocations: %w", err)
+	}
+	if tracked {
+		return fmt.Errorf("%w: %d units of %s were not sold from any batch on this invoice line",
+			ErrInvalidInput, remaining, line.productName)
+	}
+	if !line.damaged {
+		batchID, err := addToStockBatch(tx, line.productID, "RETURNED", remaining)
+		if err != nil {
+			return err
+		}
+		return recordRegisterBatches(tx, registerID, []batchAllocation{{batchID: batchID, batchNo: "RETURNED", quantity: remaining}})
+	}
+	return nil
+}
+
+// GetCustomerReturns lists the credit notes raised against an invoice
+func GetCustomerReturns(db *sql.DB, invoiceID int) ([]models.CustomerReturnDTO, error) {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM invoice WHERE id = $1 AND deleted = 0)", invoiceID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("invoice not found")
+	}
+
+	rows, err := db.Query(`
+		SELECT id, customer_id_fk, COALESCE(total, 0), COALESCE(credited_amount, 0),
+		       COALESCE(refund_amount, 0), COALESCE(reason, ''), created_at
+		FROM customer_return
+		WHERE invoice_id = $1
+		ORDER BY id
+	`, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer returns: %w", err)
+	}
+	defer rows.Close()
+
+	returns := []models.CustomerReturnDTO{}
+	index := map[int]int{}
+	for rows.Next() {
+		var r models.CustomerReturnDTO
+		var customerID sql.NullInt64
+		var createdAt time.Time
+		if err := rows.Scan(&r.ID, &customerID, &r.Total, &r.CreditedAmount,
+			&r.RefundAmount, &r.Reason, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan customer return: %w", err)
+		}
+		r.CreditNoteNo = formatCreditNoteNo(r.ID)
+		r.InvoiceID = invoiceID
+		r.InvoiceNo = formatInvoiceNo(invoiceID)
+		r.CreatedAt = createdAt.Format(time.RFC3339)
+		r.Items = []models.CustomerReturnItemDTO{}
+		if customerID.Valid {
+			cid := int(customerID.Int64)
+			r.CustomerID = &cid
+		}
+		index[r.ID] = len(returns)
+		returns = append(returns, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	itemRows, err := db.Query(`
+		SELECT cri.return_id, cri.id, COALESCE(cri.invoice_item_id, 0), cri.product_id, p.product_name,
+		       cri.pack_type, cri.quantity, cri.units, COALESCE(cri.amount, 0), COALESCE(cri.damaged, false)
+		FROM customer_return_items cri
+		JOIN customer_return cr ON cri.return_id = cr.id
+		JOIN product p ON cri.product_id = p.id
+		WHERE cr.invoice_id = $1
+		ORDER BY cri.id
+	`, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customer return items: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item models.CustomerReturnItemDTO
+		var returnID, productID int
+		if err := itemRows.Scan(&returnID, &item.ID, &item.InvoiceItemID, &productID, &item.ProductName,
+			&item.PackType, &item.Quantity, &item.Units, &item.Amount, &item.Damaged); err != nil {
+			return nil, fmt.Errorf("failed to scan customer return item: %w", err)
+		}
+		item.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if i, ok := index[returnID]; ok {
+			returns[i].Items = append(returns[i].Items, item)
+		}
+	}
+
+	return returns, itemRows.Err()
+}
+
+func formatCreditNoteNo(id int) string {
+	return fmt.Sprintf("CN-%06d", id)
+}
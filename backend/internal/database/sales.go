@@ -0,0 +1,387 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// =====================================================
+// SALES / CHECKOUT
+// =====================================================
+
+// saleLine is a cart line after it has been priced from product/product_packaging
+type saleLine struct {
+	productID   int
+	productName string
+	packType    models.PackType
+	quantity    float64
+	units       int
+	unitPrice   float64
+	gross       float64
+	discount    float64
+	vat         float64
+	total       float64
+
+	requiresPrescription bool
+	prescriptionID       int // prescription named by the cart line, 0 if none
+	prescriptionItemID   int // the prescribed item the line fills
+}
+
+// CreateSale posts a checkout: it prices every cart line, writes the invoice and
+// its items, draws the sold units from batches (FEFO) and deducts them from
+// stock, all in one transaction.
+func CreateSale(db *sql.DB, actor models.Actor, req models.CreateSaleRequest) (*models.SaleDTO, error) {
+	if len(req.Items) == 0 {
+		return nil, fmt.Errorf("%w: cart is empty", ErrInvalidInput)
+	}
+	if req.DiscountPercent < 0 || req.DiscountPercent > 100 || req.DiscountAmount < 0 || req.PaidAmount < 0 {
+		return nil, fmt.Errorf("%w: discount and paid amount must be positive", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 1. Resolve customer. The row stays locked so concurrent credit sales
+	// cannot both pass the credit limit check.
+	var customerName string
+	var creditLimit float64
+	if req.CustomerID != nil {
+		err = tx.QueryRow(
+			"SELECT name, COALESCE(credit_limit, 0) FROM customer WHERE id = $1 AND deleted = 0 FOR UPDATE",
+			*req.CustomerID,
+		).Scan(&customerName, &creditLimit)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("customer not found")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get customer: %w", err)
+		}
+	}
+
+	// 2. Price every line
+	lines := make([]saleLine, 0, len(req.Items))
+	var subtotal, lineDiscount, vat float64
+	for _, item := range req.Items {
+		line, err := priceSaleLine(tx, item)
+		if err != nil {
+			return nil, err
+		}
+		subtotal += line.gross
+		lineDiscount += line.discount
+		vat += line.vat
+		lines = append(lines, line)
+	}
+
+	// 3. Invoice totals
+	subtotal = roundMoney(subtotal)
+	vat = roundMoney(vat)
+	invoiceDiscount := roundMoney(req.DiscountAmount + (subtotal-lineDiscount)*req.DiscountPercent/100)
+	discount := roundMoney(lineDiscount + invoiceDiscount)
+	total := roundMoney(subtotal - discount + vat)
+	if total < 0 {
+		return nil, fmt.Errorf("%w: discount exceeds invoice amount", ErrInvalidInput)
+	}
+
+	paid := math.Min(req.PaidAmount, total)
+	changeDue := roundMoney(math.Max(req.PaidAmount-total, 0))
+	balance := roundMoney(total - paid)
+
+	status := models.InvoiceStatusPaid
+	if balance > 0 {
+		status = models.InvoiceStatusDue
+	}
+
+	invoiceType := req.InvoiceType
+	if invoiceType == "" {
+		invoiceType = models.InvoiceTypeCash
+		if balance > 0 {
+			invoiceType = models.InvoiceTypeOutstanding
+		}
+	}
+	switch invoiceType {
+	case models.InvoiceTypeCash:
+		if balance > 0 {
+			return nil, fmt.Errorf("%w: cash invoice must be fully paid (due %.2f)", ErrInvalidInput, balance)
+		}
+	case models.InvoiceTypeOutstanding:
+		if req.CustomerID == nil {
+			return nil, fmt.Errorf("%w: outstanding invoice requires a customer", ErrInvalidInput)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown invoice type %q", ErrInvalidInput, invoiceType)
+	}
+	if balance > 0 && creditLimit > 0 {
+		if err := checkCreditLimit(tx, *req.CustomerID, creditLimit, balance); err != nil {
+			return nil, err
+		}
+	}
+
+	// 4. Check prescriptions and use up their fills
+	if err := dispensePrescriptions(tx, req.CustomerID, lines); err != nil {
+		return nil, err
+	}
+
+	// 5. Insert invoice
+	var invoiceID int
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO invoice (
+			customer_id_fk, invoice_type, subtotal, discount, vat, total,
+			paid_amount, balance, status, notes, user_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, req.CustomerID, invoiceType, subtotal, discount, vat, total,
+		paid, balance, status, req.Notes, nullInt(actor.UserID),
+	).Scan(&invoiceID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert invoice: %w", err)
+	}
+
+	// 6. Insert items and deduct stock
+	items := make([]models.SaleItemDTO, 0, len(lines))
+	for _, line := range lines {
+		var itemID int
+		err = tx.QueryRow(`
+			INSERT INTO invoice_items (
+				invoice_id, product_id, pack_type, quantity, units,
+				unit_price, discount, vat, total, prescription_item_id
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id
+		`, invoiceID, line.productID, line.packType, line.quantity, line.units,
+			line.unitPrice, line.discount, line.vat, line.total, nullInt(line.prescriptionItemID),
+		).Scan(&itemID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert invoice item: %w", err)
+		}
+
+		// Lock and deduct product stock first, then draw the units from batches
+		entry, err := recordStockMovement(tx, stockMovement{
+			productID:          line.productID,
+			change:             -line.units,
+			changeType:         models.StockChangeSold,
+			referenceType:      refInvoice,
+			referenceID:        invoiceID,
+			prescriptionItemID: line.prescriptionItemID,
+			userID:             actor.UserID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		allocations, err := allocateBatchesFEFO(tx, line.productID, line.units)
+		if err != nil {
+			return nil, err
+		}
+		if err := recordRegisterBatches(tx, entry.registerID, allocations); err != nil {
+			return nil, err
+		}
+		if err := recordInvoiceItemBatches(tx, itemID, allocations); err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec("UPDATE product SET total_sold = COALESCE(total_sold, 0) + $1 WHERE id = $2", line.units, line.productID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update total sold: %w", err)
+		}
+
+		item := models.SaleItemDTO{
+			ID:          itemID,
+			ProductID:   fmt.Sprintf("prod_%03d", line.productID),
+			ProductName: line.productName,
+			PackType:    line.packType,
+			Quantity:    line.quantity,
+			Units:       line.units,
+			UnitPrice:   line.unitPrice,
+			Discount:    line.discount,
+			VAT:         line.vat,
+			Total:       line.total,
+		}
+		if line.prescriptionID != 0 {
+			rxID := line.prescriptionID
+			item.PrescriptionID = &rxID
+		}
+		items = append(items, item)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &models.SaleDTO{
+		ID:           invoiceID,
+		InvoiceNo:    formatInvoiceNo(invoiceID),
+		CustomerID:   req.CustomerID,
+		CustomerName: customerName,
+		InvoiceType:  invoiceType,
+		Subtotal:     subtotal,
+		Discount:     discount,
+		VAT:          vat,
+		Total:        total,
+		PaidAmount:   paid,
+		Balance:      balance,
+		ChangeDue:    changeDue,
+		Status:       status,
+		Notes:        req.Notes,
+		CreatedAt:    createdAt.Format(time.RFC3339),
+		Items:        items,
+	}, nil
+}
+
+// priceSaleLine prices one cart line from the product and its packaging.
+// Line discount and VAT come from the product's discount_percent/vat_percent.
+func priceSaleLine(tx *sql.Tx, item models.SaleItemRequest) (saleLine, error) {
+	productID, err := parseProductID(item.ProductID)
+	if err != nil {
+		return saleLine{}, fmt.Errorf("%w: invalid product ID %q", ErrInvalidInput, item.ProductID)
+	}
+	if item.Quantity <= 0 {
+		return saleLine{}, fmt.Errorf("%w: quantity must be greater than zero", ErrInvalidInput)
+	}
+
+	packType := item.PackType
+	if packType == "" {
+		packType = models.PackTypeUnit
+	}
+
+	line := saleLine{productID: productID, packType: packType, quantity: item.Quantity}
+	if item.PrescriptionID != nil {
+		line.prescriptionID = *item.PrescriptionID
+	}
+
+	var discountPercent, vatPercent float64
+	err = tx.QueryRow(`
+		SELECT product_name, COALESCE(discount_percent, 0), COALESCE(vat_percent, 0), requires_prescription
+		FROM product WHERE id = $1 AND deleted = 0
+	`, productID).Scan(&line.productName, &discountPercent, &vatPercent, &line.requiresPrescription)
+	if err == sql.ErrNoRows {
+		return saleLine{}, fmt.Errorf("product %s not found", item.ProductID)
+	}
+	if err != nil {
+		return saleLine{}, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	pk, err := loadPackaging(tx, productID)
+	if err != nil {
+		return saleLine{}, err
+	}
+	line.units, err = pk.toUnits(packType, item.Quantity)
+	if err != nil {
+		return saleLine{}, err
+	}
+	line.unitPrice = pk.packPrice(packType)
+
+	line.gross = roundMoney(line.unitPrice * item.Quantity)
+	line.discount = roundMoney(line.gross * discountPercent / 100)
+	line.vat = roundMoney((line.gross - line.discount) * vatPercent / 100)
+	line.total = roundMoney(line.gross - line.discount + line.vat)
+
+	return line, nil
+}
+
+// saleColumns selects a posted invoice for scanSale
+const saleColumns = `
+	SELECT i.id, i.customer_id_fk, c.name, i.invoice_type,
+	       COALESCE(i.subtotal, 0), COALESCE(i.discount, 0), COALESCE(i.vat, 0), COALESCE(i.total, 0),
+	       COALESCE(i.paid_amount, 0), COALESCE(i.balance, 0), i.status, i.notes, i.created_at
+	FROM invoice i
+	LEFT JOIN customer c ON i.customer_id_fk = c.id`
+
+func scanSale(row rowScanner) (*models.SaleDTO, error) {
+	var s models.SaleDTO
+	var customerID sql.NullInt64
+	var customerName, notes sql.NullString
+	var createdAt time.Time
+
+	if err := row.Scan(&s.ID, &customerID, &customerName, &s.InvoiceType,
+		&s.Subtotal, &s.Discount, &s.VAT, &s.Total,
+		&s.PaidAmount, &s.Balance, &s.Status, &notes, &createdAt); err != nil {
+		return nil, err
+	}
+
+	s.InvoiceNo = formatInvoiceNo(s.ID)
+	if customerID.Valid {
+		cid := int(customerID.Int64)
+		s.CustomerID = &cid
+	}
+	s.CustomerName = customerName.String
+	s.Notes = notes.String
+	s.CreatedAt = createdAt.Format(time.RFC3339)
+	s.Items = []models.SaleItemDTO{}
+	return &s, nil
+}
+
+// attachSaleItems loads the line items of the given invoices in one query
+func attachSaleItems(db *sql.DB, sales []*models.SaleDTO) error {
+	if len(sales) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.SaleDTO, len(sales))
+	ids := make([]int64, len(sales))
+	for i, s := range sales {
+		byID[s.ID] = s
+		ids[i] = int64(s.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT ii.invoice_id, ii.id, ii.product_id, p.product_name, ii.pack_type, ii.quantity,
+		       COALESCE(ii.units, 0), COALESCE(ii.unit_price, 0), COALESCE(ii.discount, 0),
+		       COALESCE(ii.vat, 0), COALESCE(ii.total, 0), rx.prescription_id
+		FROM invoice_items ii
+		JOIN product p ON ii.product_id = p.id
+		LEFT JOIN prescription_item rx ON ii.prescription_item_id = rx.id
+		WHERE ii.invoice_id = ANY($1)
+		ORDER BY ii.invoice_id, ii.id
+	`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query invoice items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.SaleItemDTO
+		var invoiceID, productID int
+		var prescriptionID sql.NullInt64
+		if err := rows.Scan(&invoiceID, &item.ID, &productID, &item.ProductName, &item.PackType, &item.Quantity,
+			&item.Units, &item.UnitPrice, &item.Discount, &item.VAT, &item.Total, &prescriptionID); err != nil {
+			return fmt.Errorf("failed to scan invoice item: %w", err)
+		}
+		item.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if prescriptionID.Valid {
+			rxID := int(prescriptionID.Int64)
+			item.PrescriptionID = &rxID
+		}
+		if s := byID[invoiceID]; s != nil {
+			s.Items = append(s.Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+// GetSaleByID retrieves a posted invoice with its line items
+func GetSaleByID(db *sql.DB, id int) (*models.SaleDTO, error) {
+	s, err := scanSale(db.QueryRow(saleColumns+" WHERE i.id = $1 AND i.deleted = 0", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("invoice not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+
+	if err := attachSaleItems(db, []*models.SaleDTO{s}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func formatInvoiceNo(id int) string {
+	return fmt.Sprintf("INV-%06d", id)
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// PRODUCT SEARCH
+// product.search_text and product.search_vector are kept up to date by
+// trigger (migration 022). A term matches on a word prefix, a close spelling
+// (trigram word similarity, so "amoxicilin" finds amoxicillin), a substring,
+// or an exact barcode. Product codes are part of search_text, so they match
+// as substrings and prefixes; every branch of the filter has an index, which
+// lets the planner combine them in a BitmapOr instead of scanning product.
+// =====================================================
+
+// productSearch is the filter and relevance rank for a search term
+type productSearch struct {
+	where string
+	rank  string
+	args  []interface{}
+}
+
+// newProductSearch builds the search for term with its arguments numbered
+// from argStart. Expects product to be aliased p.
+func newProductSearch(term string, argStart int) productSearch {
+	term = strings.ToLower(strings.TrimSpace(term))
+	s := productSearch{args: []interface{}{term}}
+	arg := func(v interface{}) string {
+		s.args = append(s.args, v)
+		return fmt.Sprintf("$%d", argStart+len(s.args)-1)
+	}
+	t := fmt.Sprintf("$%d", argStart)
+
+	like := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+	conds := []string{
+		t + " <% p.search_text",
+		"p.barcode = " + t,
+	}
+	// Substrings shorter than a trigram can't use the index
+	if utf8.RuneCountInString(term) >= 3 {
+		conds = append(conds, "p.search_text LIKE "+arg("%"+like+"%"))
+	}
+	rank := []string{
+		"word_similarity(" + t + ", p.search_text)",
+		fmt.Sprintf("CASE WHEN p.barcode = %s OR lower(p.product_code) = %s THEN 4 ELSE 0 END", t, t),
+		"CASE WHEN lower(p.product_name) LIKE " + arg(like+"%") + " THEN 2 ELSE 0 END",
+	}
+	if q := prefixQuery(term); q != "" {
+		tsq := "to_tsquery('simple', " + arg(q) + ")"
+		conds = append(conds, "p.search_vector @@ "+tsq)
+		rank = append(rank, "ts_rank(p.search_vector, "+tsq+")")
+	}
+
+	s.where = "(" + strings.Join(conds, " OR ") + ")"
+	s.rank = "(" + strings.Join(rank, " + ") + ")"
+	return s
+}
+
+// prefixQuery turns "amox 500" into the tsquery "amox:* & 500:*". Combining
+// marks belong to their word, so Bangla vowel signs don't split it.
+func prefixQuery(term string) string {
+	words := strings.FieldsFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.M, r)
+	})
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// SuggestProducts returns the best matches for a partly typed term, for
+// counter autocomplete. Terms under two characters return nothing.
+func SuggestProducts(db *sql.DB, term string, limit int) ([]models.ProductSuggestionDTO, error) {
+	suggestions := []models.ProductSuggestionDTO{}
+	if utf8.RuneCountInString(strings.TrimSpace(term)) < 2 {
+		return suggestions, nil
+	}
+
+	search := newProductSearch(term, 1)
+	args := append(search.args, limit)
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT p.id, p.product_name, COALESCE(g.generic_name, ''), COALESCE(p.strength, ''),
+		       COALESCE(p.manufacture, ''), COALESCE(p.barcode, ''), COALESCE(p.unit_price, 0),
+		       COALESCE(p.available_stock, 0), p.requires_prescription
+		FROM product p
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		WHERE p.deleted = 0 AND %s
+		ORDER BY %s DESC, p.product_name
+		LIMIT $%d
+	`, search.where, search.rank, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search products: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s models.ProductSuggestionDTO
+		var id int
+		if err := rows.Scan(&id, &s.Name, &s.GenericName, &s.Strength, &s.Manufacture, &s.Barcode,
+			&s.Price, &s.InStock, &s.RequiresPrescription); err != nil {
+			return nil, fmt.Errorf("failed to scan product suggestion: %w", err)
+		}
+		s.ID = fmt.Sprintf("prod_%03d", id)
+		suggestions = append(suggestions, s)
+	}
+	return suggestions, rows.Err()
+}
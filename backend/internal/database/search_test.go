@@ -0,0 +1,65 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"amox 500", "amox:* & 500:*"},
+		{"napa-extra", "napa:* & extra:*"},
+		{"500mg/5ml", "500mg:* & 5ml:*"},
+		{"a&b|c:*", "a:* & b:* & c:*"}, // tsquery operators never reach the query
+		{"প্যারা সিরাপ", "প্যারা:* & সিরাপ:*"},
+		{"  ", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := prefixQuery(tt.term); got != tt.want {
+			t.Errorf("prefixQuery(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestNewProductSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		term     string
+		argStart int
+		where    string
+		args     []interface{}
+	}{
+		{"words", "  Amox 500 ", 3,
+			"($3 <% p.search_text OR p.barcode = $3 OR p.search_text LIKE $4 OR p.search_vector @@ to_tsquery('simple', $6))",
+			[]interface{}{"amox 500", "%amox 500%", "amox 500%", "amox:* & 500:*"}},
+		{"too short for a substring", "am", 1,
+			"($1 <% p.search_text OR p.barcode = $1 OR p.search_vector @@ to_tsquery('simple', $3))",
+			[]interface{}{"am", "am%", "am:*"}},
+		{"LIKE wildcards are escaped", "50%_", 1,
+			"($1 <% p.search_text OR p.barcode = $1 OR p.search_text LIKE $2 OR p.search_vector @@ to_tsquery('simple', $4))",
+			[]interface{}{"50%_", `%50\%\_%`, `50\%\_%`, "50:*"}},
+		{"no words", "--", 1,
+			"($1 <% p.search_text OR p.barcode = $1)",
+			[]interface{}{"--", "--%"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newProductSearch(tt.term, tt.argStart)
+			if s.where != tt.where {
+				t.Errorf("where = %s\nwant    %s", s.where, tt.where)
+			}
+			if !reflect.DeepEqual(s.args, tt.args) {
+				t.Errorf("args = %#v, want %#v", s.args, tt.args)
+			}
+			// Every branch of the filter must be able to use an index
+			if strings.Contains(s.where, "product_code") {
+				t.Errorf("where filters on product_code, which has no lower() index: %s", s.where)
+			}
+		})
+	}
+}
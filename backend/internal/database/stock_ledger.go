@@ -0,0 +1,309 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// STOCK LEDGER
+// Every change to product.available_stock goes through recordStockMovement,
+// which appends a product_stock_history row. The history table is append-only.
+// Movements of scheduled products also get a controlled register entry.
+// =====================================================
+
+// stockMovement is one ledger entry. change is in base units and signed.
+type stockMovement struct {
+	productID     int
+	change        int
+	changeType    models.StockChangeType
+	referenceType string
+	referenceID   int
+	reason        models.StockAdjustmentReason
+	note          string
+	expiry        *time.Time
+	userID        int
+
+	prescriptionItemID int // the prescribed item a sale fills, for the controlled register
+}
+
+// stockEntry is what recordStockMovement wrote
+type stockEntry struct {
+	newQty     int
+	registerID int // controlled register entry, 0 for products that are not scheduled
+}
+
+// Ledger reference types for product_stock_history.reference_type
+const (
+	refInvoice    = "invoice"
+	refPurchase   = "purchase"
+	refAdjustment = "adjustment"
+	refBatch      = "batch"
+)
+
+// recordStockMovement locks the product row, writes a ledger row with the
+// previous and new quantity, and sets available_stock to the new quantity.
+// A movement that would take stock below zero is rejected.
+func recordStockMovement(tx *sql.Tx, m stockMovement) (stockEntry, error) {
+	var previous int
+	var schedule models.DrugSchedule
+	err := tx.QueryRow(
+		"SELECT COALESCE(available_stock, 0), COALESCE(schedule, '') FROM product WHERE id = $1 AND deleted = 0 FOR UPDATE",
+		m.productID,
+	).Scan(&previous, &schedule)
+	if err == sql.ErrNoRows {
+		return stockEntry{}, fmt.Errorf("product %d not found", m.productID)
+	}
+	if err != nil {
+		return stockEntry{}, fmt.Errorf("failed to lock product stock: %w", err)
+	}
+
+	entry := stockEntry{newQty: previous + m.change}
+	if entry.newQty < 0 {
+		return stockEntry{}, fmt.Errorf("%w: product %d has %d units, %d requested", ErrInsufficientStock, m.productID, previous, -m.change)
+	}
+
+	var historyID int
+	err = tx.QueryRow(`
+		INSERT INTO product_stock_history (
+			product_id_fk, change_amount, change_type, previous_quantity, new_quantity,
+			reference_type, reference_id, reason_code, note, user_id, stock_expiry
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, m.productID, m.change, m.changeType, previous, entry.newQty,
+		nullString(m.referenceType), nullInt(m.referenceID), nullString(string(m.reason)),
+		nullString(m.note), nullInt(m.userID), m.expiry).Scan(&historyID)
+	if err != nil {
+		return stockEntry{}, fmt.Errorf("failed to write stock history: %w", err)
+	}
+
+	_, err = tx.Exec(
+		"UPDATE product SET available_stock = $1, updated_at = NOW() WHERE id = $2",
+		entry.newQty, m.productID,
+	)
+	if err != nil {
+		return stockEntry{}, fmt.Errorf("failed to update product stock: %w", err)
+	}
+
+	if schedule != models.DrugScheduleNone {
+		entry.registerID, err = recordRegisterEntry(tx, m, historyID, schedule, entry.newQty)
+		if err != nil {
+			return stockEntry{}, err
+		}
+	}
+
+	return entry, nil
+}
+
+// adjustStock posts a manual 'adjustment' movement with a reason code and keeps
+// batches in step: added stock goes to an OPENING/ADJUSTMENT batch, removed
+// stock is taken from batches in expiry order (expired batches first) and
+// recorded as a write-off.
+func adjustStock(tx *sql.Tx, productID, change int, reason models.StockAdjustmentReason, note string, userID int) error {
+	if !reason.Valid() {
+		return fmt.Errorf("%w: unknown stock adjustment reason %q", ErrInvalidInput, reason)
+	}
+	if change == 0 {
+		return nil
+	}
+
+	entry, err := recordStockMovement(tx, stockMovement{
+		productID:     productID,
+		change:        change,
+		changeType:    models.StockChangeAdjustment,
+		referenceType: refAdjustment,
+		reason:        reason,
+		note:          note,
+		userID:        userID,
+	})
+	if err != nil {
+		return err
+	}
+
+	if change > 0 {
+		batchNo := "ADJUSTMENT"
+		if reason == models.AdjustmentOpeningBalance {
+			batchNo = "OPENING"
+		}
+		batchID, err := addToStockBatch(tx, productID, batchNo, change)
+		if err != nil {
+			return err
+		}
+		return recordRegisterBatches(tx, entry.registerID, []batchAllocation{{batchID: batchID, batchNo: batchNo, quantity: change}})
+	}
+
+	allocations, err := drawFromBatches(tx, productID, -change, true)
+	if err != nil {
+		return err
+	}
+	if err := recordRegisterBatches(tx, entry.registerID, allocations); err != nil {
+		return err
+	}
+	return recordWriteOffs(tx, productID, allocations, reason, note, userID)
+}
+
+// recordWriteOffs values stock taken out by an adjustment at the cost of the
+// batches it came from, for the profit and loss report
+func recordWriteOffs(tx *sql.Tx, productID int, allocations []batchAllocation, reason models.StockAdjustmentReason, note string, userID int) error {
+	for _, a := range allocations {
+		_, err := tx.Exec(`
+			INSERT INTO stock_write_off (product_id, batch_fk_id, quantity, cost_price, amount, reason, note, user_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, productID, a.batchID, a.quantity, a.costPrice, roundMoney(float64(a.quantity)*a.costPrice),
+			reason, nullString(note), nullInt(userID))
+		if err != nil {
+			return fmt.Errorf("failed to record write-off: %w", err)
+		}
+	}
+	return nil
+}
+
+// setStockLevel turns an absolute stock count (the legacy inStock overwrite)
+// into an adjustment movement for the difference
+func setStockLevel(tx *sql.Tx, productID, target int, reason models.StockAdjustmentReason, note string, userID int) error {
+	if target < 0 {
+		return fmt.Errorf("%w: stock cannot be negative", ErrInvalidInput)
+	}
+
+	var current int
+	err := tx.QueryRow(
+		"SELECT COALESCE(available_stock, 0) FROM product WHERE id = $1 AND deleted = 0 FOR UPDATE",
+		productID,
+	).Scan(&current)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get product stock: %w", err)
+	}
+
+	return adjustStock(tx, productID, target-current, reason, note, userID)
+}
+
+// adjustmentReason returns the requested reason, defaulting to a count correction
+func adjustmentReason(reason *string) models.StockAdjustmentReason {
+	if reason == nil || *reason == "" {
+		return models.AdjustmentCountCorrection
+	}
+	return models.StockAdjustmentReason(*reason)
+}
+
+// AdjustProductStock posts a manual stock adjustment for a product
+func AdjustProductStock(db *sql.DB, actor models.Actor, idStr string, req models.StockAdjustmentRequest) (*models.ProductResponse, error) {
+	id, err := parseProductID(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+	if req.Change == 0 {
+		return nil, fmt.Errorf("%w: change must not be zero", ErrInvalidInput)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := productAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := adjustStock(tx, id, req.Change, req.Reason, req.Note, actor.UserID); err != nil {
+		return nil, err
+	}
+	after, err := productAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditProduct, id, before, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetProductResponseByID(db, idStr)
+}
+
+// GetStockHistory lists a product's ledger movements, newest first
+func GetStockHistory(db *sql.DB, idStr string, page, limit int) ([]models.StockHistoryDTO, models.Pagination, error) {
+	id, err := parseProductID(idStr)
+	if err != nil {
+		return nil, models.Pagination{}, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+	offset := (page - 1) * limit
+
+	var totalItems int
+	err = db.QueryRow("SELECT COUNT(*) FROM product_stock_history WHERE product_id_fk = $1", id).Scan(&totalItems)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT id, COALESCE(change_amount, 0), change_type,
+		       COALESCE(previous_quantity, 0), COALESCE(new_quantity, 0),
+		       COALESCE(reference_type, ''), reference_id, COALESCE(reason_code, ''),
+		       COALESCE(note, ''), user_id, stock_expiry, created_at
+		FROM product_stock_history
+		WHERE product_id_fk = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3
+	`, id, limit, offset)
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	defer rows.Close()
+
+	history := []models.StockHistoryDTO{}
+	for rows.Next() {
+		var hst models.StockHistoryDTO
+		var referenceID, userID sql.NullInt64
+		var expiry sql.NullTime
+		var createdAt time.Time
+		if err := rows.Scan(&hst.ID, &hst.ChangeAmount, &hst.ChangeType,
+			&hst.PreviousQuantity, &hst.NewQuantity,
+			&hst.ReferenceType, &referenceID, &hst.Reason,
+			&hst.Note, &userID, &expiry, &createdAt); err != nil {
+			return nil, models.Pagination{}, err
+		}
+		if referenceID.Valid {
+			v := int(referenceID.Int64)
+			hst.ReferenceID = &v
+		}
+		if userID.Valid {
+			v := int(userID.Int64)
+			hst.UserID = &v
+		}
+		if expiry.Valid {
+			hst.StockExpiry = expiry.Time.Format("2006-01-02")
+		}
+		hst.CreatedAt = createdAt.Format(time.RFC3339)
+		history = append(history, hst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	totalPages := 0
+	if limit > 0 {
+		totalPages = (totalItems + limit - 1) / limit
+	}
+
+	return history, models.Pagination{
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		TotalItems:   totalItems,
+		ItemsPerPage: limit,
+	}, nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"pharmacy-backend/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// genericIngredients normalizes a generic name to its sorted set of
+// ingredients, so "Caffeine + Paracetamol" and "paracetamol, caffeine" match
+func genericIngredients(generic string) []string {
+	ingredients := splitGeneric(generic)
+	for i, name := range ingredients {
+		ingredients[i] = strings.ToLower(name)
+	}
+	sort.Strings(ingredients)
+	return ingredients
+}
+
+// normalizeStrength makes "500 mg" and "500MG" compare equal
+func normalizeStrength(strength string) string {
+	return strings.ToLower(strings.Join(strings.Fields(strength), ""))
+}
+
+// strengthSignature pairs each part of a combination strength with its
+// ingredient and sorts the pairs, so "Paracetamol + Caffeine 500mg/65mg" and
+// "Caffeine + Paracetamol 65mg/500mg" compare equal. Strengths that don't
+// split one per ingredient compare as a whole.
+func strengthSignature(generic, strength string) string {
+	names := splitGeneric(generic)
+	parts := strengthSeparator.Split(strings.TrimSpace(strength), -1)
+	if len(names) < 2 || len(parts) != len(names) {
+		return normalizeStrength(strength)
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = strings.ToLower(name) + ":" + normalizeStrength(parts[i])
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// sameIngredientsSQL selects the products whose ingredients, strengths and
+// units are exactly those of product $1
+const sameIngredientsSQL = `
+	SELECT product_id FROM (
+		SELECT product_id, ` + ingredientSignatureSQL + ` AS signature
+		FROM product_ingredient
+		WHERE product_id IN (SELECT c.product_id FROM product_ingredient c
+		                     JOIN product_ingredient t ON t.ingredient_id = c.ingredient_id AND t.product_id = $1)
+		GROUP BY product_id
+	) sig
+	WHERE signature = (SELECT ` + ingredientSignatureSQL + ` FROM product_ingredient WHERE product_id = $1)`
+
+const ingredientSignatureSQL = `string_agg(ingredient_id || ':' || COALESCE(strength::text, '') || ':' ||
+		lower(COALESCE(strength_unit, '')), ',' ORDER BY ingredient_id)`
+
+// matchingGenerics returns the generic names with the same ingredient set.
+// Generic names are few; they are matched here.
+func matchingGenerics(db *sql.DB, ingredients []string) ([]int64, error) {
+	key := strings.Join(ingredients, " + ")
+	rows, err := db.Query("SELECT id, generic_name FROM generic_name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query generic names: %w", err)
+	}
+	defer rows.Close()
+
+	var genericIDs []int64
+	for rows.Next() {
+		var genericID int64
+		var name string
+		if err := rows.Scan(&genericID, &name); err != nil {
+			return nil, fmt.Errorf("failed to scan generic name: %w", err)
+		}
+		if strings.Join(genericIngredients(name), " + ") == key {
+			genericIDs = append(genericIDs, genericID)
+		}
+	}
+	return genericIDs, rows.Err()
+}
+
+// GetSubstitutes lists in-stock products with the same ingredients and
+// strengths as a product, best margin first, then cheapest, then the soonest
+// expiring stock. Only active, unexpired batches count as in stock.
+func GetSubstitutes(db *sql.DB, idStr string) (*models.SubstitutesDTO, error) {
+	id, err := parseProductID(idStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid product ID", ErrInvalidInput)
+	}
+
+	result := models.SubstitutesDTO{ProductID: fmt.Sprintf("prod_%03d", id), Substitutes: []models.SubstituteDTO{}}
+	err = db.QueryRow(`
+		SELECT p.product_name, COALESCE(g.generic_name, ''), COALESCE(p.strength, '')
+		FROM product p
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		WHERE p.id = $1 AND p.deleted = 0
+	`, id).Scan(&result.ProductName, &result.GenericName, &result.Strength)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product: %w", err)
+	}
+
+	links, err := productIngredients(db, []int{id})
+	if err != nil {
+		return nil, err
+	}
+
+	// Products with structured ingredients match on every ingredient, strength
+	// and unit; others on their generic name's ingredients and the strength
+	// of each
+	args := []interface{}{id}
+	filter := "p.id IN (" + sameIngredientsSQL + ")"
+	if len(links[id]) > 0 {
+		for _, ing := range links[id] {
+			result.Ingredients = append(result.Ingredients, strings.ToLower(ing.Name))
+		}
+		sort.Strings(result.Ingredients)
+	} else {
+		result.Ingredients = genericIngredients(result.GenericName)
+		if len(result.Ingredients) == 0 {
+			return &result, nil
+		}
+		genericIDs, err := matchingGenerics(db, result.Ingredients)
+		if err != nil {
+			return nil, err
+		}
+		filter = "p.generic_fk_id = ANY($2)"
+		args = append(args, pq.Array(genericIDs))
+	}
+
+	rows, err := db.Query(`
+		WITH sellable AS (
+			SELECT product_id, SUM(quantity) AS quantity, MIN(expiry_date) AS nearest_expiry
+			FROM product_batch
+			WHERE status = 'active' AND quantity > 0 AND (expiry_date IS NULL OR expiry_date >= CURRENT_DATE)
+			GROUP BY product_id
+		)
+		SELECT p.id, p.product_name, COALESCE(g.generic_name, ''), COALESCE(p.strength, ''), COALESCE(p.manufacture, ''),
+		       COALESCE(p.unit_price, 0), COALESCE(p.unit_mrp, 0), COALESCE(p.unit_cost_price, 0),
+		       s.quantity, COALESCE(TO_CHAR(s.nearest_expiry, 'YYYY-MM-DD'), ''),
+		       COALESCE(r.rack_name, ''), p.requires_prescription
+		FROM product p
+		LEFT JOIN generic_name g ON p.generic_fk_id = g.id
+		JOIN sellable s ON s.product_id = p.id
+		LEFT JOIN rack r ON p.rack_fk_id = r.id
+		WHERE p.deleted = 0 AND p.id <> $1 AND `+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query substitutes: %w", err)
+	}
+	defer rows.Close()
+
+	strength := strengthSignature(result.GenericName, result.Strength)
+	for rows.Next() {
+		var s models.SubstituteDTO
+		var productID int
+		if err := rows.Scan(&productID, &s.Name, &s.GenericName, &s.Strength, &s.Manufacture,
+			&s.Price, &s.MRP, &s.BuyingPrice, &s.InStock, &s.NearestExpiry,
+			&s.RackNo, &s.RequiresPrescription); err != nil {
+			return nil, fmt.Errorf("failed to scan substitute: %w", err)
+		}
+		if len(links[id]) == 0 && strengthSignature(s.GenericName, s.Strength) != strength {
+			continue
+		}
+		s.ID = fmt.Sprintf("prod_%03d", productID)
+		s.ProfitMargin = calculateProfitMargin(s.Price, s.BuyingPrice)
+		result.Substitutes = append(result.Substitutes, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result.Substitutes, func(i, j int) bool {
+		a, b := result.Substitutes[i], result.Substitutes[j]
+		if a.ProfitMargin != b.ProfitMargin {
+			return a.ProfitMargin > b.ProfitMargin
+		}
+		if a.Price != b.Price {
+			return a.Price < b.Price
+		}
+		if a.NearestExpiry != b.NearestExpiry {
+			// Undated stock goes last
+			return b.NearestExpiry == "" || (a.NearestExpiry != "" && a.NearestExpiry < b.NearestExpiry)
+		}
+		return a.Name < b.Name
+	})
+	return &result, nil
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenericIngredients(t *testing.T) {
+	tests := []struct {
+		generic string
+		want    []string
+	}{
+		{"Paracetamol", []string{"paracetamol"}},
+		{"Paracetamol + Caffeine", []string{"caffeine", "paracetamol"}},
+		{"caffeine, paracetamol", []string{"caffeine", "paracetamol"}},
+		{"Amoxicillin/Clavulanic Acid", []string{"amoxicillin", "clavulanic acid"}},
+		{"Paracetamol and Caffeine", []string{"caffeine", "paracetamol"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := genericIngredients(tt.generic); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("genericIngredients(%q) = %q, want %q", tt.generic, got, tt.want)
+		}
+	}
+}
+
+func TestStrengthSignature(t *testing.T) {
+	tests := []struct {
+		name                string
+		genericA, strengthA string
+		genericB, strengthB string
+		same                bool
+	}{
+		{"same text", "Paracetamol", "500 mg", "Paracetamol", "500MG", true},
+		{"different strength", "Paracetamol", "500mg", "Paracetamol", "650mg", false},
+		{"combination in another order", "Paracetamol + Caffeine", "500mg/65mg", "Caffeine + Paracetamol", "65mg/500mg", true},
+		{"combination with strengths swapped", "Paracetamol + Caffeine", "500mg/65mg", "Caffeine + Paracetamol", "500mg/65mg", false},
+		{"combination with other separators", "Rosuvastatin + Ezetimibe", "10mg + 10mg", "Ezetimibe, Rosuvastatin", "10 mg, 10 mg", true},
+		{"single ingredient keeps its concentration", "Amoxicillin", "125mg/5ml", "Amoxicillin", "125 mg / 5 ml", true},
+		{"concentration differs", "Amoxicillin", "125mg/5ml", "Amoxicillin", "250mg/5ml", false},
+		{"strength not per ingredient", "Paracetamol + Caffeine", "565mg", "Caffeine + Paracetamol", "565 mg", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := strengthSignature(tt.genericA, tt.strengthA)
+			b := strengthSignature(tt.genericB, tt.strengthB)
+			if (a == b) != tt.same {
+				t.Errorf("strengthSignature(%q, %q) = %q, strengthSignature(%q, %q) = %q; same = %v, want %v",
+					tt.genericA, tt.strengthA, a, tt.genericB, tt.strengthB, b, a == b, tt.same)
+			}
+		})
+	}
+}
@@ -124,7 +124,13 @@ func GetSupplierCompanies(db *sql.DB) ([]string, error) {
 }
 
 // AddSupplier adds a new supplier
-func AddSupplier(db *sql.DB, req models.CreateSupplierRequest) (models.SupplierDTO, error) {
+func AddSupplier(db *sql.DB, actor models.Actor, req models.CreateSupplierRequest) (models.SupplierDTO, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.SupplierDTO{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO supplier (name, company, contact, email, address, status)
 		VALUES ($1, $2, $3, $4, $5, 'Active')
@@ -132,11 +138,23 @@ func AddSupplier(db *sql.DB, req models.CreateSupplierRequest) (models.SupplierD
 	`
 
 	var idInt int
-	err := db.QueryRow(query, req.Name, req.Company, req.Phone, req.Email, req.Address).Scan(&idInt)
+	err = tx.QueryRow(query, req.Name, req.Company, req.Phone, req.Email, req.Address).Scan(&idInt)
 	if err != nil {
 		return models.SupplierDTO{}, err
 	}
 
+	after, err := supplierAuditSnapshot(tx, idInt)
+	if err != nil {
+		return models.SupplierDTO{}, err
+	}
+	if err := writeAuditDiff(tx, actor, auditSupplier, idInt, nil, after); err != nil {
+		return models.SupplierDTO{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.SupplierDTO{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return models.SupplierDTO{
 		ID:      fmt.Sprintf("SUP-%03d", idInt),
 		Name:    req.Name,
@@ -149,12 +167,18 @@ func AddSupplier(db *sql.DB, req models.CreateSupplierRequest) (models.SupplierD
 }
 
 // UpdateSupplier updates an existing supplier
-func UpdateSupplier(db *sql.DB, id int, req models.UpdateSupplierRequest) (models.SupplierDTO, error) {
+func UpdateSupplier(db *sql.DB, actor models.Actor, id int, req models.UpdateSupplierRequest) (models.SupplierDTO, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return models.SupplierDTO{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	// First get existing supplier
-	existingQuery := `SELECT name, company, contact, email, address, status FROM supplier WHERE id = $1 AND deleted = 0`
+	existingQuery := `SELECT name, company, contact, email, address, status FROM supplier WHERE id = $1 AND deleted = 0 FOR UPDATE`
 	var currentName, currentCompany, currentPhone, currentEmail, currentAddress, currentStatus string
 
-	err := db.QueryRow(existingQuery, id).Scan(&currentName, &currentCompany, &currentPhone, &currentEmail, &currentAddress, &currentStatus)
+	err = tx.QueryRow(existingQuery, id).Scan(&currentName, &currentCompany, &currentPhone, &currentEmail, &currentAddress, &currentStatus)
 	if err != nil {
 		return models.SupplierDTO{}, err
 	}
@@ -187,11 +211,28 @@ func UpdateSupplier(db *sql.DB, id int, req models.UpdateSupplierRequest) (model
 		WHERE id = $6
 	`
 
-	_, err = db.Exec(updateQuery, name, company, phone, email, address, id)
+	before, err := supplierAuditSnapshot(tx, id)
 	if err != nil {
 		return models.SupplierDTO{}, err
 	}
 
+	_, err = tx.Exec(updateQuery, name, company, phone, email, address, id)
+	if err != nil {
+		return models.SupplierDTO{}, err
+	}
+
+	after, err := supplierAuditSnapshot(tx, id)
+	if err != nil {
+		return models.SupplierDTO{}, err
+	}
+	if err := writeAuditDiff(tx, actor, auditSupplier, id, before, after); err != nil {
+		return models.SupplierDTO{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.SupplierDTO{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return models.SupplierDTO{
 		ID:      fmt.Sprintf("SUP-%03d", id),
 		Name:    name,
@@ -204,21 +245,138 @@ func UpdateSupplier(db *sql.DB, id int, req models.UpdateSupplierRequest) (model
 }
 
 // DeleteSupplier soft deletes a supplier
-func DeleteSupplier(db *sql.DB, id int) error {
-	query := `UPDATE supplier SET deleted = 1, deleted_at = NOW() WHERE id = $1`
-	result, err := db.Exec(query, id)
+func DeleteSupplier(db *sql.DB, actor models.Actor, id int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+	err = tx.QueryRow(
+		"UPDATE supplier SET deleted = 1, deleted_at = NOW() WHERE id = $1 AND deleted = 0 RETURNING name", id,
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("supplier not found")
+	}
 	if err != nil {
 		return err
 	}
+	if err := writeAudit(tx, actor, auditSupplier, id, auditDelete, "", &name, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	rows, err := result.RowsAffected()
+// recentSupplierPurchases is how many purchases the supplier detail shows
+const recentSupplierPurchases = 10
+
+// GetSupplierByID returns a supplier with its products, recent purchases,
+// purchase stats and open return credits
+func GetSupplierByID(db *sql.DB, id int) (*models.SupplierDetailDTO, error) {
+	var d models.SupplierDetailDTO
+	var company, phone, email, address, status sql.NullString
+	err := db.QueryRow(
+		`SELECT name, company, contact, email, address, status FROM supplier WHERE id = $1 AND deleted = 0`, id,
+	).Scan(&d.Name, &company, &phone, &email, &address, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("supplier not found")
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+	d.ID = fmt.Sprintf("SUP-%03d", id)
+	d.Company = company.String
+	d.Phone = phone.String
+	d.Email = email.String
+	d.Address = address.String
+	d.Status = status.String
+	if d.Status == "" {
+		d.Status = "Active"
+	}
+
+	d.Products, err = supplierProducts(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.RecentPurchases, _, err = GetPurchases(db, 1, recentSupplierPurchases, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lead time only counts purchases recorded with an order date
+	var lastPurchase sql.NullTime
+	var leadTime sql.NullFloat64
+	err = db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(total), 0),
+		       MAX(COALESCE(received_date, created_at::date)),
+		       AVG(received_date - ordered_date) FILTER (WHERE ordered_date IS NOT NULL)
+		FROM product_stock_purchase
+		WHERE supplier_id = $1
+	`, id).Scan(&d.Stats.TotalPurchases, &d.Stats.TotalSpend, &lastPurchase, &leadTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier purchase stats: %w", err)
+	}
+	d.Stats.TotalSpend = roundMoney(d.Stats.TotalSpend)
+	if lastPurchase.Valid {
+		d.Stats.LastPurchaseAt = lastPurchase.Time.Format("2006-01-02")
+	}
+	if leadTime.Valid {
+		days := math.Round(leadTime.Float64*10) / 10
+		d.Stats.AverageLeadTimeDays = &days
 	}
 
-	if rows == 0 {
-		return sql.ErrNoRows
+	d.Stats.Payable, err = supplierPayable(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	d.ReturnCredits, err = GetSupplierReturnCredits(db, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// supplierProducts lists the live products a supplier is linked to, primary
+// supplier links first
+func supplierProducts(db *sql.DB, supplierID int) ([]models.SupplierProductDTO, error) {
+	rows, err := db.Query(`
+		SELECT p.id, p.product_name, COALESCE(p.strength, ''), COALESCE(ps.is_primary, FALSE),
+		       COALESCE(ps.buying_price, 0), last.received, COALESCE(p.available_stock, 0)
+		FROM product_supplier ps
+		JOIN product p ON ps.product_id = p.id
+		LEFT JOIN LATERAL (
+			SELECT MAX(COALESCE(psp.received_date, psp.created_at::date)) AS received
+			FROM product_stock_purchase_items pi
+			JOIN product_stock_purchase psp ON pi.psp_fk_id = psp.id
+			WHERE pi.product_id = p.id AND psp.supplier_id = ps.supplier_id
+		) last ON TRUE
+		WHERE ps.supplier_id = $1 AND p.deleted = 0
+		ORDER BY ps.is_primary DESC, p.product_name
+	`, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query supplier products: %w", err)
 	}
+	defer rows.Close()
 
-	return nil
+	products := []models.SupplierProductDTO{}
+	for rows.Next() {
+		var sp models.SupplierProductDTO
+		var productID int
+		var lastPurchased sql.NullTime
+		if err := rows.Scan(&productID, &sp.Name, &sp.Strength, &sp.IsPrimary,
+			&sp.BuyingPrice, &lastPurchased, &sp.AvailableStock); err != nil {
+			return nil, fmt.Errorf("failed to scan supplier product: %w", err)
+		}
+		sp.ProductID = fmt.Sprintf("prod_%03d", productID)
+		if lastPurchased.Valid {
+			sp.LastPurchasedAt = lastPurchased.Time.Format("2006-01-02")
+		}
+		products = append(products, sp)
+	}
+	return products, rows.Err()
 }
@@ -0,0 +1,399 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// SUPPLIER PAYABLES
+// What we owe a supplier is the sum of due over their purchases. Payments and
+// company return credits are applied to open purchases oldest first; credit
+// with nothing left to apply to stays unapplied until the next purchase.
+// =====================================================
+
+// purchaseStatus derives a purchase's payment status from its amounts
+func purchaseStatus(paid, due float64) models.PurchaseStatus {
+	switch {
+	case due <= 0:
+		return models.PurchaseStatusPaid
+	case paid <= 0:
+		return models.PurchaseStatusDue
+	}
+	return models.PurchaseStatusPartial
+}
+
+func formatPurchaseNo(id int) string {
+	return fmt.Sprintf("PUR-%06d", id)
+}
+
+func formatSupplierPaymentNo(id int) string {
+	return fmt.Sprintf("PAY-%06d", id)
+}
+
+// supplierPayable sums the due amounts of a supplier's purchases
+func supplierPayable(q rowQueryer, supplierID int) (float64, error) {
+	var payable float64
+	err := q.QueryRow(
+		"SELECT COALESCE(SUM(due), 0) FROM product_stock_purchase WHERE supplier_id = $1 AND due > 0",
+		supplierID,
+	).Scan(&payable)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get supplier payable: %w", err)
+	}
+	return roundMoney(payable), nil
+}
+
+// supplierPayment is a payment or credit about to be applied
+type supplierPayment struct {
+	supplierID      int
+	amount          float64
+	method          models.PaymentMethod
+	reference       string
+	notes           string
+	companyReturnID int
+}
+
+// openPurchase is a purchase with something still due
+type openPurchase struct {
+	id         int
+	invoiceRef string
+	paid, due  float64
+}
+
+// lockOpenPurchases locks a supplier's purchases with something due, oldest
+// first
+func lockOpenPurchases(tx *sql.Tx, supplierID int) ([]openPurchase, error) {
+	rows, err := tx.Query(`
+		SELECT id, COALESCE(invoice_ref, ''), COALESCE(paid_amount, 0), COALESCE(due, 0)
+		FROM product_stock_purchase
+		WHERE supplier_id = $1 AND due > 0
+		ORDER BY COALESCE(received_date, created_at::date), id
+		FOR UPDATE
+	`, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query open purchases: %w", err)
+	}
+	defer rows.Close()
+
+	var open []openPurchase
+	for rows.Next() {
+		var o openPurchase
+		if err := rows.Scan(&o.id, &o.invoiceRef, &o.paid, &o.due); err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		open = append(open, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating purchases: %w", err)
+	}
+	return open, nil
+}
+
+// allocateSupplierPayment applies amount of a payment to the open purchases
+// in order, updating them in place, and returns what is left over
+func allocateSupplierPayment(tx *sql.Tx, paymentID int, amount float64, open []openPurchase) ([]models.PurchaseAllocationDTO, float64, error) {
+	allocations := []models.PurchaseAllocationDTO{}
+	remaining := amount
+	for i := range open {
+		o := &open[i]
+		if remaining <= 0 {
+			break
+		}
+		if o.due <= 0 {
+			continue
+		}
+		applied := roundMoney(math.Min(remaining, o.due))
+		remaining = roundMoney(remaining - applied)
+		o.paid = roundMoney(o.paid + applied)
+		o.due = roundMoney(o.due - applied)
+		status := purchaseStatus(o.paid, o.due)
+
+		_, err := tx.Exec(`
+			UPDATE product_stock_purchase SET paid_amount = $1, due = $2, purchase_status = $3
+			WHERE id = $4
+		`, o.paid, o.due, status, o.id)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to update purchase: %w", err)
+		}
+		_, err = tx.Exec(
+			"INSERT INTO supplier_payment_allocation (payment_id, purchase_id, amount) VALUES ($1, $2, $3)",
+			paymentID, o.id, applied,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to record payment allocation: %w", err)
+		}
+
+		allocations = append(allocations, models.PurchaseAllocationDTO{
+			PurchaseID: o.id,
+			InvoiceRef: o.invoiceRef,
+			Amount:     applied,
+			Due:        o.due,
+			Status:     status,
+		})
+	}
+	return allocations, remaining, nil
+}
+
+// applyUnappliedCredit applies return credit left unapplied, oldest first,
+// to the supplier's open purchases. Runs when a purchase is received, so
+// credit is netted against the next deliveries instead of sitting idle.
+func applyUnappliedCredit(tx *sql.Tx, supplierID int) error {
+	if _, err := tx.Exec("SELECT 1 FROM supplier WHERE id = $1 FOR UPDATE", supplierID); err != nil {
+		return fmt.Errorf("failed to lock supplier: %w", err)
+	}
+
+	rows, err := tx.Query(`
+		SELECT sp.id, sp.amount - COALESCE(SUM(a.amount), 0)
+		FROM supplier_payment sp
+		LEFT JOIN supplier_payment_allocation a ON a.payment_id = sp.id
+		WHERE sp.supplier_id = $1
+		GROUP BY sp.id
+		HAVING sp.amount - COALESCE(SUM(a.amount), 0) > 0
+		ORDER BY sp.created_at, sp.id
+	`, supplierID)
+	if err != nil {
+		return fmt.Errorf("failed to query unapplied credit: %w", err)
+	}
+	type credit struct {
+		paymentID int
+		unapplied float64
+	}
+	var credits []credit
+	for rows.Next() {
+		var c credit
+		if err := rows.Scan(&c.paymentID, &c.unapplied); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan credit: %w", err)
+		}
+		credits = append(credits, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating credits: %w", err)
+	}
+	if len(credits) == 0 {
+		return nil
+	}
+
+	open, err := lockOpenPurchases(tx, supplierID)
+	if err != nil {
+		return err
+	}
+	for _, c := range credits {
+		if _, _, err := allocateSupplierPayment(tx, c.paymentID, roundMoney(c.unapplied), open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySupplierPayment records a payment or return credit and applies it to
+// the supplier's open purchases, oldest first. Payments may not exceed what
+// is owed; credits may, and the rest is left for the next purchases.
+func applySupplierPayment(tx *sql.Tx, actor models.Actor, p supplierPayment) (*models.SupplierPaymentDTO, error) {
+	var name string
+	err := tx.QueryRow("SELECT name FROM supplier WHERE id = $1 FOR UPDATE", p.supplierID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("supplier not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+
+	open, err := lockOpenPurchases(tx, p.supplierID)
+	if err != nil {
+		return nil, err
+	}
+	var payable float64
+	for _, o := range open {
+		payable += o.due
+	}
+	payable = roundMoney(payable)
+	if p.companyReturnID == 0 && p.amount > payable {
+		return nil, fmt.Errorf("%w: payment of %.2f exceeds the %.2f owed to the supplier", ErrInvalidInput, p.amount, payable)
+	}
+
+	payment := &models.SupplierPaymentDTO{
+		SupplierID: fmt.Sprintf("SUP-%03d", p.supplierID),
+		Amount:     p.amount,
+		Method:     p.method,
+		Reference:  p.reference,
+		Notes:      p.notes,
+	}
+	if p.companyReturnID != 0 {
+		payment.CompanyReturnID = &p.companyReturnID
+	}
+
+	var createdAt time.Time
+	err = tx.QueryRow(`
+		INSERT INTO supplier_payment (supplier_id, amount, method, reference, notes, company_return_id, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`, p.supplierID, p.amount, nullString(string(p.method)), nullString(p.reference), nullString(p.notes),
+		nullInt(p.companyReturnID), nullInt(actor.UserID)).Scan(&payment.ID, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert supplier payment: %w", err)
+	}
+
+	allocations, remaining, err := allocateSupplierPayment(tx, payment.ID, p.amount, open)
+	if err != nil {
+		return nil, err
+	}
+	payment.Allocations = allocations
+
+	payment.PaymentNo = formatSupplierPaymentNo(payment.ID)
+	payment.CreatedAt = createdAt.Format(time.RFC3339)
+	payment.Unapplied = remaining
+	payment.Payable = roundMoney(payable - (p.amount - remaining))
+	return payment, nil
+}
+
+// CreateSupplierPayment records a payment to a supplier
+func CreateSupplierPayment(db *sql.DB, actor models.Actor, supplierID int, req models.SupplierPaymentRequest) (*models.SupplierPaymentDTO, error) {
+	req.Amount = roundMoney(req.Amount)
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%w: payment amount must be positive", ErrInvalidInput)
+	}
+	if req.Method == "" {
+		req.Method = models.PaymentCash
+	}
+	if !req.Method.Valid() {
+		return nil, fmt.Errorf("%w: unknown payment method %q", ErrInvalidInput, req.Method)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM supplier WHERE id = $1 AND deleted = 0)", supplierID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("supplier not found")
+	}
+
+	payment, err := applySupplierPayment(tx, actor, supplierPayment{
+		supplierID: supplierID,
+		amount:     req.Amount,
+		method:     req.Method,
+		reference:  req.Reference,
+		notes:      req.Notes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return payment, nil
+}
+
+// GetSupplierLedger returns a supplier's statement with a running balance of
+// what we owe. from and to (YYYY-MM-DD, inclusive) are optional; entries
+// before from are carried in the opening balance.
+func GetSupplierLedger(db *sql.DB, supplierID int, from, to string) (*models.SupplierLedgerDTO, error) {
+	ledger := &models.SupplierLedgerDTO{
+		SupplierID: fmt.Sprintf("SUP-%03d", supplierID),
+		From:       from,
+		To:         to,
+		Entries:    []models.SupplierLedgerEntryDTO{},
+	}
+	err := db.QueryRow("SELECT name FROM supplier WHERE id = $1 AND deleted = 0", supplierID).Scan(&ledger.Name)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("supplier not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get supplier: %w", err)
+	}
+
+	// What was paid with the delivery is the purchase's paid amount less what
+	// later payments and credits added to it
+	rows, err := db.Query(`
+		SELECT at, to_char(at, 'YYYY-MM-DD'), type, ref_id, note, debit, credit FROM (
+			SELECT COALESCE(psp.received_date, psp.created_at::date)::timestamp AS at, 1 AS seq,
+			       'purchase' AS type, psp.id AS ref_id, COALESCE(psp.invoice_ref, '') AS note,
+			       0 AS debit, COALESCE(psp.total, 0) AS credit
+			FROM product_stock_purchase psp
+			WHERE psp.supplier_id = $1
+			UNION ALL
+			SELECT COALESCE(psp.received_date, psp.created_at::date)::timestamp, 2, 'paid_on_receipt', psp.id,
+			       COALESCE(psp.invoice_ref, ''), COALESCE(psp.paid_amount, 0) - COALESCE(a.allocated, 0), 0
+			FROM product_stock_purchase psp
+			LEFT JOIN (
+				SELECT purchase_id, SUM(amount) AS allocated
+				FROM supplier_payment_allocation GROUP BY purchase_id
+			) a ON a.purchase_id = psp.id
+			WHERE psp.supplier_id = $1
+			  AND COALESCE(psp.paid_amount, 0) - COALESCE(a.allocated, 0) > 0
+			UNION ALL
+			SELECT sp.created_at, 3,
+			       CASE WHEN sp.company_return_id IS NULL THEN 'payment' ELSE 'return_credit' END,
+			       COALESCE(sp.company_return_id, sp.id), COALESCE(sp.reference, sp.notes, ''), sp.amount, 0
+			FROM supplier_payment sp
+			WHERE sp.supplier_id = $1
+		) entries
+		ORDER BY at, seq, ref_id
+	`, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query supplier ledger: %w", err)
+	}
+	defer rows.Close()
+
+	var balance float64
+	for rows.Next() {
+		var e models.SupplierLedgerEntryDTO
+		var at time.Time
+		var refID int
+		if err := rows.Scan(&at, &e.Date, &e.Type, &refID, &e.Note, &e.Debit, &e.Credit); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		balance = roundMoney(balance + e.Credit - e.Debit)
+		e.Balance = balance
+
+		if from != "" && e.Date < from {
+			ledger.OpeningBalance = balance
+			continue
+		}
+		if to != "" && e.Date > to {
+			break
+		}
+
+		switch e.Type {
+		case models.LedgerPurchase, models.LedgerPaidOnReceipt:
+			e.Reference = formatPurchaseNo(refID)
+		case models.LedgerPayment:
+			e.Reference = formatSupplierPaymentNo(refID)
+		case models.LedgerReturnCredit:
+			e.Reference = fmt.Sprintf("CR-%06d", refID)
+		}
+		ledger.Entries = append(ledger.Entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger entries: %w", err)
+	}
+
+	ledger.ClosingBalance = ledger.OpeningBalance
+	if n := len(ledger.Entries); n > 0 {
+		ledger.ClosingBalance = ledger.Entries[n-1].Balance
+	}
+
+	if ledger.Payable, err = supplierPayable(db, supplierID); err != nil {
+		return nil, err
+	}
+	credits, err := GetSupplierReturnCredits(db, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	ledger.OpenCredit = credits.OpenCredit
+	return ledger, nil
+}
@@ -0,0 +1,275 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"pharmacy-backend/internal/models"
+)
+
+// =====================================================
+// USERS & REFRESH TOKENS
+// =====================================================
+
+// userRecord is an app_user row including its password hash
+type userRecord struct {
+	models.UserDTO
+	passwordHash string
+}
+
+const userColumns = `
+	SELECT id, username, COALESCE(full_name, ''), role, active, last_login_at, created_at, password_hash
+	FROM app_user`
+
+func scanUser(row rowScanner) (*userRecord, error) {
+	var u userRecord
+	var lastLogin sql.NullTime
+	var createdAt time.Time
+	if err := row.Scan(&u.ID, &u.Username, &u.FullName, &u.Role, &u.Active,
+		&lastLogin, &createdAt, &u.passwordHash); err != nil {
+		return nil, err
+	}
+	if lastLogin.Valid {
+		u.LastLoginAt = lastLogin.Time.Format(time.RFC3339)
+	}
+	u.CreatedAt = createdAt.Format(time.RFC3339)
+	return &u, nil
+}
+
+// GetUserCredentials returns a user and their password hash by username
+func GetUserCredentials(db *sql.DB, username string) (*models.UserDTO, string, error) {
+	u, err := scanUser(db.QueryRow(userColumns+" WHERE LOWER(username) = LOWER($1)", strings.TrimSpace(username)))
+	if err == sql.ErrNoRows {
+		return nil, "", fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get user: %w", err)
+	}
+	return &u.UserDTO, u.passwordHash, nil
+}
+
+// GetUserByID retrieves a user
+func GetUserByID(db *sql.DB, id int) (*models.UserDTO, error) {
+	u, err := scanUser(db.QueryRow(userColumns+" WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &u.UserDTO, nil
+}
+
+// GetUsers lists all users
+func GetUsers(db *sql.DB) ([]models.UserDTO, error) {
+	rows, err := db.Query(userColumns + " ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.UserDTO{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, u.UserDTO)
+	}
+	return users, rows.Err()
+}
+
+// CountUsers returns the number of users
+func CountUsers(db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM app_user").Scan(&n)
+	return n, err
+}
+
+// CreateUser inserts a user with an already hashed password
+func CreateUser(db *sql.DB, actor models.Actor, req models.CreateUserRequest, passwordHash string) (*models.UserDTO, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		return nil, fmt.Errorf("%w: username is required", ErrInvalidInput)
+	}
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, req.Role)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS(SELECT 1 FROM app_user WHERE LOWER(username) = LOWER($1))", username).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check username: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("%w: username %q is taken", ErrInvalidInput, username)
+	}
+
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO app_user (username, password_hash, full_name, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, username, passwordHash, nullString(req.FullName), req.Role).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert user: %w", err)
+	}
+
+	after, err := userAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditUser, id, nil, after); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetUserByID(db, id)
+}
+
+// UpdateUser changes a user's name, role, active flag or password hash.
+// Deactivating a user or changing their password revokes their refresh tokens.
+// Password changes are audited without their values.
+func UpdateUser(db *sql.DB, actor models.Actor, id int, req models.UpdateUserRequest, passwordHash string) (*models.UserDTO, error) {
+	if req.Role != nil && !req.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", ErrInvalidInput, *req.Role)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	before, err := userAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE app_user
+		SET full_name = COALESCE($1, full_name),
+		    role = COALESCE($2, role),
+		    active = COALESCE($3, active),
+		    password_hash = COALESCE($4, password_hash),
+		    updated_at = NOW()
+		WHERE id = $5
+	`, req.FullName, req.Role, req.Active, nullString(passwordHash), id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	after, err := userAuditSnapshot(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeAuditDiff(tx, actor, auditUser, id, before, after); err != nil {
+		return nil, err
+	}
+	if passwordHash != "" {
+		if err := writeAudit(tx, actor, auditUser, id, auditUpdate, "password", nil, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if passwordHash != "" || (req.Active != nil && !*req.Active) {
+		_, err = tx.Exec("UPDATE refresh_token SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetUserByID(db, id)
+}
+
+// RecordLogin stores a new refresh token hash and the login time
+func RecordLogin(db *sql.DB, userID int, tokenHash string, expiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE app_user SET last_login_at = NOW() WHERE id = $1", userID); err != nil {
+		return fmt.Errorf("failed to update last login: %w", err)
+	}
+	if err := insertRefreshToken(tx, userID, tokenHash, expiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RotateRefreshToken revokes a valid refresh token, stores its replacement and
+// returns the owning user. Expired, revoked and unknown tokens are rejected.
+func RotateRefreshToken(db *sql.DB, oldHash, newHash string, expiresAt time.Time) (*models.UserDTO, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tokenID, userID int
+	var active bool
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.user_id, u.active
+		FROM refresh_token rt
+		JOIN app_user u ON rt.user_id = u.id
+		WHERE rt.token_hash = $1 AND rt.revoked_at IS NULL AND rt.expires_at > NOW()
+		FOR UPDATE OF rt
+	`, oldHash).Scan(&tokenID, &userID, &active)
+	if err == sql.ErrNoRows || (err == nil && !active) {
+		return nil, fmt.Errorf("%w: refresh token is invalid or expired", ErrInvalidInput)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE refresh_token SET revoked_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if err := insertRefreshToken(tx, userID, newHash, expiresAt); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return GetUserByID(db, userID)
+}
+
+// RevokeRefreshToken revokes a refresh token (logout)
+func RevokeRefreshToken(db *sql.DB, tokenHash string) error {
+	_, err := db.Exec("UPDATE refresh_token SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL", tokenHash)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	return nil
+}
+
+func insertRefreshToken(tx *sql.Tx, userID int, tokenHash string, expiresAt time.Time) error {
+	_, err := tx.Exec(
+		"INSERT INTO refresh_token (user_id, token_hash, expires_at) VALUES ($1, $2, $3)",
+		userID, tokenHash, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pharmacy-backend/internal/database"
+)
+
+// parseAuditEntityID accepts the ID format the entity's own API uses
+// ("prod_001", "SUP-001") as well as plain numeric IDs
+func parseAuditEntityID(entity, idStr string) (int, error) {
+	switch entity {
+	case "product":
+		return strconv.Atoi(strings.TrimPrefix(idStr, "prod_"))
+	case "supplier":
+		return parseSupplierID(idStr)
+	}
+	return strconv.Atoi(idStr)
+}
+
+// GetAuditLog handles GET /api/audit
+// Query: entity (product, supplier, customer, user), id, user, page, limit
+func (h *Handler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 50
+	}
+
+	entity := query.Get("entity")
+	switch entity {
+	case "", "product", "supplier", "customer", "user":
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "entity must be product, supplier, customer or user"})
+		return
+	}
+
+	entityID := 0
+	if s := query.Get("id"); s != "" {
+		if entity == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "id requires entity"})
+			return
+		}
+		id, err := parseAuditEntityID(entity, s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid " + entity + " ID"})
+			return
+		}
+		entityID = id
+	}
+
+	userID := 0
+	if s := query.Get("user"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid user ID"})
+			return
+		}
+		userID = id
+	}
+
+	entries, pagination, err := database.GetAuditLog(h.db, entity, entityID, userID, page, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       entries,
+		"pagination": pagination,
+	})
+}
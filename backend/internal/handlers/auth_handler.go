@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// dummyHash is checked when a username does not exist so that failed logins
+// take the same time either way
+var dummyHash, _ = auth.HashPassword("not-a-real-password")
+
+// Login handles POST /api/auth/login
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	user, hash, err := database.GetUserCredentials(h.db, req.Username)
+	if err != nil {
+		hash = dummyHash
+	}
+	ok, _ := auth.CheckPassword(hash, req.Password)
+	if err != nil || !ok || !user.Active {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid username or password"})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err == nil {
+		err = database.RecordLogin(h.db, user.ID, refreshHash, time.Now().Add(h.tokens.RefreshTTL()))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	h.writeTokens(w, user, refreshToken)
+}
+
+// RefreshToken handles POST /api/auth/refresh
+// Exchanges a refresh token for a new access token and refresh token
+func (h *Handler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	user, err := database.RotateRefreshToken(h.db, auth.HashRefreshToken(req.RefreshToken), refreshHash,
+		time.Now().Add(h.tokens.RefreshTTL()))
+	if err != nil {
+		status := errorStatus(err)
+		if status == http.StatusBadRequest {
+			status = http.StatusUnauthorized
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	h.writeTokens(w, user, refreshToken)
+}
+
+// Logout handles POST /api/auth/logout
+// Revokes the given refresh token; the access token expires on its own
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	if err := database.RevokeRefreshToken(h.db, auth.HashRefreshToken(req.RefreshToken)); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Logged out",
+	})
+}
+
+// Me handles GET /api/auth/me
+func (h *Handler) Me(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := database.GetUserByID(h.db, auth.ActorFrom(r.Context()).UserID)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
+
+func (h *Handler) writeTokens(w http.ResponseWriter, user *models.UserDTO, refreshToken string) {
+	accessToken, expiresAt, err := h.tokens.IssueAccessToken(user.ID, user.Username, user.Role)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data": models.TokenResponse{
+			AccessToken:  accessToken,
+			TokenType:    "Bearer",
+			ExpiresAt:    expiresAt.Format(time.RFC3339),
+			RefreshToken: refreshToken,
+			User:         *user,
+		},
+	})
+}
+
+// GetUsers handles GET /api/users
+func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	users, err := database.GetUsers(h.db)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    users,
+	})
+}
+
+// CreateUser handles POST /api/users
+func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	if len(req.Password) < auth.MinPasswordLength {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Password must be at least 8 characters"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	user, err := database.CreateUser(h.db, auth.ActorFrom(r.Context()), req, hash)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
+
+// UpdateUser handles PUT /api/users/{id}
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid user ID"})
+		return
+	}
+
+	var req models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	// Owners cannot lock themselves out
+	if id == auth.ActorFrom(r.Context()).UserID &&
+		((req.Active != nil && !*req.Active) || (req.Role != nil && *req.Role != models.RoleOwner)) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "You cannot deactivate or demote yourself"})
+		return
+	}
+
+	var hash string
+	if req.Password != nil {
+		if len(*req.Password) < auth.MinPasswordLength {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Password must be at least 8 characters"})
+			return
+		}
+		if hash, err = auth.HashPassword(*req.Password); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	user, err := database.UpdateUser(h.db, auth.ActorFrom(r.Context()), id, req, hash)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    user,
+	})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateCompanyReturn handles POST /api/company-returns
+// Sends batches back to their supplier and opens a return credit
+func (h *Handler) CreateCompanyReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateCompanyReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	supplierID, err := parseSupplierID(req.SupplierID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	ret, err := database.CreateCompanyReturn(h.db, auth.ActorFrom(r.Context()), supplierID, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ret,
+	})
+}
+
+// GetCompanyReturns handles GET /api/company-returns
+func (h *Handler) GetCompanyReturns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	supplierID := 0
+	if s := query.Get("supplier"); s != "" {
+		id, err := parseSupplierID(s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+			return
+		}
+		supplierID = id
+	}
+
+	returns, pagination, err := database.GetCompanyReturns(h.db, page, limit, supplierID, query.Get("status"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       returns,
+		"pagination": pagination,
+	})
+}
+
+// GetCompanyReturn handles GET /api/company-returns/{id}
+func (h *Handler) GetCompanyReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid return ID"})
+		return
+	}
+
+	ret, err := database.GetCompanyReturnByID(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ret,
+	})
+}
+
+// SettleCompanyReturn handles POST /api/company-returns/{id}/settle
+// Records credit received from the supplier against a return
+func (h *Handler) SettleCompanyReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid return ID"})
+		return
+	}
+
+	var req models.SettleCompanyReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	ret, err := database.SettleCompanyReturn(h.db, auth.ActorFrom(r.Context()), id, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ret,
+	})
+}
+
+// GetSupplierReturnCredits handles GET /api/suppliers/{id}/return-credits
+func (h *Handler) GetSupplierReturnCredits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	supplierID, err := parseSupplierID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	credits, err := database.GetSupplierReturnCredits(h.db, supplierID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    credits,
+	})
+}
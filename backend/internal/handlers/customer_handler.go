@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"pharmacy-backend/internal/auth"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/models"
 
@@ -46,6 +47,41 @@ func (h *Handler) GetCustomers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetCustomer handles GET /api/customers/{id}
+// Query: page, limit (for the customer's invoices, newest first)
+func (h *Handler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid customer ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = 10
+	}
+
+	customer, pagination, err := database.GetCustomerDetail(h.db, id, page, limit)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       customer,
+		"pagination": pagination,
+	})
+}
+
 // CreateCustomer handles POST /api/customers
 func (h *Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -64,9 +100,9 @@ func (h *Handler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	customer, err := database.CreateCustomer(h.db, req)
+	customer, err := database.CreateCustomer(h.db, auth.ActorFrom(r.Context()), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to create customer: " + err.Error()})
 		return
 	}
@@ -101,14 +137,14 @@ func (h *Handler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	customer, err := database.UpdateCustomer(h.db, id, req)
+	customer, err := database.UpdateCustomer(h.db, auth.ActorFrom(r.Context()), id, req)
 	if err != nil {
 		if err.Error() == "customer not found" {
 			w.WriteHeader(http.StatusNotFound)
 			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Customer not found"})
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to update customer: " + err.Error()})
 		return
 	}
@@ -135,7 +171,7 @@ func (h *Handler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = database.DeleteCustomer(h.db, id)
+	err = database.DeleteCustomer(h.db, auth.ActorFrom(r.Context()), id)
 	if err != nil {
 		if err.Error() == "customer not found" {
 			w.WriteHeader(http.StatusNotFound)
@@ -155,3 +191,86 @@ func (h *Handler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetReceivables handles GET /api/customers/receivables
+// Customers who owe money, with their balance split by invoice age
+func (h *Handler) GetReceivables(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	receivables, err := database.GetReceivables(h.db)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    receivables,
+	})
+}
+
+// CreateCustomerPayment handles POST /api/customers/{id}/payments
+// Applies the payment to the customer's open invoices, oldest first
+func (h *Handler) CreateCustomerPayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid customer ID"})
+		return
+	}
+
+	var req models.CustomerPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	payment, err := database.CreateCustomerPayment(h.db, auth.ActorFrom(r.Context()), id, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    payment,
+	})
+}
+
+// GetCustomerLedger handles GET /api/customers/{id}/ledger
+// Query: from, to (YYYY-MM-DD, inclusive; both optional)
+func (h *Handler) GetCustomerLedger(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid customer ID"})
+		return
+	}
+
+	from, to, err := parseDateRange(r, "", "")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	ledger, err := database.GetCustomerLedger(h.db, id, from, to)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ledger,
+	})
+}
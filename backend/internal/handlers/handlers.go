@@ -3,9 +3,13 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"pharmacy-backend/internal/auth"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/models"
 
@@ -14,16 +18,78 @@ import (
 
 // Handler holds the database connection and provides HTTP handlers
 type Handler struct {
-	db *sql.DB
+	db     *sql.DB
+	tokens *auth.Tokens
 }
 
 // New creates a new Handler instance
-func New(db *sql.DB) *Handler {
+func New(db *sql.DB, tokens *auth.Tokens) *Handler {
 	return &Handler{
-		db: db,
+		db:     db,
+		tokens: tokens,
 	}
 }
 
+// errorStatus maps an error from the database layer to an HTTP status code
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrInsufficientStock), errors.Is(err, database.ErrCreditLimit),
+		errors.Is(err, database.ErrPrescription):
+		return http.StatusConflict
+	case strings.Contains(err.Error(), "not found"):
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// hideCost strips buying price and margin from products when the
+// requesting user is not allowed to see them
+func hideCost(r *http.Request, products ...*models.ProductResponse) {
+	if auth.ActorFrom(r.Context()).CanSeeCost() {
+		return
+	}
+	for _, p := range products {
+		if p != nil {
+			p.HideCost()
+		}
+	}
+}
+
+// monthToDate returns the first of the current month and today (YYYY-MM-DD),
+// the default range of the reports
+func monthToDate() (string, string) {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	return start.Format("2006-01-02"), now.Format("2006-01-02")
+}
+
+// parseDateRange reads the from and to query dates (YYYY-MM-DD, inclusive).
+// A missing date takes its default; an empty default leaves that end open.
+func parseDateRange(r *http.Request, defaultFrom, defaultTo string) (from, to string, err error) {
+	query := r.URL.Query()
+	from, to = defaultFrom, defaultTo
+	if s := query.Get("from"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return "", "", errors.New("from must be a date (YYYY-MM-DD)")
+		}
+		from = t.Format("2006-01-02")
+	}
+	if s := query.Get("to"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return "", "", errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		to = t.Format("2006-01-02")
+	}
+	if from != "" && to != "" && from > to {
+		return "", "", errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
 // Health check handler
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+)
+
+// GetIngredients handles GET /api/ingredients
+// Query: search, page, limit
+func (h *Handler) GetIngredients(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	ingredients, pagination, err := database.GetIngredients(h.db, page, limit, query.Get("search"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       ingredients,
+		"pagination": pagination,
+	})
+}
+
+// CreateIngredient handles POST /api/ingredients
+func (h *Handler) CreateIngredient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateIngredientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	ingredient, err := database.CreateIngredient(h.db, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ingredient,
+	})
+}
+
+// GetIngredientProducts handles GET /api/ingredients/products
+// Query: names (comma separated, all required), exact, page, limit
+func (h *Handler) GetIngredientProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	exact, _ := strconv.ParseBool(query.Get("exact"))
+	names := strings.Split(query.Get("names"), ",")
+
+	products, pagination, err := database.GetProductsByIngredients(h.db, names, exact, page, limit)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       products,
+		"pagination": pagination,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"pharmacy-backend/internal/auth"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/models"
 
@@ -52,6 +53,9 @@ func (h *Handler) GetMedicines(w http.ResponseWriter, r *http.Request) {
 	if medicines == nil {
 		medicines = []models.ProductResponse{}
 	}
+	for i := range medicines {
+		hideCost(r, &medicines[i])
+	}
 
 	// Response Format
 	response := map[string]interface{}{
@@ -88,6 +92,8 @@ func (h *Handler) GetMedicineByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hideCost(r, medicine)
+
 	// Response Format - matching the data structure from GetMedicines
 	response := map[string]interface{}{
 		"data": medicine,
@@ -108,13 +114,14 @@ func (h *Handler) CreateMedicine(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Use CreateNewProduct for full response details
-	medicine, err := database.CreateNewProduct(h.db, req)
+	medicine, err := database.CreateNewProduct(h.db, auth.ActorFrom(r.Context()), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
 	}
 
+	hideCost(r, medicine)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(medicine)
 }
@@ -133,14 +140,31 @@ func (h *Handler) UpdateMedicine(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.BuyingPrice != nil && !auth.ActorFrom(r.Context()).CanSeeCost() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not allowed to change the buying price"})
+		return
+	}
+	if req.RequiresPrescription != nil && !auth.ActorFrom(r.Context()).CanClassifyDrugs() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not allowed to change whether a prescription is required"})
+		return
+	}
+	if req.Schedule != nil && !auth.ActorFrom(r.Context()).CanClassifyDrugs() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not allowed to change the schedule"})
+		return
+	}
+
 	// Use UpdateExistingProduct for full status return
-	updatedMedicine, err := database.UpdateExistingProduct(h.db, id, req)
+	updatedMedicine, err := database.UpdateExistingProduct(h.db, auth.ActorFrom(r.Context()), id, req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
 	}
 
+	hideCost(r, updatedMedicine)
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(updatedMedicine)
 }
@@ -152,8 +176,8 @@ func (h *Handler) DeleteMedicine(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	if err := database.DeleteMedicine(h.db, id); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	if err := database.DeleteMedicine(h.db, auth.ActorFrom(r.Context()), id); err != nil {
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
 	}
@@ -248,6 +272,48 @@ func (h *Handler) GetGenerics(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(genericDTOs)
 }
 
+// GetPackUnits handles GET /api/inventory/pack-units
+func (h *Handler) GetPackUnits(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	units, err := database.GetPackUnits(h.db)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    units,
+	})
+}
+
+// CreatePackUnit handles POST /api/inventory/pack-units
+func (h *Handler) CreatePackUnit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.PackUnit
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	unit, err := database.CreatePackUnit(h.db, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    unit,
+	})
+}
+
 // GetRackMedicines handles GET /api/inventory/racks/medicines
 func (h *Handler) GetRackMedicines(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -270,3 +336,156 @@ func (h *Handler) GetRackMedicines(w http.ResponseWriter, r *http.Request) {
 
 	json.NewEncoder(w).Encode(response)
 }
+
+// GetMedicineBatches handles GET /api/inventory/medicines/{id}/batches
+func (h *Handler) GetMedicineBatches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	batches, err := database.GetProductBatches(h.db, mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    batches,
+	})
+}
+
+// GetBatchSales handles GET /api/inventory/batches/{id}/sales
+// Lists the invoices and customers that received units from a batch
+func (h *Handler) GetBatchSales(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid batch ID"})
+		return
+	}
+
+	sales, err := database.GetBatchSales(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    sales,
+	})
+}
+
+// QuarantineBatches handles POST /api/inventory/batches/quarantine
+// Takes batches (or every expired batch) out of sellable stock
+func (h *Handler) QuarantineBatches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.QuarantineBatchesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	batches, err := database.QuarantineBatches(h.db, auth.ActorFrom(r.Context()), req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    batches,
+	})
+}
+
+// WriteOffBatch handles POST /api/inventory/batches/{id}/write-off
+// Disposes of stock in a quarantined batch; an empty body writes off all of it as expired
+func (h *Handler) WriteOffBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid batch ID"})
+		return
+	}
+
+	var req models.WriteOffBatchRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+			return
+		}
+	}
+
+	result, err := database.WriteOffBatch(h.db, auth.ActorFrom(r.Context()), id, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetStockHistory handles GET /api/inventory/medicines/{id}/stock-history
+func (h *Handler) GetStockHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	history, pagination, err := database.GetStockHistory(h.db, mux.Vars(r)["id"], page, limit)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       history,
+		"pagination": pagination,
+	})
+}
+
+// AdjustStock handles POST /api/inventory/medicines/{id}/stock-adjustments
+func (h *Handler) AdjustStock(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.StockAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	medicine, err := database.AdjustProductStock(h.db, auth.ActorFrom(r.Context()), mux.Vars(r)["id"], req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	hideCost(r, medicine)
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    medicine,
+	})
+}
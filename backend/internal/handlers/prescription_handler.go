@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CreatePrescription handles POST /api/prescriptions
+func (h *Handler) CreatePrescription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreatePrescriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	prescription, err := database.CreatePrescription(h.db, auth.ActorFrom(r.Context()), req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    prescription,
+	})
+}
+
+// GetPrescriptions handles GET /api/prescriptions
+// Query: customer, search (patient, prescriber or registration number), page, limit
+func (h *Handler) GetPrescriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	customerID := 0
+	if s := query.Get("customer"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid customer ID"})
+			return
+		}
+		customerID = id
+	}
+
+	prescriptions, pagination, err := database.GetPrescriptions(h.db, page, limit, customerID, query.Get("search"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       prescriptions,
+		"pagination": pagination,
+	})
+}
+
+// GetPrescription handles GET /api/prescriptions/{id}
+// Includes the scanned image and the invoice lines dispensed against it
+func (h *Handler) GetPrescription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid prescription ID"})
+		return
+	}
+
+	prescription, err := database.GetPrescriptionByID(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    prescription,
+	})
+}
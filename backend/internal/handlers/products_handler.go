@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 
+	"pharmacy-backend/internal/auth"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/models"
 
@@ -37,6 +38,9 @@ func (h *Handler) GetAllProducts(w http.ResponseWriter, r *http.Request) {
 	if products == nil {
 		products = []models.ProductResponse{}
 	}
+	for i := range products {
+		hideCost(r, &products[i])
+	}
 
 	json.NewEncoder(w).Encode(models.ProductAPIResponse{
 		Success: true,
@@ -67,6 +71,7 @@ func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	hideCost(r, product)
 
 	json.NewEncoder(w).Encode(models.ProductAPIResponse{
 		Success: true,
@@ -75,6 +80,87 @@ func (h *Handler) GetProductByID(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetProductByBarcode handles GET /api/products/barcode/:code
+// Resolves a scanned barcode to its product and pack type
+func (h *Handler) GetProductByBarcode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	result, err := database.LookupBarcode(h.db, mux.Vars(r)["code"])
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ProductAPIResponse{
+			Success: false,
+			Data:    nil,
+			Error:   err.Error(),
+		})
+		return
+	}
+	hideCost(r, &result.Product)
+
+	json.NewEncoder(w).Encode(models.ProductAPIResponse{
+		Success: true,
+		Data:    result,
+		Error:   nil,
+	})
+}
+
+// GetSubstitutes handles GET /api/products/{id}/substitutes
+// Lists in-stock products with the same generic ingredients and strength
+func (h *Handler) GetSubstitutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	substitutes, err := database.GetSubstitutes(h.db, mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if !auth.ActorFrom(r.Context()).CanSeeCost() {
+		substitutes.HideCost()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    substitutes,
+	})
+}
+
+// RegenerateProductCodes handles POST /api/products/codes/regenerate
+// Reassigns product codes from the configured pattern
+func (h *Handler) RegenerateProductCodes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.RegenerateProductCodesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ProductAPIResponse{
+				Success: false,
+				Data:    nil,
+				Error:   "Invalid request payload",
+			})
+			return
+		}
+	}
+
+	changes, err := database.RegenerateProductCodes(h.db, auth.ActorFrom(r.Context()), req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ProductAPIResponse{
+			Success: false,
+			Data:    nil,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(models.ProductAPIResponse{
+		Success: true,
+		Data:    changes,
+		Error:   nil,
+	})
+}
+
 // AddProduct handles POST /api/products
 // Creates a new product
 func (h *Handler) AddProduct(w http.ResponseWriter, r *http.Request) {
@@ -102,9 +188,9 @@ func (h *Handler) AddProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err := database.CreateNewProduct(h.db, req)
+	product, err := database.CreateNewProduct(h.db, auth.ActorFrom(r.Context()), req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.ProductAPIResponse{
 			Success: false,
 			Data:    nil,
@@ -113,6 +199,7 @@ func (h *Handler) AddProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hideCost(r, product)
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(models.ProductAPIResponse{
 		Success: true,
@@ -140,13 +227,37 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product, err := database.UpdateExistingProduct(h.db, id, req)
+	if req.BuyingPrice != nil && !auth.ActorFrom(r.Context()).CanSeeCost() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.ProductAPIResponse{
+			Success: false,
+			Data:    nil,
+			Error:   "Not allowed to change the buying price",
+		})
+		return
+	}
+	if req.RequiresPrescription != nil && !auth.ActorFrom(r.Context()).CanClassifyDrugs() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.ProductAPIResponse{
+			Success: false,
+			Data:    nil,
+			Error:   "Not allowed to change whether a prescription is required",
+		})
+		return
+	}
+	if req.Schedule != nil && !auth.ActorFrom(r.Context()).CanClassifyDrugs() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.ProductAPIResponse{
+			Success: false,
+			Data:    nil,
+			Error:   "Not allowed to change the schedule",
+		})
+		return
+	}
+
+	product, err := database.UpdateExistingProduct(h.db, auth.ActorFrom(r.Context()), id, req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "not found") {
-			status = http.StatusNotFound
-		}
-		w.WriteHeader(status)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.ProductAPIResponse{
 			Success: false,
 			Data:    nil,
@@ -154,6 +265,7 @@ func (h *Handler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	hideCost(r, product)
 
 	json.NewEncoder(w).Encode(models.ProductAPIResponse{
 		Success: true,
@@ -170,7 +282,7 @@ func (h *Handler) RemoveProduct(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	err := database.DeleteProduct(h.db, id)
+	err := database.DeleteProduct(h.db, auth.ActorFrom(r.Context()), id)
 	if err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "not found") {
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CreatePurchase handles POST /api/purchases
+// Records a goods receipt (GRN) from a supplier
+func (h *Handler) CreatePurchase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreatePurchaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	supplierID, err := parseSupplierID(req.SupplierID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	purchase, err := database.CreatePurchase(h.db, auth.ActorFrom(r.Context()), supplierID, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    purchase,
+	})
+}
+
+// GetPurchases handles GET /api/purchases
+func (h *Handler) GetPurchases(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	supplierID := 0
+	if s := query.Get("supplier"); s != "" {
+		id, err := parseSupplierID(s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+			return
+		}
+		supplierID = id
+	}
+
+	purchases, pagination, err := database.GetPurchases(h.db, page, limit, supplierID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       purchases,
+		"pagination": pagination,
+	})
+}
+
+// GetPurchase handles GET /api/purchases/{id}
+func (h *Handler) GetPurchase(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid purchase ID"})
+		return
+	}
+
+	purchase, err := database.GetPurchaseByID(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    purchase,
+	})
+}
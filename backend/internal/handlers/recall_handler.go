@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// CreateRecall handles POST /api/recalls
+// Blocks the batches from sale, traces their sales and returns the remaining
+// stock to the supplier
+func (h *Handler) CreateRecall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.CreateRecallRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	actor := auth.ActorFrom(r.Context())
+	recall, err := database.CreateRecall(h.db, actor, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if !actor.CanSeeCustomers() {
+		recall.HideCustomers()
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recall,
+	})
+}
+
+// GetRecalls handles GET /api/recalls
+// Query: product, page, limit
+func (h *Handler) GetRecalls(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 {
+		limit = 20
+	}
+
+	productID := 0
+	if s := query.Get("product"); s != "" {
+		id, err := strconv.Atoi(strings.TrimPrefix(s, "prod_"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid product ID"})
+			return
+		}
+		productID = id
+	}
+
+	recalls, pagination, err := database.GetRecalls(h.db, page, limit, productID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"data":       recalls,
+		"pagination": pagination,
+	})
+}
+
+// GetRecall handles GET /api/recalls/{id}
+func (h *Handler) GetRecall(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid recall ID"})
+		return
+	}
+
+	recall, err := database.GetRecallByID(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if !auth.ActorFrom(r.Context()).CanSeeCustomers() {
+		recall.HideCustomers()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    recall,
+	})
+}
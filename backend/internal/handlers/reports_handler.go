@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/database"
+	"pharmacy-backend/internal/models"
+)
+
+// GetExpiryReport handles GET /api/reports/expiry
+// Query: rack, supplier, category, status (active, quarantined), bucket
+func (h *Handler) GetExpiryReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+
+	supplierID := 0
+	if s := query.Get("supplier"); s != "" {
+		id, err := parseSupplierID(s)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+			return
+		}
+		supplierID = id
+	}
+
+	status := models.BatchStatus(query.Get("status"))
+	if status != "" && status != models.BatchStatusActive && status != models.BatchStatusQuarantined && status != models.BatchStatusRecalled {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "status must be active, quarantined or recalled"})
+		return
+	}
+
+	bucket := models.ExpiryBucket(query.Get("bucket"))
+	if bucket != "" && !bucket.Valid() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Unknown expiry bucket"})
+		return
+	}
+
+	report, err := database.GetExpiryReport(h.db, query.Get("rack"), supplierID, query.Get("category"), status, bucket)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// GetProfitLossReport handles GET /api/reports/profit-loss
+// Query: from, to (YYYY-MM-DD, inclusive); defaults to the current month so far
+func (h *Handler) GetProfitLossReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	start, today := monthToDate()
+	from, to, err := parseDateRange(r, start, today)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	report, err := database.GetProfitLossReport(h.db, from, to)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    report,
+	})
+}
+
+// GetControlledRegister handles GET /api/reports/controlled-register
+// Query: product (required), from, to (YYYY-MM-DD, inclusive); defaults to the current month so far
+func (h *Handler) GetControlledRegister(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	query := r.URL.Query()
+	productID := query.Get("product")
+	if productID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "product is required"})
+		return
+	}
+
+	start, today := monthToDate()
+	from, to, err := parseDateRange(r, start, today)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	register, err := database.GetControlledRegister(h.db, productID, from, to)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    register,
+	})
+}
+
+// GetDashboard handles GET /api/dashboard
+// Query: period (today, week, month); defaults to today
+func (h *Handler) GetDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	period := models.DashboardPeriod(r.URL.Query().Get("period"))
+	if period == "" {
+		period = models.DashboardToday
+	}
+	if period.Days() == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "period must be today, week or month"})
+		return
+	}
+
+	dashboard, err := database.GetDashboard(h.db, period)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if !auth.ActorFrom(r.Context()).CanSeeCost() {
+		dashboard.PurchaseSpend = nil
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    dashboard,
+	})
+}
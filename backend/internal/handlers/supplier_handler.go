@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"pharmacy-backend/internal/auth"
 	"pharmacy-backend/internal/database"
 	"pharmacy-backend/internal/models"
 	"strconv"
@@ -79,6 +80,32 @@ func (h *Handler) GetSupplierCompanies(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetSupplier handles GET /api/suppliers/{id}
+// Returns the supplier with its products, recent purchases, purchase stats
+// and open return credits
+func (h *Handler) GetSupplier(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := parseSupplierID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	supplier, err := database.GetSupplierByID(h.db, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    supplier,
+	})
+}
+
 // AddSupplier handles POST /api/suppliers
 func (h *Handler) AddSupplier(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -94,7 +121,7 @@ func (h *Handler) AddSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	supplier, err := database.AddSupplier(h.db, req)
+	supplier, err := database.AddSupplier(h.db, auth.ActorFrom(r.Context()), req)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.APIResponse{
@@ -139,7 +166,7 @@ func (h *Handler) UpdateSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	supplier, err := database.UpdateSupplier(h.db, id, req)
+	supplier, err := database.UpdateSupplier(h.db, auth.ActorFrom(r.Context()), id, req)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(models.APIResponse{
@@ -173,9 +200,9 @@ func (h *Handler) DeleteSupplier(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = database.DeleteSupplier(h.db, id)
+	err = database.DeleteSupplier(h.db, auth.ActorFrom(r.Context()), id)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.APIResponse{
 			Status:  "error",
 			Message: "Failed to delete supplier",
@@ -189,3 +216,68 @@ func (h *Handler) DeleteSupplier(w http.ResponseWriter, r *http.Request) {
 		Message: "Supplier deleted successfully",
 	})
 }
+
+// CreateSupplierPayment handles POST /api/suppliers/{id}/payments
+// Applies the payment to the supplier's open purchases, oldest first
+func (h *Handler) CreateSupplierPayment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := parseSupplierID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	var req models.SupplierPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid request payload"})
+		return
+	}
+
+	payment, err := database.CreateSupplierPayment(h.db, auth.ActorFrom(r.Context()), id, req)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    payment,
+	})
+}
+
+// GetSupplierLedger handles GET /api/suppliers/{id}/ledger
+// Query: from, to (YYYY-MM-DD, inclusive; both optional)
+func (h *Handler) GetSupplierLedger(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := parseSupplierID(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid supplier ID"})
+		return
+	}
+
+	from, to, err := parseDateRange(r, "", "")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	ledger, err := database.GetSupplierLedger(h.db, id, from, to)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"data":    ledger,
+	})
+}
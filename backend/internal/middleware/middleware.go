@@ -1,8 +1,15 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"log"
 	"net/http"
+	"strings"
+
+	"pharmacy-backend/internal/auth"
+	"pharmacy-backend/internal/models"
 )
 
 // CORS middleware for handling Cross-Origin Resource Sharing
@@ -10,7 +17,8 @@ func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -24,7 +32,80 @@ func CORS(next http.Handler) http.Handler {
 // Logging middleware for request logging
 func Logging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
+		log.Printf("%s %s %s %s", r.Method, r.RequestURI, r.RemoteAddr, auth.RequestIDFrom(r.Context()))
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequestID tags every request with an ID, taken from a sane X-Request-ID
+// header or generated, and echoes it in the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(auth.WithRequestID(r.Context(), id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c == '-' || c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+// Auth requires a valid "Authorization: Bearer <access token>" header and
+// stores the authenticated user on the request context
+func Auth(tokens *auth.Tokens) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(header, "Bearer ")
+			if header == "" || token == header {
+				unauthorized(w, "Missing bearer token")
+				return
+			}
+
+			claims, err := tokens.VerifyAccessToken(token)
+			if err != nil {
+				unauthorized(w, "Invalid or expired token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithActor(r.Context(), claims.Actor())))
+		})
+	}
+}
+
+// RequireRole only lets users with one of the given roles through to next
+func RequireRole(next http.HandlerFunc, roles ...models.Role) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor := auth.ActorFrom(r.Context())
+		for _, role := range roles {
+			if actor.Role == role {
+				next(w, r)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Insufficient permissions"})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+}
@@ -0,0 +1,21 @@
+package models
+
+// =====================================================
+// Audit Log API DTOs
+// =====================================================
+
+// AuditEntryDTO is one field-level change. Field is empty for whole-record
+// create/delete entries.
+type AuditEntryDTO struct {
+	ID        int64   `json:"id"`
+	Entity    string  `json:"entity"`
+	EntityID  int     `json:"entityId"`
+	Action    string  `json:"action"`
+	Field     string  `json:"field,omitempty"`
+	OldValue  *string `json:"oldValue"`
+	NewValue  *string `json:"newValue"`
+	UserID    *int    `json:"userId,omitempty"`
+	Username  string  `json:"username,omitempty"`
+	RequestID string  `json:"requestId,omitempty"`
+	CreatedAt string  `json:"createdAt"`
+}
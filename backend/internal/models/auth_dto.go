@@ -0,0 +1,76 @@
+package models
+
+// =====================================================
+// Auth / User API DTOs
+// =====================================================
+
+// Actor is the authenticated user performing a request
+type Actor struct {
+	UserID    int
+	Username  string
+	Role      Role
+	RequestID string
+}
+
+// CanSeeCost reports whether the actor may see buying prices and margins
+func (a Actor) CanSeeCost() bool {
+	return a.Role != RoleCashier
+}
+
+// CanSeeCustomers reports whether the actor may see customer names and
+// contact details
+func (a Actor) CanSeeCustomers() bool {
+	return a.Role != RoleStockClerk
+}
+
+// CanClassifyDrugs reports whether the actor may change whether a product
+// needs a prescription or its controlled substance schedule
+func (a Actor) CanClassifyDrugs() bool {
+	return a.Role == RoleOwner || a.Role == RolePharmacist
+}
+
+// LoginRequest - Request DTO for POST /api/auth/login
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest - Request DTO for POST /api/auth/refresh and /api/auth/logout
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse is returned by login and refresh
+type TokenResponse struct {
+	AccessToken  string  `json:"accessToken"`
+	TokenType    string  `json:"tokenType"`
+	ExpiresAt    string  `json:"expiresAt"`
+	RefreshToken string  `json:"refreshToken"`
+	User         UserDTO `json:"user"`
+}
+
+type UserDTO struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	FullName    string `json:"fullName,omitempty"`
+	Role        Role   `json:"role"`
+	Active      bool   `json:"active"`
+	LastLoginAt string `json:"lastLoginAt,omitempty"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// CreateUserRequest - Request DTO for POST /api/users
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	FullName string `json:"fullName,omitempty"`
+	Role     Role   `json:"role"`
+}
+
+// UpdateUserRequest - Request DTO for PUT /api/users/{id}
+type UpdateUserRequest struct {
+	FullName *string `json:"fullName,omitempty"`
+	Role     *Role   `json:"role,omitempty"`
+	Active   *bool   `json:"active,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
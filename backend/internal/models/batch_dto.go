@@ -0,0 +1,68 @@
+package models
+
+// =====================================================
+// Batch API DTOs
+// =====================================================
+
+// BatchDTO - a product batch with its remaining quantity
+type BatchDTO struct {
+	ID           int         `json:"id"`
+	ProductID    string      `json:"productId"`
+	BatchNo      string      `json:"batchNo"`
+	Quantity     int         `json:"quantity"`
+	ExpiryDate   string      `json:"expiryDate,omitempty"`
+	PurchaseDate string      `json:"purchaseDate,omitempty"`
+	SupplierID   *int        `json:"supplierId,omitempty"`
+	Supplier     string      `json:"supplier,omitempty"`
+	CostPrice    float64     `json:"costPrice"`
+	Expired      bool        `json:"expired"`
+	Status       BatchStatus `json:"status"`
+}
+
+// BatchSaleDTO - one invoice line that drew units from a batch
+type BatchSaleDTO struct {
+	InvoiceID     int    `json:"invoiceId"`
+	InvoiceNo     string `json:"invoiceNo"`
+	InvoiceItemID int    `json:"invoiceItemId"`
+	SoldAt        string `json:"soldAt"`
+	CustomerID    *int   `json:"customerId,omitempty"`
+	CustomerName  string `json:"customerName,omitempty"`
+	CustomerPhone string `json:"customerPhone,omitempty"`
+	Quantity      int    `json:"quantity"`
+}
+
+// QuarantineBatchesRequest - Request DTO for POST /api/inventory/batches/quarantine
+// Expired sweeps in every active expired batch that still has stock
+type QuarantineBatchesRequest struct {
+	BatchIDs []int  `json:"batchIds,omitempty"`
+	Expired  bool   `json:"expired,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// QuarantinedBatchDTO - a batch taken out of sellable stock
+type QuarantinedBatchDTO struct {
+	BatchID    int    `json:"batchId"`
+	BatchNo    string `json:"batchNo"`
+	ProductID  string `json:"productId"`
+	Quantity   int    `json:"quantity"`
+	ExpiryDate string `json:"expiryDate,omitempty"`
+}
+
+// WriteOffBatchRequest - Request DTO for POST /api/inventory/batches/{id}/write-off
+// Quantity 0 writes off everything left in the batch; Reason defaults to expired
+type WriteOffBatchRequest struct {
+	Quantity int                   `json:"quantity,omitempty"`
+	Reason   StockAdjustmentReason `json:"reason,omitempty"`
+	Note     string                `json:"note,omitempty"`
+}
+
+// WrittenOffBatchDTO - stock removed from a quarantined batch
+type WrittenOffBatchDTO struct {
+	BatchID   int                   `json:"batchId"`
+	BatchNo   string                `json:"batchNo"`
+	ProductID string                `json:"productId"`
+	Quantity  int                   `json:"quantity"`
+	Remaining int                   `json:"remaining"`
+	Reason    StockAdjustmentReason `json:"reason"`
+	Amount    float64               `json:"amount"` // quantity x cost price
+}
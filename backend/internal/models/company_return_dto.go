@@ -0,0 +1,63 @@
+package models
+
+// =====================================================
+// Company Return (Return to Supplier) API DTOs
+// =====================================================
+
+// CompanyReturnItemRequest returns Quantity base units of a batch.
+// A zero Quantity returns everything left in the batch.
+type CompanyReturnItemRequest struct {
+	BatchID  int `json:"batchId"`
+	Quantity int `json:"quantity"`
+}
+
+// CreateCompanyReturnRequest - Request DTO for POST /api/company-returns
+type CreateCompanyReturnRequest struct {
+	SupplierID string                     `json:"supplierId"`
+	Reason     CompanyReturnReason        `json:"reason"`
+	Notes      string                     `json:"notes,omitempty"`
+	Items      []CompanyReturnItemRequest `json:"items"`
+}
+
+// SettleCompanyReturnRequest - Request DTO for POST /api/company-returns/{id}/settle.
+// Close marks the return settled even when Amount falls short of the expected credit.
+type SettleCompanyReturnRequest struct {
+	Amount float64 `json:"amount"`
+	Close  bool    `json:"close"`
+}
+
+type CompanyReturnItemDTO struct {
+	ID          int     `json:"id"`
+	BatchID     int     `json:"batchId"`
+	BatchNo     string  `json:"batchNo"`
+	ExpiryDate  string  `json:"expiryDate,omitempty"`
+	ProductID   string  `json:"productId"`
+	ProductName string  `json:"productName"`
+	Quantity    int     `json:"quantity"`
+	CostPrice   float64 `json:"costPrice"`
+	Amount      float64 `json:"amount"`
+}
+
+// CompanyReturnDTO - a return to supplier and the state of its credit
+type CompanyReturnDTO struct {
+	ID             int                    `json:"id"`
+	ReturnNo       string                 `json:"returnNo"`
+	SupplierID     string                 `json:"supplierId"`
+	SupplierName   string                 `json:"supplierName"`
+	Reason         CompanyReturnReason    `json:"reason"`
+	Status         CompanyReturnStatus    `json:"status"`
+	ExpectedCredit float64                `json:"expectedCredit"`
+	SettledAmount  float64                `json:"settledAmount"`
+	OpenCredit     float64                `json:"openCredit"`
+	SettledAt      string                 `json:"settledAt,omitempty"`
+	Notes          string                 `json:"notes,omitempty"`
+	CreatedAt      string                 `json:"createdAt"`
+	Items          []CompanyReturnItemDTO `json:"items,omitempty"`
+}
+
+// SupplierReturnCreditsDTO - open return credits owed by a supplier
+type SupplierReturnCreditsDTO struct {
+	SupplierID string             `json:"supplierId"`
+	OpenCredit float64            `json:"openCredit"`
+	Returns    []CompanyReturnDTO `json:"returns"`
+}
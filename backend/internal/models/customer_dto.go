@@ -0,0 +1,110 @@
+package models
+
+// =====================================================
+// Customer Credit API DTOs
+// =====================================================
+
+// CustomerPaymentRequest - Request DTO for POST /api/customers/{id}/payments
+type CustomerPaymentRequest struct {
+	Amount    float64       `json:"amount"`
+	Method    PaymentMethod `json:"method,omitempty"` // defaults to cash
+	Reference string        `json:"reference,omitempty"`
+	Notes     string        `json:"notes,omitempty"`
+}
+
+// PaymentAllocationDTO - the part of a payment applied to one invoice
+type PaymentAllocationDTO struct {
+	InvoiceID int     `json:"invoiceId"`
+	InvoiceNo string  `json:"invoiceNo"`
+	Amount    float64 `json:"amount"`
+	Balance   float64 `json:"balance"` // left on the invoice afterwards
+}
+
+// CustomerPaymentDTO - a payment received from a customer
+type CustomerPaymentDTO struct {
+	ID          int                    `json:"id"`
+	ReceiptNo   string                 `json:"receiptNo"`
+	CustomerID  int                    `json:"customerId"`
+	Amount      float64                `json:"amount"`
+	Method      PaymentMethod          `json:"method"`
+	Reference   string                 `json:"reference,omitempty"`
+	Notes       string                 `json:"notes,omitempty"`
+	CreatedAt   string                 `json:"createdAt"`
+	Allocations []PaymentAllocationDTO `json:"allocations"`
+	Balance     float64                `json:"balance"` // customer's outstanding balance afterwards
+}
+
+// AgingDTO - outstanding balance by invoice age in days
+type AgingDTO struct {
+	Current float64 `json:"current"` // 0-30 days
+	Days60  float64 `json:"days31to60"`
+	Days90  float64 `json:"days61to90"`
+	Over90  float64 `json:"over90"`
+	Total   float64 `json:"total"`
+}
+
+// CustomerLedgerEntryDTO - one line of a customer statement
+type CustomerLedgerEntryDTO struct {
+	Date      string          `json:"date"`
+	Type      LedgerEntryType `json:"type"`
+	Reference string          `json:"reference"` // invoice, receipt or credit note number
+	Debit     float64         `json:"debit"`
+	Credit    float64         `json:"credit"`
+	Balance   float64         `json:"balance"` // running balance after this line
+}
+
+// CustomerLedgerDTO - Response data for GET /api/customers/{id}/ledger
+type CustomerLedgerDTO struct {
+	CustomerID     int                      `json:"customerId"`
+	Name           string                   `json:"name"`
+	CreditLimit    float64                  `json:"creditLimit"`
+	From           string                   `json:"from,omitempty"`
+	To             string                   `json:"to,omitempty"`
+	OpeningBalance float64                  `json:"openingBalance"`
+	ClosingBalance float64                  `json:"closingBalance"`
+	Entries        []CustomerLedgerEntryDTO `json:"entries"`
+	Aging          AgingDTO                 `json:"aging"` // as of today
+}
+
+// CustomerReceivableDTO - a customer who owes money, for GET /api/customers/receivables
+type CustomerReceivableDTO struct {
+	CustomerID      int      `json:"customerId"`
+	Name            string   `json:"name"`
+	Phone           string   `json:"phone,omitempty"`
+	CreditLimit     float64  `json:"creditLimit"`
+	OpenInvoices    int      `json:"openInvoices"`
+	OldestInvoiceAt string   `json:"oldestInvoiceAt"`
+	Aging           AgingDTO `json:"aging"`
+}
+
+// =====================================================
+// Customer Profile API DTOs
+// =====================================================
+
+// CustomerStatsDTO - a customer's purchase totals
+type CustomerStatsDTO struct {
+	LifetimeSpend float64 `json:"lifetimeSpend"` // invoice totals less returns
+	Returned      float64 `json:"returned"`
+	VisitCount    int     `json:"visitCount"` // invoices
+	LastVisit     string  `json:"lastVisit,omitempty"`
+}
+
+// CustomerProductDTO - a medicine a customer buys, with what they took last time
+type CustomerProductDTO struct {
+	ProductID       string   `json:"productId"`
+	Name            string   `json:"name"`
+	Strength        string   `json:"strength,omitempty"`
+	Units           int      `json:"units"` // kept, after returns
+	Invoices        int      `json:"invoices"`
+	LastPurchasedAt string   `json:"lastPurchasedAt"`
+	LastPackType    PackType `json:"lastPackType"`
+	LastQuantity    float64  `json:"lastQuantity"`
+}
+
+// CustomerDetailDTO - Response data for GET /api/customers/{id}
+type CustomerDetailDTO struct {
+	CustomerDTO
+	Stats       CustomerStatsDTO     `json:"stats"`
+	TopProducts []CustomerProductDTO `json:"topProducts"`
+	Invoices    []SaleDTO            `json:"invoices"` // newest first, one page
+}
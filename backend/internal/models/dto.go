@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // =====================================================
 // API DTOs - Product API (Matches frontend expectations)
 // =====================================================
@@ -16,88 +18,222 @@ type PackPrice struct {
 	Box   float64 `json:"box,omitempty"`
 }
 
+// PackagingDTO is one packaging level of a product: how many base units a
+// pack holds and what it sells for
+type PackagingDTO struct {
+	PackType     PackType `json:"packType"`
+	Name         string   `json:"name"`
+	UnitsPerPack int      `json:"unitsPerPack"`
+	Price        float64  `json:"price"`
+	MRP          float64  `json:"mrp"`
+	Barcode      string   `json:"barcode,omitempty"`
+}
+
+// PackagingRequest sets one packaging level. MRP defaults to Price; the unit
+// level's barcode is the product barcode.
+type PackagingRequest struct {
+	PackType     PackType `json:"packType"`
+	UnitsPerPack int      `json:"unitsPerPack"`
+	Price        float64  `json:"price"`
+	MRP          float64  `json:"mrp,omitempty"`
+	Barcode      string   `json:"barcode,omitempty"`
+}
+
+// PackCount - whole packs of one type
+type PackCount struct {
+	PackType PackType `json:"packType"`
+	Count    int      `json:"count"`
+}
+
+// PackAvailability breaks stock down into whole packs, largest first, then
+// loose units. Boxes, Strips and Units are kept for older clients.
+type PackAvailability struct {
+	Packs  []PackCount `json:"packs"`
+	Boxes  int         `json:"boxes"`
+	Strips int         `json:"strips"`
+	Units  int         `json:"units"`
+	Label  string      `json:"label"` // e.g. "3 boxes, 2 strips, 4 units"
+}
+
+// PackUnit is a kind of pack products can be sold in
+type PackUnit struct {
+	Code       PackType `json:"code"`
+	Name       string   `json:"name"`
+	PluralName string   `json:"pluralName"`
+}
+
+// PackBarcode represents the barcodes printed on each pack type
+type PackBarcode struct {
+	Strip string `json:"strip,omitempty"`
+	Box   string `json:"box,omitempty"`
+}
+
 // ProductResponse - Response DTO for GET /api/products
 // Matches the exact JSON structure expected by frontend
 type ProductResponse struct {
-	ID              string    `json:"id"`
-	SrlNo           int       `json:"srlNo"`
-	Name            string    `json:"name"`
-	Image           string    `json:"image,omitempty"`
-	Description     string    `json:"description,omitempty"`
-	Barcode         string    `json:"barcode,omitempty"`
-	ProductCode     string    `json:"productCode,omitempty"`
-	Strength        string    `json:"strength,omitempty"`
-	Manufacture     string    `json:"manufacture,omitempty"`
-	GenericName     string    `json:"genericName,omitempty"`
-	Price           float64   `json:"price"`
-	MRP             float64   `json:"mrp"`
-	Discount        float64   `json:"discount"`
-	VAT             float64   `json:"vat"`
-	RackNo          string    `json:"rackNo,omitempty"`
-	RackLocation    string    `json:"rackLocation,omitempty"`
-	RackFkID        int       `json:"rackFkId,omitempty"`
-	TotalPurchase   int       `json:"totalPurchase"`
-	TotalSold       int       `json:"totalSold"`
-	InStock         int       `json:"inStock"`
-	StockStatus     string    `json:"stockStatus"`
-	Category        string    `json:"category,omitempty"`
-	ExpiryDate      string    `json:"expiryDate,omitempty"`
-	Type            string    `json:"type,omitempty"`
-	BatchID         string    `json:"batchId,omitempty"`
-	Supplier        string    `json:"supplier,omitempty"`
-	SupplierContact string    `json:"supplierContact,omitempty"`
-	PurchaseDate    string    `json:"purchaseDate,omitempty"`
-	BuyingPrice     float64   `json:"buyingPrice"`
-	ProfitMargin    float64   `json:"profitMargin"`
-	StockAlert      int       `json:"stockAlert"`
-	PackSize        PackSize  `json:"packSize"`
-	PackPrice       PackPrice `json:"packPrice"`
+	ID              string      `json:"id"`
+	SrlNo           int         `json:"srlNo"`
+	Name            string      `json:"name"`
+	Image           string      `json:"image,omitempty"`
+	Description     string      `json:"description,omitempty"`
+	Barcode         string      `json:"barcode,omitempty"`
+	ProductCode     string      `json:"productCode,omitempty"`
+	Strength        string      `json:"strength,omitempty"`
+	Manufacture     string      `json:"manufacture,omitempty"`
+	GenericName     string      `json:"genericName,omitempty"`
+	Price           float64     `json:"price"`
+	MRP             float64     `json:"mrp"`
+	Discount        float64     `json:"discount"`
+	VAT             float64     `json:"vat"`
+	RackNo          string      `json:"rackNo,omitempty"`
+	RackLocation    string      `json:"rackLocation,omitempty"`
+	RackFkID        int         `json:"rackFkId,omitempty"`
+	TotalPurchase   int         `json:"totalPurchase"`
+	TotalSold       int         `json:"totalSold"`
+	InStock         int         `json:"inStock"`
+	StockStatus     string      `json:"stockStatus"`
+	Category        string      `json:"category,omitempty"`
+	ExpiryDate      string      `json:"expiryDate,omitempty"`
+	Type            string      `json:"type,omitempty"`
+	BatchID         string      `json:"batchId,omitempty"`
+	Supplier        string      `json:"supplier,omitempty"`
+	SupplierContact string      `json:"supplierContact,omitempty"`
+	PurchaseDate    string      `json:"purchaseDate,omitempty"`
+	BuyingPrice     float64     `json:"buyingPrice"`
+	ProfitMargin    float64     `json:"profitMargin"`
+	StockAlert      int         `json:"stockAlert"`
+	PackSize        PackSize    `json:"packSize"`
+	PackPrice       PackPrice   `json:"packPrice"`
+	PackBarcode     PackBarcode `json:"packBarcode"`
+
+	Packaging    []PackagingDTO   `json:"packaging"`
+	Availability PackAvailability `json:"availability"`
+
+	Ingredients []ProductIngredientDTO `json:"ingredients"`
+
+	RequiresPrescription bool         `json:"requiresPrescription"`
+	Schedule             DrugSchedule `json:"schedule,omitempty"`
+
+	costHidden bool
+}
+
+// HideCost drops buyingPrice and profitMargin from the JSON output
+func (p *ProductResponse) HideCost() {
+	p.BuyingPrice = 0
+	p.ProfitMargin = 0
+	p.costHidden = true
+}
+
+// MarshalJSON omits the cost fields when HideCost was called
+func (p ProductResponse) MarshalJSON() ([]byte, error) {
+	type product ProductResponse
+	if !p.costHidden {
+		return json.Marshal(product(p))
+	}
+	// The outer fields shadow the embedded ones and are left nil
+	return json.Marshal(struct {
+		product
+		BuyingPrice  *float64 `json:"buyingPrice,omitempty"`
+		ProfitMargin *float64 `json:"profitMargin,omitempty"`
+	}{product: product(p)})
 }
 
 // CreateProductRequest - Request DTO for POST /api/products
 type CreateProductRequest struct {
-	Name            string    `json:"name"`
-	Description     string    `json:"description,omitempty"`
-	Strength        string    `json:"strength,omitempty"`
-	GenericName     string    `json:"genericName,omitempty"`
-	Manufacture     string    `json:"manufacture,omitempty"`
-	Supplier        string    `json:"supplier,omitempty"`
-	SupplierContact string    `json:"supplierContact,omitempty"`
-	RackNo          string    `json:"rackNo,omitempty"`
-	RackLocation    string    `json:"rackLocation,omitempty"`
-	InStock         int       `json:"inStock"`
-	Price           float64   `json:"price"`
-	MRP             float64   `json:"mrp"`
-	Discount        float64   `json:"discount"`
-	BuyingPrice     float64   `json:"buyingPrice"`
-	Type            string    `json:"type,omitempty"`
-	StockStatus     string    `json:"stockStatus,omitempty"`
-	Category        string    `json:"category,omitempty"`
-	PackSize        PackSize  `json:"packSize"`
-	PackPrice       PackPrice `json:"packPrice"`
+	Name            string      `json:"name"`
+	Description     string      `json:"description,omitempty"`
+	Barcode         string      `json:"barcode,omitempty"` // manufacturer code; generated when empty
+	Strength        string      `json:"strength,omitempty"`
+	GenericName     string      `json:"genericName,omitempty"`
+	Manufacture     string      `json:"manufacture,omitempty"`
+	Supplier        string      `json:"supplier,omitempty"`
+	SupplierContact string      `json:"supplierContact,omitempty"`
+	RackNo          string      `json:"rackNo,omitempty"`
+	RackLocation    string      `json:"rackLocation,omitempty"`
+	InStock         int         `json:"inStock"`
+	Price           float64     `json:"price"`
+	MRP             float64     `json:"mrp"`
+	Discount        float64     `json:"discount"`
+	BuyingPrice     float64     `json:"buyingPrice"`
+	Type            string      `json:"type,omitempty"`
+	StockStatus     string      `json:"stockStatus,omitempty"`
+	Category        string      `json:"category,omitempty"`
+	PackSize        PackSize    `json:"packSize"`
+	PackPrice       PackPrice   `json:"packPrice"`
+	PackBarcode     PackBarcode `json:"packBarcode"`
+
+	// Packaging, when sent, replaces packSize, packPrice and packBarcode
+	Packaging []PackagingRequest `json:"packaging,omitempty"`
+
+	// Ingredients, when sent, set the active ingredients; otherwise they are
+	// read from genericName and strength
+	Ingredients []IngredientRequest `json:"ingredients,omitempty"`
+
+	RequiresPrescription bool         `json:"requiresPrescription"`
+	Schedule             DrugSchedule `json:"schedule,omitempty"` // narcotic or psychotropic
 }
 
 // UpdateProductRequest - Request DTO for PUT/PATCH /api/products/:id
 type UpdateProductRequest struct {
-	Name            *string    `json:"name,omitempty"`
-	Description     *string    `json:"description,omitempty"`
-	Strength        *string    `json:"strength,omitempty"`
-	GenericName     *string    `json:"genericName,omitempty"`
-	Manufacture     *string    `json:"manufacture,omitempty"`
-	Supplier        *string    `json:"supplier,omitempty"`
-	SupplierContact *string    `json:"supplierContact,omitempty"`
-	RackNo          *string    `json:"rackNo,omitempty"`
-	RackLocation    *string    `json:"rackLocation,omitempty"`
-	InStock         *int       `json:"inStock,omitempty"`
-	Price           *float64   `json:"price,omitempty"`
-	MRP             *float64   `json:"mrp,omitempty"`
-	Discount        *float64   `json:"discount,omitempty"`
-	BuyingPrice     *float64   `json:"buyingPrice,omitempty"`
-	Type            *string    `json:"type,omitempty"`
-	StockStatus     *string    `json:"stockStatus,omitempty"`
-	Category        *string    `json:"category,omitempty"`
-	PackSize        *PackSize  `json:"packSize,omitempty"`
-	PackPrice       *PackPrice `json:"packPrice,omitempty"`
+	Name            *string      `json:"name,omitempty"`
+	Description     *string      `json:"description,omitempty"`
+	Barcode         *string      `json:"barcode,omitempty"` // "" assigns a generated code
+	Strength        *string      `json:"strength,omitempty"`
+	GenericName     *string      `json:"genericName,omitempty"`
+	Manufacture     *string      `json:"manufacture,omitempty"`
+	Supplier        *string      `json:"supplier,omitempty"`
+	SupplierContact *string      `json:"supplierContact,omitempty"`
+	RackNo          *string      `json:"rackNo,omitempty"`
+	RackLocation    *string      `json:"rackLocation,omitempty"`
+	InStock         *int         `json:"inStock,omitempty"`
+	StockReason     *string      `json:"stockReason,omitempty"`
+	StockNote       *string      `json:"stockNote,omitempty"`
+	Price           *float64     `json:"price,omitempty"`
+	MRP             *float64     `json:"mrp,omitempty"`
+	Discount        *float64     `json:"discount,omitempty"`
+	BuyingPrice     *float64     `json:"buyingPrice,omitempty"`
+	Type            *string      `json:"type,omitempty"`
+	StockStatus     *string      `json:"stockStatus,omitempty"`
+	Category        *string      `json:"category,omitempty"`
+	PackSize        *PackSize    `json:"packSize,omitempty"`
+	PackPrice       *PackPrice   `json:"packPrice,omitempty"`
+	PackBarcode     *PackBarcode `json:"packBarcode,omitempty"` // replaces both; "" clears
+
+	// Packaging replaces every packaging level; nil leaves them unchanged
+	Packaging []PackagingRequest `json:"packaging,omitempty"`
+
+	// Ingredients replaces the active ingredients; nil re-reads them from a
+	// changed genericName or strength
+	Ingredients []IngredientRequest `json:"ingredients,omitempty"`
+
+	RequiresPrescription *bool         `json:"requiresPrescription,omitempty"`
+	Schedule             *DrugSchedule `json:"schedule,omitempty"` // "" removes the schedule
+}
+
+// RegenerateProductCodesRequest - Request DTO for POST /api/products/codes/regenerate
+// With no product IDs every product is renumbered
+type RegenerateProductCodesRequest struct {
+	ProductIDs  []string `json:"productIds,omitempty"`
+	OnlyMissing bool     `json:"onlyMissing,omitempty"`
+}
+
+// ProductCodeChange - One product whose code was regenerated
+type ProductCodeChange struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OldCode string `json:"oldCode"`
+	NewCode string `json:"newCode"`
+}
+
+// BarcodeLookupResponse - Response DTO for GET /api/products/barcode/:code
+// Tells the counter which product and pack a scanned code stands for
+type BarcodeLookupResponse struct {
+	Barcode      string          `json:"barcode"`
+	PackType     PackType        `json:"packType"`
+	UnitsPerPack int             `json:"unitsPerPack"`
+	Price        float64         `json:"price"`
+	MRP          float64         `json:"mrp"`
+	Product      ProductResponse `json:"product"`
 }
 
 // DeleteProductResponse - Response DTO for DELETE /api/products/:id
@@ -236,26 +372,30 @@ type CreateInvoiceRequest struct {
 // =====================================================
 
 type CustomerDTO struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Phone       string `json:"phone"`
-	Email       string `json:"email"`
-	Address     string `json:"address"`
-	MemberSince string `json:"memberSince"`
+	ID          int     `json:"id"`
+	Name        string  `json:"name"`
+	Phone       string  `json:"phone"`
+	Email       string  `json:"email"`
+	Address     string  `json:"address"`
+	CreditLimit float64 `json:"creditLimit"` // 0 means no limit
+	Balance     float64 `json:"balance"`     // outstanding on unpaid invoices
+	MemberSince string  `json:"memberSince"`
 }
 
 type CreateCustomerRequest struct {
-	Name    string `json:"name"`
-	Phone   string `json:"phone"`
-	Email   string `json:"email"`
-	Address string `json:"address"`
+	Name        string  `json:"name"`
+	Phone       string  `json:"phone"`
+	Email       string  `json:"email"`
+	Address     string  `json:"address"`
+	CreditLimit float64 `json:"creditLimit"`
 }
 
 type UpdateCustomerRequest struct {
-	Name    *string `json:"name,omitempty"`
-	Phone   *string `json:"phone,omitempty"`
-	Email   *string `json:"email,omitempty"`
-	Address *string `json:"address,omitempty"`
+	Name        *string  `json:"name,omitempty"`
+	Phone       *string  `json:"phone,omitempty"`
+	Email       *string  `json:"email,omitempty"`
+	Address     *string  `json:"address,omitempty"`
+	CreditLimit *float64 `json:"creditLimit,omitempty"`
 }
 
 type CustomerListResponse struct {
@@ -127,6 +127,11 @@ type ProductStockHistory struct {
 	ChangeType       StockChangeType `json:"change_type"`
 	PreviousQuantity int             `json:"previous_quantity"`
 	NewQuantity      int             `json:"new_quantity"`
+	ReferenceType    sql.NullString  `json:"reference_type,omitempty"`
+	ReferenceID      sql.NullInt64   `json:"reference_id,omitempty"`
+	ReasonCode       sql.NullString  `json:"reason_code,omitempty"`
+	Note             sql.NullString  `json:"note,omitempty"`
+	UserID           sql.NullInt64   `json:"user_id,omitempty"`
 	CreatedAt        time.Time       `json:"created_at"`
 	StockExpiry      sql.NullTime    `json:"stock_expiry,omitempty"`
 }
@@ -146,6 +151,7 @@ type Invoice struct {
 	InvoiceType  InvoiceType   `json:"invoice_type"`
 	Subtotal     float64       `json:"subtotal"`
 	Discount     float64       `json:"discount"`
+	VAT          float64       `json:"vat"`
 	Total        float64       `json:"total"`
 	PaidAmount   float64       `json:"paid_amount"`
 	Balance      float64       `json:"balance"`
@@ -160,9 +166,24 @@ type InvoiceItem struct {
 	ProductID int       `json:"product_id"`
 	PackType  PackType  `json:"pack_type"`
 	Quantity  float64   `json:"quantity"`
+	Units     int       `json:"units"`
+	UnitPrice float64   `json:"unit_price"`
+	Discount  float64   `json:"discount"`
+	VAT       float64   `json:"vat"`
+	Total     float64   `json:"total"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// InvoiceItemBatch records how many units of an invoice line came from a batch
+type InvoiceItemBatch struct {
+	ID            int       `json:"id"`
+	InvoiceItemID int       `json:"invoice_item_id"`
+	BatchFkID     int       `json:"batch_fk_id"`
+	Quantity      int       `json:"quantity"`
+	CostPrice     float64   `json:"cost_price"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
 type Vendor struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
@@ -186,18 +207,28 @@ type Distributor struct {
 
 type ProductStockPurchase struct {
 	ID             int            `json:"id"`
-	Total          int            `json:"total"`
+	SupplierID     sql.NullInt64  `json:"supplier_id,omitempty"`
+	InvoiceRef     string         `json:"invoice_ref,omitempty"`
+	ReceivedDate   time.Time      `json:"received_date"`
+	Total          float64        `json:"total"`
 	PurchaseStatus PurchaseStatus `json:"purchase_status"`
-	Due            int            `json:"due"`
-	PaidAmount     int            `json:"paid_amount"`
+	Due            float64        `json:"due"`
+	PaidAmount     float64        `json:"paid_amount"`
+	Notes          string         `json:"notes,omitempty"`
 	CreatedAt      time.Time      `json:"created_at"`
 }
 
 type ProductStockPurchaseItem struct {
-	ID        int       `json:"id"`
-	PspFkID   int       `json:"psp_fk_id"`
-	ProductID int       `json:"product_id"`
-	PackType  PackType  `json:"pack_type"`
-	Quantity  float64   `json:"quantity"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int           `json:"id"`
+	PspFkID    int           `json:"psp_fk_id"`
+	ProductID  int           `json:"product_id"`
+	PackType   PackType      `json:"pack_type"`
+	Quantity   float64       `json:"quantity"`
+	Units      int           `json:"units"`
+	BatchNo    string        `json:"batch_no,omitempty"`
+	ExpiryDate sql.NullTime  `json:"expiry_date,omitempty"`
+	CostPrice  float64       `json:"cost_price"`
+	Total      float64       `json:"total"`
+	BatchFkID  sql.NullInt64 `json:"batch_fk_id,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
 }
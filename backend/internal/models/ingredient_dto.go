@@ -0,0 +1,47 @@
+package models
+
+// =====================================================
+// Active Ingredient API DTOs
+// =====================================================
+
+// IngredientDTO - an active ingredient and how many products contain it
+type IngredientDTO struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	ProductCount int    `json:"productCount"`
+}
+
+// CreateIngredientRequest - Request DTO for POST /api/ingredients
+type CreateIngredientRequest struct {
+	Name string `json:"name"`
+}
+
+// ProductIngredientDTO - one active ingredient of a product with its
+// strength, e.g. {"name": "Rosuvastatin", "strength": 10, "unit": "mg"}
+type ProductIngredientDTO struct {
+	IngredientID int      `json:"ingredientId"`
+	Name         string   `json:"name"`
+	Strength     *float64 `json:"strength,omitempty"`
+	Unit         string   `json:"unit,omitempty"`
+}
+
+// IngredientRequest sets one active ingredient of a product. Unknown
+// ingredients are created.
+type IngredientRequest struct {
+	Name     string   `json:"name"`
+	Strength *float64 `json:"strength,omitempty"`
+	Unit     string   `json:"unit,omitempty"`
+}
+
+// IngredientProductDTO - a product found by ingredient, with all of its
+// ingredients
+type IngredientProductDTO struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	GenericName          string                 `json:"genericName,omitempty"`
+	Strength             string                 `json:"strength,omitempty"`
+	Price                float64                `json:"price"`
+	InStock              int                    `json:"inStock"`
+	RequiresPrescription bool                   `json:"requiresPrescription"`
+	Ingredients          []ProductIngredientDTO `json:"ingredients"`
+}
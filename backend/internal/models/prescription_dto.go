@@ -0,0 +1,69 @@
+package models
+
+// =====================================================
+// Prescription API DTOs
+// =====================================================
+
+// PrescriptionItemRequest is one prescribed medicine. Quantity is in base
+// units per fill; Refills are the repeats allowed after the first fill.
+type PrescriptionItemRequest struct {
+	ProductID string `json:"productId"`
+	Dosage    string `json:"dosage,omitempty"` // e.g. "1 tablet three times daily after meals"
+	Quantity  int    `json:"quantity"`
+	Refills   int    `json:"refills"`
+}
+
+// CreatePrescriptionRequest - Request DTO for POST /api/prescriptions
+type CreatePrescriptionRequest struct {
+	CustomerID      *int                      `json:"customerId,omitempty"`
+	PatientName     string                    `json:"patientName,omitempty"` // defaults to the customer's name
+	PrescriberName  string                    `json:"prescriberName"`
+	PrescriberRegNo string                    `json:"prescriberRegNo"`
+	IssueDate       string                    `json:"issueDate"` // YYYY-MM-DD
+	Notes           string                    `json:"notes,omitempty"`
+	Image           string                    `json:"image,omitempty"` // scanned copy, as a URL or data URI
+	Items           []PrescriptionItemRequest `json:"items"`
+}
+
+type PrescriptionItemDTO struct {
+	ID             int    `json:"id"`
+	ProductID      string `json:"productId"`
+	ProductName    string `json:"productName"`
+	Strength       string `json:"strength,omitempty"`
+	Dosage         string `json:"dosage,omitempty"`
+	Quantity       int    `json:"quantity"`
+	Refills        int    `json:"refills"`
+	FillsDispensed int    `json:"fillsDispensed"`
+	RemainingFills int    `json:"remainingFills"` // including the first fill
+}
+
+// PrescriptionFillDTO - an invoice line dispensed against a prescription
+type PrescriptionFillDTO struct {
+	InvoiceID   int      `json:"invoiceId"`
+	InvoiceNo   string   `json:"invoiceNo"`
+	ProductID   string   `json:"productId"`
+	ProductName string   `json:"productName"`
+	PackType    PackType `json:"packType"`
+	Quantity    float64  `json:"quantity"`
+	Units       int      `json:"units"`
+	DispensedAt string   `json:"dispensedAt"`
+}
+
+// PrescriptionDTO - Response DTO for a prescription. Image and Fills are
+// only filled in by GET /api/prescriptions/{id}.
+type PrescriptionDTO struct {
+	ID              int                   `json:"id"`
+	PrescriptionNo  string                `json:"prescriptionNo"`
+	CustomerID      *int                  `json:"customerId,omitempty"`
+	CustomerName    string                `json:"customerName,omitempty"`
+	PatientName     string                `json:"patientName"`
+	PrescriberName  string                `json:"prescriberName"`
+	PrescriberRegNo string                `json:"prescriberRegNo"`
+	IssueDate       string                `json:"issueDate"`
+	Notes           string                `json:"notes,omitempty"`
+	HasImage        bool                  `json:"hasImage"`
+	Image           string                `json:"image,omitempty"`
+	CreatedAt       string                `json:"createdAt"`
+	Items           []PrescriptionItemDTO `json:"items"`
+	Fills           []PrescriptionFillDTO `json:"fills,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+// =====================================================
+// Purchase / Goods Receipt API DTOs
+// =====================================================
+
+// PurchaseItemRequest is one received line. CostPrice is per pack.
+type PurchaseItemRequest struct {
+	ProductID  string   `json:"productId"`
+	PackType   PackType `json:"packType"`
+	Quantity   float64  `json:"quantity"`
+	BatchNo    string   `json:"batchNo"`
+	ExpiryDate string   `json:"expiryDate"`
+	CostPrice  float64  `json:"costPrice"`
+}
+
+// CreatePurchaseRequest - Request DTO for POST /api/purchases
+type CreatePurchaseRequest struct {
+	SupplierID   string                `json:"supplierId"`
+	InvoiceRef   string                `json:"invoiceRef"`
+	OrderedDate  string                `json:"orderedDate,omitempty"` // for supplier lead time
+	ReceivedDate string                `json:"receivedDate,omitempty"`
+	PaidAmount   float64               `json:"paidAmount"`
+	Notes        string                `json:"notes,omitempty"`
+	Items        []PurchaseItemRequest `json:"items"`
+}
+
+type PurchaseItemDTO struct {
+	ID          int      `json:"id"`
+	ProductID   string   `json:"productId"`
+	ProductName string   `json:"productName"`
+	PackType    PackType `json:"packType"`
+	Quantity    float64  `json:"quantity"`
+	Units       int      `json:"units"`
+	BatchID     int      `json:"batchId"`
+	BatchNo     string   `json:"batchNo"`
+	ExpiryDate  string   `json:"expiryDate,omitempty"`
+	CostPrice   float64  `json:"costPrice"`
+	Total       float64  `json:"total"`
+}
+
+// PurchaseDTO - a goods receipt with its lines
+type PurchaseDTO struct {
+	ID             int               `json:"id"`
+	SupplierID     string            `json:"supplierId,omitempty"`
+	SupplierName   string            `json:"supplierName,omitempty"`
+	InvoiceRef     string            `json:"invoiceRef,omitempty"`
+	OrderedDate    string            `json:"orderedDate,omitempty"`
+	ReceivedDate   string            `json:"receivedDate"`
+	Total          float64           `json:"total"`
+	PaidAmount     float64           `json:"paidAmount"`
+	Due            float64           `json:"due"`
+	PurchaseStatus PurchaseStatus    `json:"purchaseStatus"`
+	Notes          string            `json:"notes,omitempty"`
+	Items          []PurchaseItemDTO `json:"items,omitempty"`
+}
@@ -0,0 +1,81 @@
+package models
+
+// =====================================================
+// Batch Recall API DTOs
+// =====================================================
+
+// CreateRecallRequest - Request DTO for POST /api/recalls.
+// BatchNos are the product's batch numbers (product_batch.batch_id).
+type CreateRecallRequest struct {
+	ProductID string   `json:"productId"`
+	BatchNos  []string `json:"batchNos"`
+	Reference string   `json:"reference,omitempty"` // manufacturer or regulator notice number
+	Reason    string   `json:"reason,omitempty"`
+}
+
+// RecallBatchDTO - a recalled batch. Quantity is the stock on hand when the
+// recall was raised; Sold and Returned count units that reached customers.
+type RecallBatchDTO struct {
+	BatchID        int         `json:"batchId"`
+	BatchNo        string      `json:"batchNo"`
+	ExpiryDate     string      `json:"expiryDate,omitempty"`
+	SupplierID     string      `json:"supplierId,omitempty"`
+	SupplierName   string      `json:"supplierName,omitempty"`
+	PreviousStatus BatchStatus `json:"previousStatus,omitempty"`
+	Quantity       int         `json:"quantity"`
+	Remaining      int         `json:"remaining"`
+	Sold           int         `json:"sold"`
+	Returned       int         `json:"returned"`
+}
+
+// RecallRackDTO - recalled units to pull from a rack
+type RecallRackDTO struct {
+	Rack     string   `json:"rack"`
+	Location string   `json:"location,omitempty"`
+	Quantity int      `json:"quantity"`
+	BatchNos []string `json:"batchNos"`
+}
+
+// RecallSaleDTO - an invoice line that received units from a recalled batch,
+// with the customer's contact details
+type RecallSaleDTO struct {
+	InvoiceID       int    `json:"invoiceId"`
+	InvoiceNo       string `json:"invoiceNo"`
+	SoldAt          string `json:"soldAt"`
+	BatchNo         string `json:"batchNo"`
+	Quantity        int    `json:"quantity"`
+	Returned        int    `json:"returned"`
+	CustomerID      *int   `json:"customerId,omitempty"`
+	CustomerName    string `json:"customerName,omitempty"`
+	CustomerPhone   string `json:"customerPhone,omitempty"`
+	CustomerEmail   string `json:"customerEmail,omitempty"`
+	CustomerAddress string `json:"customerAddress,omitempty"`
+}
+
+// RecallDTO - a batch recall and everything needed to act on it. Rack stock,
+// sales and company returns are only filled in for a single recall.
+type RecallDTO struct {
+	ID                int                `json:"id"`
+	RecallNo          string             `json:"recallNo"`
+	ProductID         string             `json:"productId"`
+	ProductName       string             `json:"productName"`
+	Reference         string             `json:"reference,omitempty"`
+	Reason            string             `json:"reason,omitempty"`
+	CreatedAt         string             `json:"createdAt"`
+	Batches           []RecallBatchDTO   `json:"batches"`
+	Racks             []RecallRackDTO    `json:"racks,omitempty"`
+	Sales             []RecallSaleDTO    `json:"sales,omitempty"`
+	CustomersAffected int                `json:"customersAffected"`
+	WalkInSales       int                `json:"walkInSales"` // invoices without a customer to contact
+	CompanyReturns    []CompanyReturnDTO `json:"companyReturns,omitempty"`
+	Unreturned        []string           `json:"unreturned,omitempty"` // batches with stock but no supplier to return to
+}
+
+// HideCustomers drops the customer and contact details from the sales
+func (r *RecallDTO) HideCustomers() {
+	for i := range r.Sales {
+		s := &r.Sales[i]
+		s.CustomerID = nil
+		s.CustomerName, s.CustomerPhone, s.CustomerEmail, s.CustomerAddress = "", "", "", ""
+	}
+}
@@ -0,0 +1,186 @@
+package models
+
+// =====================================================
+// Report API DTOs
+// =====================================================
+
+// ExpiryReportBatchDTO - one batch with stock left, for GET /api/reports/expiry
+type ExpiryReportBatchDTO struct {
+	BatchID     int          `json:"batchId"`
+	BatchNo     string       `json:"batchNo"`
+	ProductID   string       `json:"productId"`
+	ProductName string       `json:"productName"`
+	ProductCode string       `json:"productCode,omitempty"`
+	Strength    string       `json:"strength,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Category    string       `json:"category,omitempty"`
+	RackNo      string       `json:"rackNo,omitempty"`
+	SupplierID  string       `json:"supplierId,omitempty"`
+	Supplier    string       `json:"supplier,omitempty"`
+	ExpiryDate  string       `json:"expiryDate,omitempty"`
+	DaysLeft    *int         `json:"daysLeft,omitempty"`
+	Bucket      ExpiryBucket `json:"bucket"`
+	Status      BatchStatus  `json:"status"`
+	Quantity    int          `json:"quantity"`
+	CostPrice   float64      `json:"costPrice"`
+	Value       float64      `json:"value"` // quantity x cost price
+}
+
+// ExpiryBucketSummaryDTO - totals for one expiry bucket
+type ExpiryBucketSummaryDTO struct {
+	Bucket  ExpiryBucket `json:"bucket"`
+	Batches int          `json:"batches"`
+	Units   int          `json:"units"`
+	Value   float64      `json:"value"`
+}
+
+// ExpiryReportDTO - Response data for GET /api/reports/expiry
+type ExpiryReportDTO struct {
+	AsOf        string                   `json:"asOf"`
+	Summary     []ExpiryBucketSummaryDTO `json:"summary"`
+	AtRiskValue float64                  `json:"atRiskValue"` // expired or expiring within 90 days
+	Batches     []ExpiryReportBatchDTO   `json:"batches"`
+}
+
+// ProfitLossLineDTO - profit and loss figures for the whole period or for one
+// day, category, generic or supplier
+type ProfitLossLineDTO struct {
+	Key                string  `json:"key,omitempty"`
+	Sales              float64 `json:"sales"`   // invoiced, net of discounts, excluding VAT
+	Returns            float64 `json:"returns"` // customer returns, excluding VAT
+	NetRevenue         float64 `json:"netRevenue"`
+	COGS               float64 `json:"cogs"` // batch cost of the units kept by customers
+	GrossProfit        float64 `json:"grossProfit"`
+	WriteOffs          float64 `json:"writeOffs"`          // expired, damaged and lost stock at cost
+	SupplierReturnLoss float64 `json:"supplierReturnLoss"` // expected credit not received on settled company returns
+	NetProfit          float64 `json:"netProfit"`
+	GrossMargin        float64 `json:"grossMargin"` // percent of net revenue
+	NetMargin          float64 `json:"netMargin"`   // percent of net revenue
+}
+
+// ProfitLossReportDTO - Response data for GET /api/reports/profit-loss
+type ProfitLossReportDTO struct {
+	From              string              `json:"from"`
+	To                string              `json:"to"`
+	Summary           ProfitLossLineDTO   `json:"summary"`
+	WriteOffsByReason map[string]float64  `json:"writeOffsByReason"`
+	ByDay             []ProfitLossLineDTO `json:"byDay"`
+	ByCategory        []ProfitLossLineDTO `json:"byCategory"`
+	ByGeneric         []ProfitLossLineDTO `json:"byGeneric"`
+	BySupplier        []ProfitLossLineDTO `json:"bySupplier"`
+}
+
+// DashboardPeriod selects the window the dashboard compares with the one before it
+type DashboardPeriod string
+
+const (
+	DashboardToday DashboardPeriod = "today" // today vs yesterday
+	DashboardWeek  DashboardPeriod = "week"  // last 7 days vs the 7 before
+	DashboardMonth DashboardPeriod = "month" // last 30 days vs the 30 before
+)
+
+// Days returns the length of the period in days, 0 when it is unknown
+func (p DashboardPeriod) Days() int {
+	switch p {
+	case DashboardToday:
+		return 1
+	case DashboardWeek:
+		return 7
+	case DashboardMonth:
+		return 30
+	}
+	return 0
+}
+
+// DashboardMetricDTO - a figure for the period and the period before it
+type DashboardMetricDTO struct {
+	Value    float64  `json:"value"`
+	Previous float64  `json:"previous"`
+	Change   *float64 `json:"change"` // percent; null when the previous value is 0
+}
+
+// DashboardReceivablesDTO - unpaid invoice balances
+type DashboardReceivablesDTO struct {
+	Amount   float64 `json:"amount"`
+	Invoices int     `json:"invoices"`
+}
+
+// DashboardTopSellerDTO - a best-selling product by total units sold
+type DashboardTopSellerDTO struct {
+	ProductID    string `json:"productId"`
+	Name         string `json:"name"`
+	TotalSold    int    `json:"totalSold"`
+	PeriodSold   int    `json:"periodSold"`
+	PreviousSold int    `json:"previousSold"`
+}
+
+// DashboardDTO - Response data for GET /api/dashboard
+type DashboardDTO struct {
+	Period        DashboardPeriod         `json:"period"`
+	From          string                  `json:"from"`
+	To            string                  `json:"to"`
+	PreviousFrom  string                  `json:"previousFrom"`
+	PreviousTo    string                  `json:"previousTo"`
+	Sales         DashboardMetricDTO      `json:"sales"`
+	Invoices      DashboardMetricDTO      `json:"invoices"`
+	AverageBasket DashboardMetricDTO      `json:"averageBasket"`
+	PurchaseSpend *DashboardMetricDTO     `json:"purchaseSpend,omitempty"` // hidden from cashiers
+	Receivables   DashboardReceivablesDTO `json:"receivables"`
+	LowStock      int                     `json:"lowStock"`     // products below their stock alert level
+	ExpiringSoon  int                     `json:"expiringSoon"` // active batches with stock expiring within 30 days
+	TopSellers    []DashboardTopSellerDTO `json:"topSellers"`
+}
+
+// ControlledRegisterBatchDTO - the part of a register entry from or to one batch
+type ControlledRegisterBatchDTO struct {
+	BatchID  *int   `json:"batchId,omitempty"`
+	BatchNo  string `json:"batchNo"`
+	Quantity int    `json:"quantity"`
+}
+
+// ControlledRegisterEntryDTO - one line of a controlled substance register
+type ControlledRegisterEntryDTO struct {
+	ID              int                          `json:"id"`
+	Date            string                       `json:"date"`
+	EntryType       string                       `json:"entryType"` // ledger change type, or "opening", "reclassified", "closing"
+	ReferenceType   string                       `json:"referenceType,omitempty"`
+	ReferenceID     *int                         `json:"referenceId,omitempty"`
+	Reference       string                       `json:"reference,omitempty"` // invoice, purchase or return number
+	Counterparty    string                       `json:"counterparty,omitempty"`
+	PrescriberName  string                       `json:"prescriberName,omitempty"`
+	PrescriberRegNo string                       `json:"prescriberRegNo,omitempty"`
+	QuantityIn      int                          `json:"quantityIn"`
+	QuantityOut     int                          `json:"quantityOut"`
+	Balance         int                          `json:"balance"`
+	Batches         []ControlledRegisterBatchDTO `json:"batches"`
+	Note            string                       `json:"note,omitempty"`
+	UserID          *int                         `json:"userId,omitempty"`
+	StockHistoryID  *int                         `json:"stockHistoryId,omitempty"`
+}
+
+// ControlledRegisterReconciliationDTO compares the register with the stock
+// ledger (product_stock_history) over the same period
+type ControlledRegisterReconciliationDTO struct {
+	LedgerOpening     int   `json:"ledgerOpening"`
+	LedgerClosing     int   `json:"ledgerClosing"`
+	LedgerMovements   int   `json:"ledgerMovements"`
+	RegisterMovements int   `json:"registerMovements"` // entries linked to a ledger row
+	UnregisteredIDs   []int `json:"unregisteredIds"`   // ledger rows with no register entry
+	Reconciled        bool  `json:"reconciled"`
+}
+
+// ControlledRegisterDTO - Response data for GET /api/reports/controlled-register
+type ControlledRegisterDTO struct {
+	ProductID      string                              `json:"productId"`
+	Name           string                              `json:"name"`
+	Strength       string                              `json:"strength,omitempty"`
+	Schedule       DrugSchedule                        `json:"schedule,omitempty"`
+	From           string                              `json:"from"`
+	To             string                              `json:"to"`
+	OpeningBalance int                                 `json:"openingBalance"`
+	TotalIn        int                                 `json:"totalIn"`
+	TotalOut       int                                 `json:"totalOut"`
+	ClosingBalance int                                 `json:"closingBalance"`
+	Entries        []ControlledRegisterEntryDTO        `json:"entries"`
+	Reconciliation ControlledRegisterReconciliationDTO `json:"reconciliation"`
+}
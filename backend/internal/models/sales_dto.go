@@ -0,0 +1,104 @@
+package models
+
+// =====================================================
+// Sales / Checkout API DTOs
+// =====================================================
+
+// SaleItemRequest is one cart line. ProductID accepts "prod_001" or "1".
+type SaleItemRequest struct {
+	ProductID      string   `json:"productId"`
+	PackType       PackType `json:"packType"`
+	Quantity       float64  `json:"quantity"`
+	PrescriptionID *int     `json:"prescriptionId,omitempty"` // required for prescription-only products
+}
+
+// CreateSaleRequest - Request DTO for POST /api/sales
+type CreateSaleRequest struct {
+	CustomerID      *int              `json:"customerId,omitempty"`
+	InvoiceType     InvoiceType       `json:"invoiceType,omitempty"`
+	DiscountPercent float64           `json:"discountPercent"`
+	DiscountAmount  float64           `json:"discountAmount"`
+	PaidAmount      float64           `json:"paidAmount"`
+	Notes           string            `json:"notes,omitempty"`
+	Items           []SaleItemRequest `json:"items"`
+}
+
+type SaleItemDTO struct {
+	ID          int      `json:"id"`
+	ProductID   string   `json:"productId"`
+	ProductName string   `json:"productName"`
+	PackType    PackType `json:"packType"`
+	Quantity    float64  `json:"quantity"`
+	Units       int      `json:"units"`
+	UnitPrice   float64  `json:"unitPrice"`
+	Discount    float64  `json:"discount"`
+	VAT         float64  `json:"vat"`
+	Total       float64  `json:"total"`
+
+	PrescriptionID *int `json:"prescriptionId,omitempty"`
+}
+
+// SaleDTO - Response DTO for a posted sale (invoice with its lines)
+type SaleDTO struct {
+	ID           int           `json:"id"`
+	InvoiceNo    string        `json:"invoiceNo"`
+	CustomerID   *int          `json:"customerId,omitempty"`
+	CustomerName string        `json:"customerName,omitempty"`
+	InvoiceType  InvoiceType   `json:"invoiceType"`
+	Subtotal     float64       `json:"subtotal"`
+	Discount     float64       `json:"discount"`
+	VAT          float64       `json:"vat"`
+	Total        float64       `json:"total"`
+	PaidAmount   float64       `json:"paidAmount"`
+	Balance      float64       `json:"balance"`
+	ChangeDue    float64       `json:"changeDue"`
+	Status       InvoiceStatus `json:"status"`
+	Notes        string        `json:"notes,omitempty"`
+	CreatedAt    string        `json:"createdAt"`
+	Items        []SaleItemDTO `json:"items"`
+}
+
+// =====================================================
+// Customer Return API DTOs
+// =====================================================
+
+// CustomerReturnItemRequest returns Quantity (in the line's pack type) of an invoice line
+type CustomerReturnItemRequest struct {
+	InvoiceItemID int     `json:"invoiceItemId"`
+	Quantity      float64 `json:"quantity"`
+	Damaged       bool    `json:"damaged"`
+}
+
+// CreateCustomerReturnRequest - Request DTO for POST /api/sales/{id}/returns
+type CreateCustomerReturnRequest struct {
+	Reason string                      `json:"reason,omitempty"`
+	Items  []CustomerReturnItemRequest `json:"items"`
+}
+
+type CustomerReturnItemDTO struct {
+	ID            int      `json:"id"`
+	InvoiceItemID int      `json:"invoiceItemId"`
+	ProductID     string   `json:"productId"`
+	ProductName   string   `json:"productName"`
+	PackType      PackType `json:"packType"`
+	Quantity      float64  `json:"quantity"`
+	Units         int      `json:"units"`
+	Amount        float64  `json:"amount"`
+	Damaged       bool     `json:"damaged"`
+}
+
+// CustomerReturnDTO - a credit note. CreditedAmount reduced the invoice
+// balance; RefundAmount was paid back in cash.
+type CustomerReturnDTO struct {
+	ID             int                     `json:"id"`
+	CreditNoteNo   string                  `json:"creditNoteNo"`
+	InvoiceID      int                     `json:"invoiceId"`
+	InvoiceNo      string                  `json:"invoiceNo"`
+	CustomerID     *int                    `json:"customerId,omitempty"`
+	Total          float64                 `json:"total"`
+	CreditedAmount float64                 `json:"creditedAmount"`
+	RefundAmount   float64                 `json:"refundAmount"`
+	Reason         string                  `json:"reason,omitempty"`
+	CreatedAt      string                  `json:"createdAt"`
+	Items          []CustomerReturnItemDTO `json:"items"`
+}
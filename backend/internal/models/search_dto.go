@@ -0,0 +1,18 @@
+package models
+
+// =====================================================
+// Search API DTOs
+// =====================================================
+
+// ProductSuggestionDTO - one autocomplete match for GET /api/search/suggest
+type ProductSuggestionDTO struct {
+	ID                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	GenericName          string  `json:"genericName,omitempty"`
+	Strength             string  `json:"strength,omitempty"`
+	Manufacture          string  `json:"manufacture,omitempty"`
+	Barcode              string  `json:"barcode,omitempty"`
+	Price                float64 `json:"price"`
+	InStock              int     `json:"inStock"`
+	RequiresPrescription bool    `json:"requiresPrescription"`
+}
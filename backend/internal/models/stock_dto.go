@@ -0,0 +1,29 @@
+package models
+
+// =====================================================
+// Stock Ledger API DTOs
+// =====================================================
+
+// StockHistoryDTO - one ledger movement for GET /api/inventory/medicines/{id}/stock-history
+type StockHistoryDTO struct {
+	ID               int             `json:"id"`
+	ChangeAmount     int             `json:"changeAmount"`
+	ChangeType       StockChangeType `json:"changeType"`
+	PreviousQuantity int             `json:"previousQuantity"`
+	NewQuantity      int             `json:"newQuantity"`
+	ReferenceType    string          `json:"referenceType,omitempty"`
+	ReferenceID      *int            `json:"referenceId,omitempty"`
+	Reason           string          `json:"reason,omitempty"`
+	Note             string          `json:"note,omitempty"`
+	UserID           *int            `json:"userId,omitempty"`
+	StockExpiry      string          `json:"stockExpiry,omitempty"`
+	CreatedAt        string          `json:"createdAt"`
+}
+
+// StockAdjustmentRequest - Request DTO for POST /api/inventory/medicines/{id}/stock-adjustments
+// Change is a signed number of base units.
+type StockAdjustmentRequest struct {
+	Change int                   `json:"change"`
+	Reason StockAdjustmentReason `json:"reason"`
+	Note   string                `json:"note,omitempty"`
+}
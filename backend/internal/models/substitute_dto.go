@@ -0,0 +1,42 @@
+package models
+
+// =====================================================
+// Generic Substitution API DTOs
+// =====================================================
+
+// SubstituteDTO - an in-stock product with the same ingredients and strength.
+// InStock counts active, unexpired batches only.
+type SubstituteDTO struct {
+	ID                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	GenericName          string  `json:"genericName"`
+	Strength             string  `json:"strength,omitempty"`
+	Manufacture          string  `json:"manufacture,omitempty"`
+	Price                float64 `json:"price"`
+	MRP                  float64 `json:"mrp"`
+	BuyingPrice          float64 `json:"buyingPrice,omitempty"`
+	ProfitMargin         float64 `json:"profitMargin,omitempty"`
+	InStock              int     `json:"inStock"`
+	NearestExpiry        string  `json:"nearestExpiry,omitempty"`
+	RackNo               string  `json:"rackNo,omitempty"`
+	RequiresPrescription bool    `json:"requiresPrescription"`
+}
+
+// SubstitutesDTO - the requested product and its substitutes, best first.
+// Ingredients is the normalized ingredient set they were matched on.
+type SubstitutesDTO struct {
+	ProductID   string          `json:"productId"`
+	ProductName string          `json:"productName"`
+	GenericName string          `json:"genericName"`
+	Strength    string          `json:"strength"`
+	Ingredients []string        `json:"ingredients"`
+	Substitutes []SubstituteDTO `json:"substitutes"`
+}
+
+// HideCost clears buying prices and margins; the ranking is kept
+func (s *SubstitutesDTO) HideCost() {
+	for i := range s.Substitutes {
+		s.Substitutes[i].BuyingPrice = 0
+		s.Substitutes[i].ProfitMargin = 0
+	}
+}
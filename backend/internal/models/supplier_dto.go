@@ -54,3 +54,90 @@ type DeleteSupplierResponse struct {
 	Success bool   `json:"success"`
 	Message string `json:"message"`
 }
+
+// SupplierPaymentRequest - Request DTO for POST /api/suppliers/{id}/payments
+type SupplierPaymentRequest struct {
+	Amount    float64       `json:"amount"`
+	Method    PaymentMethod `json:"method,omitempty"` // defaults to cash
+	Reference string        `json:"reference,omitempty"`
+	Notes     string        `json:"notes,omitempty"`
+}
+
+// PurchaseAllocationDTO - the part of a payment or credit applied to one purchase
+type PurchaseAllocationDTO struct {
+	PurchaseID int            `json:"purchaseId"`
+	InvoiceRef string         `json:"invoiceRef,omitempty"`
+	Amount     float64        `json:"amount"`
+	Due        float64        `json:"due"` // left on the purchase afterwards
+	Status     PurchaseStatus `json:"status"`
+}
+
+// SupplierPaymentDTO - a payment to a supplier, or a credit for a company return
+type SupplierPaymentDTO struct {
+	ID              int                     `json:"id"`
+	PaymentNo       string                  `json:"paymentNo"`
+	SupplierID      string                  `json:"supplierId"`
+	Amount          float64                 `json:"amount"`
+	Method          PaymentMethod           `json:"method,omitempty"`
+	Reference       string                  `json:"reference,omitempty"`
+	Notes           string                  `json:"notes,omitempty"`
+	CompanyReturnID *int                    `json:"companyReturnId,omitempty"`
+	CreatedAt       string                  `json:"createdAt"`
+	Allocations     []PurchaseAllocationDTO `json:"allocations"`
+	Unapplied       float64                 `json:"unapplied"` // credit left over with no open purchase to apply to
+	Payable         float64                 `json:"payable"`   // still owed to the supplier afterwards
+}
+
+// SupplierLedgerEntryDTO - one line of a supplier statement. Credit is what
+// we came to owe, Debit what we paid or were credited.
+type SupplierLedgerEntryDTO struct {
+	Date      string          `json:"date"`
+	Type      LedgerEntryType `json:"type"`
+	Reference string          `json:"reference"` // purchase, payment or company return number
+	Note      string          `json:"note,omitempty"`
+	Debit     float64         `json:"debit"`
+	Credit    float64         `json:"credit"`
+	Balance   float64         `json:"balance"` // owed to the supplier after this line
+}
+
+// SupplierLedgerDTO - Response data for GET /api/suppliers/{id}/ledger
+type SupplierLedgerDTO struct {
+	SupplierID     string                   `json:"supplierId"`
+	Name           string                   `json:"name"`
+	From           string                   `json:"from,omitempty"`
+	To             string                   `json:"to,omitempty"`
+	OpeningBalance float64                  `json:"openingBalance"`
+	ClosingBalance float64                  `json:"closingBalance"`
+	Payable        float64                  `json:"payable"`    // due on open purchases
+	OpenCredit     float64                  `json:"openCredit"` // return credit still expected
+	Entries        []SupplierLedgerEntryDTO `json:"entries"`
+}
+
+// SupplierProductDTO - a product linked to a supplier
+type SupplierProductDTO struct {
+	ProductID       string  `json:"productId"`
+	Name            string  `json:"name"`
+	Strength        string  `json:"strength,omitempty"`
+	IsPrimary       bool    `json:"isPrimary"`
+	BuyingPrice     float64 `json:"buyingPrice"` // unit cost on the last purchase
+	LastPurchasedAt string  `json:"lastPurchasedAt,omitempty"`
+	AvailableStock  int     `json:"availableStock"`
+}
+
+// SupplierStatsDTO - purchase totals for a supplier
+type SupplierStatsDTO struct {
+	TotalPurchases      int      `json:"totalPurchases"`
+	TotalSpend          float64  `json:"totalSpend"`
+	Payable             float64  `json:"payable"`
+	AverageLeadTimeDays *float64 `json:"averageLeadTimeDays"` // null until purchases carry an order date
+	LastPurchaseAt      string   `json:"lastPurchaseAt,omitempty"`
+}
+
+// SupplierDetailDTO - Response data for GET /api/suppliers/{id}
+type SupplierDetailDTO struct {
+	SupplierDTO
+	Products        []SupplierProductDTO      `json:"products"`
+	RecentPurchases []PurchaseDTO             `json:"recentPurchases"`
+	Stats           SupplierStatsDTO          `json:"stats"`
+	ReturnCredits   *SupplierReturnCreditsDTO `json:"returnCredits"`
+}
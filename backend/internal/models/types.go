@@ -1,6 +1,9 @@
 package models
 
 // Enums
+
+// PackType is a pack_unit code; unit, strip and box are always there, others
+// (bottle, vial, carton, ...) come from the pack_unit table
 type PackType string
 
 const (
@@ -16,8 +19,83 @@ const (
 	StockChangeSold           StockChangeType = "sold"
 	StockChangeCompanyReturn  StockChangeType = "company_return"
 	StockChangeCustomerReturn StockChangeType = "customer_return"
+	StockChangeAdjustment     StockChangeType = "adjustment"
+	StockChangeQuarantine     StockChangeType = "quarantine"
+	StockChangeRecall         StockChangeType = "recall"
+)
+
+// BatchStatus tells whether a batch's units can be sold
+type BatchStatus string
+
+const (
+	BatchStatusActive      BatchStatus = "active"
+	BatchStatusQuarantined BatchStatus = "quarantined"
+	BatchStatusRecalled    BatchStatus = "recalled"
+)
+
+// DrugSchedule classifies controlled substances. Scheduled products keep a
+// controlled substance register; "" means not scheduled.
+type DrugSchedule string
+
+const (
+	DrugScheduleNone         DrugSchedule = ""
+	DrugScheduleNarcotic     DrugSchedule = "narcotic"
+	DrugSchedulePsychotropic DrugSchedule = "psychotropic"
+)
+
+// Valid reports whether s is a known schedule (or none)
+func (s DrugSchedule) Valid() bool {
+	switch s {
+	case DrugScheduleNone, DrugScheduleNarcotic, DrugSchedulePsychotropic:
+		return true
+	}
+	return false
+}
+
+// ExpiryBucket groups batches by how soon they expire
+type ExpiryBucket string
+
+const (
+	ExpiryBucketExpired  ExpiryBucket = "expired"
+	ExpiryBucketWithin30 ExpiryBucket = "within_30"
+	ExpiryBucketWithin60 ExpiryBucket = "within_60"
+	ExpiryBucketWithin90 ExpiryBucket = "within_90"
+	ExpiryBucketLater    ExpiryBucket = "later"
+	ExpiryBucketNoExpiry ExpiryBucket = "no_expiry"
 )
 
+// Valid reports whether b is a known expiry bucket
+func (b ExpiryBucket) Valid() bool {
+	switch b {
+	case ExpiryBucketExpired, ExpiryBucketWithin30, ExpiryBucketWithin60,
+		ExpiryBucketWithin90, ExpiryBucketLater, ExpiryBucketNoExpiry:
+		return true
+	}
+	return false
+}
+
+// StockAdjustmentReason explains a manual 'adjustment' stock movement
+type StockAdjustmentReason string
+
+const (
+	AdjustmentCountCorrection StockAdjustmentReason = "count_correction"
+	AdjustmentDamaged         StockAdjustmentReason = "damaged"
+	AdjustmentExpired         StockAdjustmentReason = "expired"
+	AdjustmentLost            StockAdjustmentReason = "lost"
+	AdjustmentFound           StockAdjustmentReason = "found"
+	AdjustmentOpeningBalance  StockAdjustmentReason = "opening_balance"
+)
+
+// Valid reports whether r is a known adjustment reason
+func (r StockAdjustmentReason) Valid() bool {
+	switch r {
+	case AdjustmentCountCorrection, AdjustmentDamaged, AdjustmentExpired,
+		AdjustmentLost, AdjustmentFound, AdjustmentOpeningBalance:
+		return true
+	}
+	return false
+}
+
 type InvoiceStatus string
 
 const (
@@ -32,6 +110,39 @@ const (
 	InvoiceTypeCash        InvoiceType = "cash"
 )
 
+// PaymentMethod records how money was paid or received
+type PaymentMethod string
+
+const (
+	PaymentCash         PaymentMethod = "cash"
+	PaymentCard         PaymentMethod = "card"
+	PaymentMobile       PaymentMethod = "mobile"
+	PaymentBankTransfer PaymentMethod = "bank_transfer"
+	PaymentCheque       PaymentMethod = "cheque"
+)
+
+// Valid reports whether m is a known payment method
+func (m PaymentMethod) Valid() bool {
+	switch m {
+	case PaymentCash, PaymentCard, PaymentMobile, PaymentBankTransfer, PaymentCheque:
+		return true
+	}
+	return false
+}
+
+// LedgerEntryType is the kind of a customer or supplier ledger line
+type LedgerEntryType string
+
+const (
+	LedgerInvoice       LedgerEntryType = "invoice"         // amount charged to a customer
+	LedgerPaidAtSale    LedgerEntryType = "paid_at_sale"    // paid at the counter with the invoice
+	LedgerPayment       LedgerEntryType = "payment"         // later payment against open invoices or purchases
+	LedgerReturn        LedgerEntryType = "return"          // credit note taken off a customer's balance
+	LedgerPurchase      LedgerEntryType = "purchase"        // goods received from a supplier
+	LedgerPaidOnReceipt LedgerEntryType = "paid_on_receipt" // paid to the supplier with the delivery
+	LedgerReturnCredit  LedgerEntryType = "return_credit"   // supplier credit for a company return
+)
+
 type PurchaseStatus string
 
 const (
@@ -39,3 +150,52 @@ const (
 	PurchaseStatusDue     PurchaseStatus = "due"
 	PurchaseStatusPartial PurchaseStatus = "partial"
 )
+
+// CompanyReturnReason explains why batches are sent back to a supplier
+type CompanyReturnReason string
+
+const (
+	CompanyReturnNearExpiry CompanyReturnReason = "near_expiry"
+	CompanyReturnExpired    CompanyReturnReason = "expired"
+	CompanyReturnRecalled   CompanyReturnReason = "recalled"
+	CompanyReturnDamaged    CompanyReturnReason = "damaged"
+	CompanyReturnOther      CompanyReturnReason = "other"
+)
+
+// Valid reports whether r is a known company return reason
+func (r CompanyReturnReason) Valid() bool {
+	switch r {
+	case CompanyReturnNearExpiry, CompanyReturnExpired, CompanyReturnRecalled,
+		CompanyReturnDamaged, CompanyReturnOther:
+		return true
+	}
+	return false
+}
+
+// CompanyReturnStatus tracks whether the supplier has settled a return's credit
+type CompanyReturnStatus string
+
+const (
+	CompanyReturnStatusOpen    CompanyReturnStatus = "open"
+	CompanyReturnStatusPartial CompanyReturnStatus = "partial"
+	CompanyReturnStatusSettled CompanyReturnStatus = "settled"
+)
+
+// Role controls which API operations a user may perform
+type Role string
+
+const (
+	RoleOwner      Role = "owner"
+	RolePharmacist Role = "pharmacist"
+	RoleCashier    Role = "cashier"
+	RoleStockClerk Role = "stock_clerk"
+)
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	switch r {
+	case RoleOwner, RolePharmacist, RoleCashier, RoleStockClerk:
+		return true
+	}
+	return false
+}
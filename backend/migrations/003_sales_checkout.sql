@@ -0,0 +1,12 @@
+-- Checkout: store per-line pricing on invoice items and invoice VAT
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS vat DECIMAL(10, 2) DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS units INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS unit_price DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS discount DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS vat DECIMAL(10, 2) NOT NULL DEFAULT 0;
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS total DECIMAL(10, 2) NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_invoice_items_invoice ON invoice_items(invoice_id);
+
+-- Stock is now deducted (with history) by the checkout transaction in the API
+DROP TRIGGER IF EXISTS increment_sales_on_invoice ON invoice_items;
+DROP FUNCTION IF EXISTS update_product_sales();
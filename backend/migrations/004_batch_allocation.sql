@@ -0,0 +1,19 @@
+-- FEFO allocation: record which batch (and how many units) each invoice line consumed
+CREATE TABLE IF NOT EXISTS invoice_item_batches (
+    id SERIAL PRIMARY KEY,
+    invoice_item_id INTEGER REFERENCES invoice_items(id) ON DELETE CASCADE,
+    batch_fk_id INTEGER REFERENCES product_batch(id),
+    quantity INTEGER NOT NULL,
+    cost_price DECIMAL(10, 2) DEFAULT 0.00,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_invoice_item_batches_item ON invoice_item_batches(invoice_item_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_item_batches_batch ON invoice_item_batches(batch_fk_id);
+
+-- Stock that is not held in any batch goes into an OPENING batch,
+-- so available_stock equals the sum of batch quantities
+INSERT INTO product_batch (product_id, batch_id, quantity, purchase_date, cost_price)
+SELECT p.id, 'OPENING', p.available_stock - COALESCE(b.qty, 0), CURRENT_DATE, p.unit_cost_price
+FROM product p
+LEFT JOIN (SELECT product_id, SUM(quantity) AS qty FROM product_batch GROUP BY product_id) b ON b.product_id = p.id
+WHERE p.available_stock > COALESCE(b.qty, 0);
@@ -0,0 +1,15 @@
+-- Goods receipt: link purchases to suppliers and record batch details per line
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS supplier_id INTEGER REFERENCES supplier(id);
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS invoice_ref VARCHAR(100);
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS received_date DATE DEFAULT CURRENT_DATE;
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS notes TEXT;
+
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS units INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS batch_no VARCHAR(100);
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS expiry_date DATE;
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS cost_price DECIMAL(10, 2) DEFAULT 0.00;
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS total DECIMAL(10, 2) DEFAULT 0.00;
+ALTER TABLE product_stock_purchase_items ADD COLUMN IF NOT EXISTS batch_fk_id INTEGER REFERENCES product_batch(id);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_supplier ON product_stock_purchase(supplier_id);
+CREATE INDEX IF NOT EXISTS idx_purchase_items_purchase ON product_stock_purchase_items(psp_fk_id);
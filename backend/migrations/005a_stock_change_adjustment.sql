@@ -0,0 +1,4 @@
+-- The 'adjustment' stock change type, in its own file: a value added with
+-- ALTER TYPE ... ADD VALUE cannot be used in the same transaction, and
+-- migration 006 books opening balances with it
+ALTER TYPE stock_change_type ADD VALUE IF NOT EXISTS 'adjustment';
@@ -0,0 +1,35 @@
+-- Stock ledger: every stock movement is an append-only product_stock_history
+-- row. The 'adjustment' change type comes from migration 005a.
+
+ALTER TABLE product_stock_history ADD COLUMN IF NOT EXISTS reference_type VARCHAR(50);
+ALTER TABLE product_stock_history ADD COLUMN IF NOT EXISTS reference_id INTEGER;
+ALTER TABLE product_stock_history ADD COLUMN IF NOT EXISTS reason_code VARCHAR(50);
+ALTER TABLE product_stock_history ADD COLUMN IF NOT EXISTS note TEXT;
+ALTER TABLE product_stock_history ADD COLUMN IF NOT EXISTS user_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_history_product ON product_stock_history(product_id_fk, id);
+
+-- Opening balance so that available_stock equals the sum of ledger movements
+INSERT INTO product_stock_history (
+    product_id_fk, change_amount, change_type, previous_quantity, new_quantity,
+    reference_type, reason_code, note
+)
+SELECT p.id, p.available_stock - COALESCE(h.total, 0), 'adjustment', COALESCE(h.total, 0), p.available_stock,
+       'adjustment', 'opening_balance', 'Opening balance from existing stock'
+FROM product p
+LEFT JOIN (SELECT product_id_fk, SUM(change_amount) AS total FROM product_stock_history GROUP BY product_id_fk) h
+    ON h.product_id_fk = p.id
+WHERE p.available_stock <> COALESCE(h.total, 0);
+
+-- Ledger rows can never be edited or removed
+CREATE OR REPLACE FUNCTION prevent_stock_history_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'product_stock_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS stock_history_append_only ON product_stock_history;
+CREATE TRIGGER stock_history_append_only
+    BEFORE UPDATE OR DELETE ON product_stock_history
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_stock_history_change();
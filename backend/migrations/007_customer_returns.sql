@@ -0,0 +1,40 @@
+-- Customer returns: credit notes against an invoice, restocked into the original batch
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS returned_amount DECIMAL(10, 2) DEFAULT 0;
+ALTER TABLE invoice_item_batches ADD COLUMN IF NOT EXISTS returned_quantity INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS customer_return (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER REFERENCES invoice(id),
+    customer_id_fk INTEGER REFERENCES customer(id),
+    total DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    credited_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    refund_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    reason TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS customer_return_items (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER REFERENCES customer_return(id) ON DELETE CASCADE,
+    invoice_item_id INTEGER REFERENCES invoice_items(id),
+    product_id INTEGER REFERENCES product(id),
+    pack_type pack_type_enum NOT NULL,
+    quantity DECIMAL(10, 2) NOT NULL,
+    units INTEGER NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    damaged BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Which batches returned units went back to (or came from, when damaged)
+CREATE TABLE IF NOT EXISTS customer_return_item_batches (
+    id SERIAL PRIMARY KEY,
+    return_item_id INTEGER REFERENCES customer_return_items(id) ON DELETE CASCADE,
+    batch_fk_id INTEGER REFERENCES product_batch(id),
+    quantity INTEGER NOT NULL,
+    cost_price DECIMAL(10, 2) DEFAULT 0.00
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_return_invoice ON customer_return(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_customer_return_items_item ON customer_return_items(invoice_item_id);
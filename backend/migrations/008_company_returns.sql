@@ -0,0 +1,27 @@
+-- Company returns: batches sent back to a supplier, with the credit we expect for them
+CREATE TABLE IF NOT EXISTS company_return (
+    id SERIAL PRIMARY KEY,
+    supplier_id INTEGER REFERENCES supplier(id),
+    reason VARCHAR(30) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- open, partial, settled
+    expected_credit DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    settled_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    settled_at TIMESTAMP,
+    notes TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS company_return_items (
+    id SERIAL PRIMARY KEY,
+    return_id INTEGER REFERENCES company_return(id) ON DELETE CASCADE,
+    batch_fk_id INTEGER REFERENCES product_batch(id),
+    product_id INTEGER REFERENCES product(id),
+    quantity INTEGER NOT NULL,
+    cost_price DECIMAL(10, 2) DEFAULT 0.00,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_company_return_supplier ON company_return(supplier_id, status);
+CREATE INDEX IF NOT EXISTS idx_company_return_items_return ON company_return_items(return_id);
@@ -0,0 +1,30 @@
+-- API users, roles and refresh tokens
+CREATE TABLE IF NOT EXISTS app_user (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(100) NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    full_name VARCHAR(255),
+    role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'pharmacist', 'cashier', 'stock_clerk')),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_app_user_username ON app_user(LOWER(username));
+
+-- Only the SHA-256 of a refresh token is stored
+CREATE TABLE IF NOT EXISTS refresh_token (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES app_user(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_token_user ON refresh_token(user_id);
+
+-- Who posted sales and goods receipts
+ALTER TABLE invoice ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES app_user(id);
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES app_user(id);
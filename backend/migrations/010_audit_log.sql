@@ -0,0 +1,30 @@
+-- Audit trail: one row per changed field, written in the same transaction as the change
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity VARCHAR(50) NOT NULL,       -- product, supplier, customer, user
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL,       -- create, update, delete
+    field VARCHAR(100),
+    old_value TEXT,
+    new_value TEXT,
+    user_id INTEGER REFERENCES app_user(id),
+    username VARCHAR(100),
+    request_id VARCHAR(64),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity, entity_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_request ON audit_log(request_id);
+
+CREATE OR REPLACE FUNCTION prevent_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_audit_log_change();
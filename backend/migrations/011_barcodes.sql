@@ -0,0 +1,44 @@
+-- Barcodes: generated EAN-13 codes are numbered from a sequence, and a code
+-- resolves to exactly one product (unit) or pack (strip/box)
+CREATE SEQUENCE IF NOT EXISTS product_barcode_seq;
+
+ALTER TABLE product_packaging ADD COLUMN IF NOT EXISTS barcode VARCHAR(32);
+
+-- Products created before this migration all got the same placeholder code;
+-- keep it on the oldest live product and clear the rest, then generate theirs
+UPDATE product SET barcode = NULL WHERE barcode = '';
+UPDATE product p SET barcode = NULL
+WHERE p.deleted = 0 AND p.barcode IS NOT NULL
+  AND EXISTS (
+      SELECT 1 FROM product q
+      WHERE q.barcode = p.barcode AND q.deleted = 0 AND q.id < p.id
+  );
+
+-- Give every live product without a code a generated EAN-13, as new products
+-- get: prefix 200 (the BARCODE_PREFIX default), a 9-digit number from the
+-- sequence and the GS1 check digit, skipping numbers already in use
+DO $$
+DECLARE
+    r RECORD;
+    body TEXT;
+    code TEXT;
+    total INTEGER;
+BEGIN
+    FOR r IN SELECT id FROM product WHERE deleted = 0 AND barcode IS NULL ORDER BY id LOOP
+        LOOP
+            body := '200' || lpad(nextval('product_barcode_seq')::text, 9, '0');
+            total := 0;
+            FOR i IN 1..12 LOOP
+                total := total + substr(body, i, 1)::INTEGER * CASE WHEN i % 2 = 0 THEN 3 ELSE 1 END;
+            END LOOP;
+            code := body || ((10 - total % 10) % 10)::text;
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM product WHERE barcode = code)
+                  AND NOT EXISTS (SELECT 1 FROM product_packaging WHERE barcode = code);
+        END LOOP;
+        UPDATE product SET barcode = code WHERE id = r.id;
+    END LOOP;
+END $$;
+
+DROP INDEX IF EXISTS idx_product_barcode;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_barcode ON product(barcode) WHERE deleted = 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_packaging_barcode ON product_packaging(barcode);
@@ -0,0 +1,19 @@
+-- Product codes: running counters per code prefix, and one live product per code
+CREATE TABLE IF NOT EXISTS product_code_counter (
+    prefix VARCHAR(255) PRIMARY KEY,
+    last_value INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Older codes were cut from the name and strength and often collided;
+-- suffix later duplicates with the product id so the index can be unique
+UPDATE product SET product_code = NULL WHERE product_code = '';
+UPDATE product p SET product_code = p.product_code || '-' || p.id
+WHERE p.deleted = 0 AND p.product_code IS NOT NULL
+  AND EXISTS (
+      SELECT 1 FROM product q
+      WHERE q.product_code = p.product_code AND q.deleted = 0 AND q.id < p.id
+  );
+
+DROP INDEX IF EXISTS idx_product_code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_code ON product(product_code) WHERE deleted = 0;
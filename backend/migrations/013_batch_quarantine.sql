@@ -0,0 +1,16 @@
+-- Batch status: quarantined batches keep their units on the shelf record but
+-- are excluded from sales and from available_stock
+ALTER TYPE stock_change_type ADD VALUE IF NOT EXISTS 'quarantine';
+
+ALTER TABLE product_batch ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active';
+ALTER TABLE product_batch ADD COLUMN IF NOT EXISTS quarantined_at TIMESTAMP;
+ALTER TABLE product_batch ADD COLUMN IF NOT EXISTS quarantined_by INTEGER;
+ALTER TABLE product_batch ADD COLUMN IF NOT EXISTS quarantine_note TEXT;
+
+ALTER TABLE product_batch DROP CONSTRAINT IF EXISTS product_batch_status_check;
+ALTER TABLE product_batch ADD CONSTRAINT product_batch_status_check
+    CHECK (status IN ('active', 'quarantined'));
+
+-- The expiry report only reads batches with stock; init.sql's
+-- idx_product_batch_expiry covers every batch
+CREATE INDEX IF NOT EXISTS idx_product_batch_expiry_in_stock ON product_batch(expiry_date) WHERE quantity > 0;
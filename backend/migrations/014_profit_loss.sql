@@ -0,0 +1,18 @@
+-- Stock written off (expired, damaged, lost), valued at the cost of the
+-- batches the units came out of, for the profit and loss report
+CREATE TABLE IF NOT EXISTS stock_write_off (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER REFERENCES product(id),
+    batch_fk_id INTEGER REFERENCES product_batch(id),
+    quantity INTEGER NOT NULL,
+    cost_price DECIMAL(10, 2) DEFAULT 0.00,
+    amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    reason VARCHAR(30) NOT NULL,
+    note TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_write_off_created ON stock_write_off(created_at);
+CREATE INDEX IF NOT EXISTS idx_invoice_created ON invoice(created_at) WHERE deleted = 0;
+CREATE INDEX IF NOT EXISTS idx_customer_return_created ON customer_return(created_at);
@@ -0,0 +1,26 @@
+-- Customer credit: payments received against outstanding invoices and a
+-- credit limit per customer (0 means no limit)
+ALTER TABLE customer ADD COLUMN IF NOT EXISTS credit_limit DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS customer_payment (
+    id SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES customer(id),
+    amount DECIMAL(10, 2) NOT NULL,
+    method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    reference VARCHAR(100),
+    notes TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- How each payment was applied to invoices, oldest invoice first
+CREATE TABLE IF NOT EXISTS customer_payment_allocation (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES customer_payment(id) ON DELETE CASCADE,
+    invoice_id INTEGER NOT NULL REFERENCES invoice(id),
+    amount DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_payment_customer ON customer_payment(customer_id);
+CREATE INDEX IF NOT EXISTS idx_customer_payment_allocation_invoice ON customer_payment_allocation(invoice_id);
+CREATE INDEX IF NOT EXISTS idx_invoice_open_balance ON invoice(customer_id_fk, created_at) WHERE deleted = 0 AND balance > 0;
@@ -0,0 +1,53 @@
+-- Supplier payables: purchase amounts in decimal money, payments made to
+-- suppliers and how they (and return credits) were applied to purchases
+ALTER TABLE product_stock_purchase ALTER COLUMN total TYPE DECIMAL(10, 2);
+ALTER TABLE product_stock_purchase ALTER COLUMN due TYPE DECIMAL(10, 2);
+ALTER TABLE product_stock_purchase ALTER COLUMN paid_amount TYPE DECIMAL(10, 2);
+
+-- Purchases recorded before goods receipt have no supplier; take it from the
+-- batches they created where possible
+UPDATE product_stock_purchase psp
+SET supplier_id = (
+    SELECT b.supplier_id
+    FROM product_stock_purchase_items pi
+    JOIN product_batch b ON pi.batch_fk_id = b.id
+    WHERE pi.psp_fk_id = psp.id AND b.supplier_id IS NOT NULL
+    LIMIT 1
+)
+WHERE supplier_id IS NULL;
+
+-- New purchases must name a supplier; NOT VALID leaves unresolved old rows alone
+ALTER TABLE product_stock_purchase DROP CONSTRAINT IF EXISTS product_stock_purchase_supplier_required;
+ALTER TABLE product_stock_purchase ADD CONSTRAINT product_stock_purchase_supplier_required
+    CHECK (supplier_id IS NOT NULL) NOT VALID;
+
+-- A payment to a supplier, or a credit for a company return when
+-- company_return_id is set
+CREATE TABLE IF NOT EXISTS supplier_payment (
+    id SERIAL PRIMARY KEY,
+    supplier_id INTEGER NOT NULL REFERENCES supplier(id),
+    amount DECIMAL(10, 2) NOT NULL,
+    method VARCHAR(20),
+    reference VARCHAR(100),
+    notes TEXT,
+    company_return_id INTEGER REFERENCES company_return(id),
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS supplier_payment_allocation (
+    id SERIAL PRIMARY KEY,
+    payment_id INTEGER NOT NULL REFERENCES supplier_payment(id) ON DELETE CASCADE,
+    purchase_id INTEGER NOT NULL REFERENCES product_stock_purchase(id),
+    amount DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_supplier_payment_supplier ON supplier_payment(supplier_id);
+CREATE INDEX IF NOT EXISTS idx_supplier_payment_allocation_purchase ON supplier_payment_allocation(purchase_id);
+
+-- Credits already settled on company returns appear in the ledger unapplied
+INSERT INTO supplier_payment (supplier_id, amount, company_return_id, notes, created_at)
+SELECT cr.supplier_id, cr.settled_amount, cr.id, 'Settled before supplier payables', COALESCE(cr.settled_at, cr.updated_at)
+FROM company_return cr
+WHERE cr.settled_amount > 0 AND cr.supplier_id IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM supplier_payment sp WHERE sp.company_return_id = cr.id);
@@ -0,0 +1,4 @@
+-- When the goods were ordered, so supplier lead time can be measured
+ALTER TABLE product_stock_purchase ADD COLUMN IF NOT EXISTS ordered_date DATE;
+
+CREATE INDEX IF NOT EXISTS idx_product_supplier_supplier ON product_supplier(supplier_id);
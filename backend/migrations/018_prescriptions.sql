@@ -0,0 +1,34 @@
+-- Prescriptions: products flagged requires_prescription are only sold
+-- against a prescription item with fills left
+ALTER TABLE product ADD COLUMN IF NOT EXISTS requires_prescription BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS prescription (
+    id SERIAL PRIMARY KEY,
+    customer_id_fk INTEGER REFERENCES customer(id),
+    patient_name VARCHAR(255) NOT NULL,
+    prescriber_name VARCHAR(255) NOT NULL,
+    prescriber_reg_no VARCHAR(100) NOT NULL,
+    issue_date DATE NOT NULL,
+    notes TEXT,
+    image TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- quantity is in base units per fill; refills are the repeats allowed after
+-- the first fill
+CREATE TABLE IF NOT EXISTS prescription_item (
+    id SERIAL PRIMARY KEY,
+    prescription_id INTEGER NOT NULL REFERENCES prescription(id) ON DELETE CASCADE,
+    product_id INTEGER NOT NULL REFERENCES product(id),
+    dosage TEXT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    refills INTEGER NOT NULL DEFAULT 0 CHECK (refills >= 0),
+    fills_dispensed INTEGER NOT NULL DEFAULT 0,
+    UNIQUE (prescription_id, product_id)
+);
+
+ALTER TABLE invoice_items ADD COLUMN IF NOT EXISTS prescription_item_id INTEGER REFERENCES prescription_item(id);
+
+CREATE INDEX IF NOT EXISTS idx_prescription_customer ON prescription(customer_id_fk);
+CREATE INDEX IF NOT EXISTS idx_invoice_items_prescription ON invoice_items(prescription_item_id);
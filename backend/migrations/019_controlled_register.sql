@@ -0,0 +1,54 @@
+-- Controlled substance register: every stock movement of a scheduled product
+-- (narcotic, psychotropic) gets a register entry linked to its ledger row
+ALTER TABLE product ADD COLUMN IF NOT EXISTS schedule VARCHAR(20);
+
+CREATE TABLE IF NOT EXISTS controlled_register (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES product(id),
+    stock_history_id INTEGER UNIQUE REFERENCES product_stock_history(id),
+    schedule VARCHAR(20) NOT NULL,
+    entry_type VARCHAR(30) NOT NULL, -- ledger change type, or 'opening'
+    quantity_in INTEGER NOT NULL DEFAULT 0,
+    quantity_out INTEGER NOT NULL DEFAULT 0,
+    balance INTEGER NOT NULL,
+    reference_type VARCHAR(50),
+    reference_id INTEGER,
+    counterparty VARCHAR(255),
+    prescriber_name VARCHAR(255),
+    prescriber_reg_no VARCHAR(100),
+    note TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Batches an entry's units came from or went to
+CREATE TABLE IF NOT EXISTS controlled_register_batch (
+    id SERIAL PRIMARY KEY,
+    register_id INTEGER NOT NULL REFERENCES controlled_register(id),
+    batch_fk_id INTEGER REFERENCES product_batch(id),
+    batch_no VARCHAR(100),
+    quantity INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_controlled_register_product ON controlled_register(product_id, id);
+CREATE INDEX IF NOT EXISTS idx_controlled_register_batch_entry ON controlled_register_batch(register_id);
+
+-- Register rows can never be edited or removed
+CREATE OR REPLACE FUNCTION prevent_controlled_register_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS controlled_register_append_only ON controlled_register;
+CREATE TRIGGER controlled_register_append_only
+    BEFORE UPDATE OR DELETE ON controlled_register
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_controlled_register_change();
+
+DROP TRIGGER IF EXISTS controlled_register_batch_append_only ON controlled_register_batch;
+CREATE TRIGGER controlled_register_batch_append_only
+    BEFORE UPDATE OR DELETE ON controlled_register_batch
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_controlled_register_change();
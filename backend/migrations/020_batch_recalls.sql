@@ -0,0 +1,32 @@
+-- Batch recalls: recalled batches are blocked from sale like quarantined ones,
+-- and their remaining stock goes back to the supplier on a company return
+ALTER TYPE stock_change_type ADD VALUE IF NOT EXISTS 'recall';
+
+ALTER TABLE product_batch DROP CONSTRAINT IF EXISTS product_batch_status_check;
+ALTER TABLE product_batch ADD CONSTRAINT product_batch_status_check
+    CHECK (status IN ('active', 'quarantined', 'recalled'));
+
+CREATE TABLE IF NOT EXISTS recall (
+    id SERIAL PRIMARY KEY,
+    product_id INTEGER NOT NULL REFERENCES product(id),
+    reference VARCHAR(100), -- manufacturer or regulator notice number
+    reason TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Batches covered by a recall, with the units on hand when it was raised
+CREATE TABLE IF NOT EXISTS recall_batch (
+    id SERIAL PRIMARY KEY,
+    recall_id INTEGER NOT NULL REFERENCES recall(id) ON DELETE CASCADE,
+    batch_fk_id INTEGER NOT NULL REFERENCES product_batch(id),
+    quantity INTEGER NOT NULL DEFAULT 0,
+    previous_status VARCHAR(20),
+    UNIQUE (batch_fk_id)
+);
+
+ALTER TABLE company_return ADD COLUMN IF NOT EXISTS recall_id INTEGER REFERENCES recall(id);
+
+CREATE INDEX IF NOT EXISTS idx_recall_product ON recall(product_id);
+CREATE INDEX IF NOT EXISTS idx_recall_batch_recall ON recall_batch(recall_id);
+CREATE INDEX IF NOT EXISTS idx_company_return_recall ON company_return(recall_id);
@@ -0,0 +1,30 @@
+-- Pack units: packaging levels are data instead of the fixed unit/strip/box
+-- enum, so products can come in bottles, vials, ampoules, sachets, tubes or
+-- cartons, with any number of levels per product
+CREATE TABLE IF NOT EXISTS pack_unit (
+    code VARCHAR(30) PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    plural_name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO pack_unit (code, name, plural_name) VALUES
+    ('unit', 'unit', 'units'),
+    ('strip', 'strip', 'strips'),
+    ('box', 'box', 'boxes'),
+    ('bottle', 'bottle', 'bottles'),
+    ('vial', 'vial', 'vials'),
+    ('ampoule', 'ampoule', 'ampoules'),
+    ('sachet', 'sachet', 'sachets'),
+    ('tube', 'tube', 'tubes'),
+    ('carton', 'carton', 'cartons')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE product_packaging ALTER COLUMN pack_type TYPE VARCHAR(30) USING pack_type::text;
+ALTER TABLE invoice_items ALTER COLUMN pack_type TYPE VARCHAR(30) USING pack_type::text;
+ALTER TABLE product_stock_purchase_items ALTER COLUMN pack_type TYPE VARCHAR(30) USING pack_type::text;
+ALTER TABLE customer_return_items ALTER COLUMN pack_type TYPE VARCHAR(30) USING pack_type::text;
+
+ALTER TABLE product_packaging DROP CONSTRAINT IF EXISTS product_packaging_pack_type_fkey;
+ALTER TABLE product_packaging ADD CONSTRAINT product_packaging_pack_type_fkey
+    FOREIGN KEY (pack_type) REFERENCES pack_unit(code);
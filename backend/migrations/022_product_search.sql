@@ -0,0 +1,53 @@
+-- Product search: a search document per product (name, generic,
+-- manufacturer, strength, barcode, code) kept up to date by trigger, with a
+-- trigram index for typos and substrings and a weighted tsvector for ranked
+-- prefix matching
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE product ADD COLUMN IF NOT EXISTS search_text TEXT NOT NULL DEFAULT '';
+ALTER TABLE product ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+CREATE OR REPLACE FUNCTION product_search_refresh()
+RETURNS TRIGGER AS $$
+DECLARE
+    generic TEXT;
+BEGIN
+    SELECT generic_name INTO generic FROM generic_name WHERE id = NEW.generic_fk_id;
+
+    NEW.search_text := lower(concat_ws(' ', NEW.product_name, generic, NEW.manufacture,
+                                       NEW.strength, NEW.barcode, NEW.product_code));
+    NEW.search_vector :=
+        setweight(to_tsvector('simple', COALESCE(NEW.product_name, '')), 'A') ||
+        setweight(to_tsvector('simple', COALESCE(generic, '')), 'B') ||
+        setweight(to_tsvector('simple', concat_ws(' ', NEW.manufacture, NEW.strength)), 'C') ||
+        setweight(to_tsvector('simple', concat_ws(' ', NEW.barcode, NEW.product_code)), 'D');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS product_search_refresh ON product;
+CREATE TRIGGER product_search_refresh
+    BEFORE INSERT OR UPDATE OF product_name, generic_fk_id, manufacture, strength, barcode, product_code ON product
+    FOR EACH ROW
+    EXECUTE FUNCTION product_search_refresh();
+
+-- Renaming a generic refreshes the products that use it
+CREATE OR REPLACE FUNCTION generic_name_search_refresh()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE product SET generic_fk_id = generic_fk_id WHERE generic_fk_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS generic_name_search_refresh ON generic_name;
+CREATE TRIGGER generic_name_search_refresh
+    AFTER UPDATE OF generic_name ON generic_name
+    FOR EACH ROW
+    EXECUTE FUNCTION generic_name_search_refresh();
+
+UPDATE product SET product_name = product_name;
+
+CREATE INDEX IF NOT EXISTS idx_product_search_trgm ON product USING GIN (search_text gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_product_search_vector ON product USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_product_name_lower ON product (lower(product_name) text_pattern_ops);
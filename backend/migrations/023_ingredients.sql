@@ -0,0 +1,75 @@
+-- Active ingredients: products link to any number of ingredients, each with
+-- a numeric strength and unit, instead of one free-text generic name and
+-- strength. generic_name stays as the display name.
+CREATE TABLE IF NOT EXISTS ingredient (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_name ON ingredient (lower(name));
+
+CREATE TABLE IF NOT EXISTS product_ingredient (
+    product_id INTEGER NOT NULL REFERENCES product(id) ON DELETE CASCADE,
+    ingredient_id INTEGER NOT NULL REFERENCES ingredient(id),
+    strength NUMERIC(12, 4) CHECK (strength IS NULL OR strength >= 0),
+    strength_unit VARCHAR(30),
+    position INTEGER NOT NULL DEFAULT 1,
+    PRIMARY KEY (product_id, ingredient_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_product_ingredient_ingredient ON product_ingredient(ingredient_id);
+
+-- Split combination generics ("Rosuvastatin + Ezetimibe",
+-- "Amoxicillin/Clavulanic Acid", "Paracetamol and Caffeine") into ingredients
+INSERT INTO ingredient (name)
+SELECT DISTINCT ON (lower(part)) part
+FROM (
+    SELECT trim(regexp_replace(part, '\s+', ' ', 'g')) AS part
+    FROM generic_name,
+         regexp_split_to_table(generic_name, '\s*(\+|/|,|&|\mand\M|\mwith\M)\s*', 'i') AS part
+) parts
+WHERE part <> ''
+ORDER BY lower(part), part
+ON CONFLICT DO NOTHING;
+
+-- Link products to their ingredients. Strengths pair up with ingredients in
+-- order when product.strength has one per ingredient ("10mg + 10mg");
+-- a single ingredient takes the whole strength ("125mg/5ml")
+WITH names AS (
+    SELECT p.id AS product_id, lower(trim(regexp_replace(t.part, '\s+', ' ', 'g'))) AS name,
+           row_number() OVER (PARTITION BY p.id ORDER BY t.ord) AS position
+    FROM product p
+    JOIN generic_name g ON g.id = p.generic_fk_id
+    CROSS JOIN LATERAL regexp_split_to_table(g.generic_name, '\s*(\+|/|,|&|\mand\M|\mwith\M)\s*', 'i')
+        WITH ORDINALITY AS t(part, ord)
+    WHERE trim(t.part) <> ''
+),
+counted AS (
+    SELECT n.*, count(*) OVER (PARTITION BY n.product_id) AS ingredients
+    FROM (SELECT DISTINCT ON (product_id, name) * FROM names ORDER BY product_id, name, position) n
+),
+strengths AS (
+    SELECT p.id AS product_id, trim(t.part) AS strength, t.ord AS position,
+           count(*) OVER (PARTITION BY p.id) AS parts
+    FROM product p
+    CROSS JOIN LATERAL regexp_split_to_table(COALESCE(p.strength, ''), '\s*(\+|/|,)\s*')
+        WITH ORDINALITY AS t(part, ord)
+),
+paired AS (
+    SELECT c.product_id, i.id AS ingredient_id, c.position,
+           CASE WHEN c.ingredients = 1 THEN trim(COALESCE(p.strength, ''))
+                WHEN s.parts = c.ingredients THEN s.strength
+           END AS strength
+    FROM counted c
+    JOIN ingredient i ON lower(i.name) = c.name
+    JOIN product p ON p.id = c.product_id
+    LEFT JOIN strengths s ON s.product_id = c.product_id AND s.position = c.position
+)
+INSERT INTO product_ingredient (product_id, ingredient_id, strength, strength_unit, position)
+SELECT product_id, ingredient_id,
+       substring(strength FROM '^([0-9]+(?:\.[0-9]+)?)')::NUMERIC,
+       NULLIF(trim(substring(strength FROM '^[0-9]+(?:\.[0-9]+)?\s*(.*)$')), ''),
+       position
+FROM paired
+ON CONFLICT (product_id, ingredient_id) DO NOTHING;
@@ -0,0 +1,10 @@
+-- Per-unit costs keep 4 decimals: a pack cost divided by its units
+-- ("12.50 for 10 tablets" is 1.25, "10.00 for 3" is 3.3333) lost up to half
+-- a cent per unit at 2 decimals, which added up over a batch
+ALTER TABLE product ALTER COLUMN unit_cost_price TYPE DECIMAL(12, 4);
+ALTER TABLE product_supplier ALTER COLUMN buying_price TYPE DECIMAL(12, 4);
+ALTER TABLE product_batch ALTER COLUMN cost_price TYPE DECIMAL(12, 4);
+ALTER TABLE invoice_item_batches ALTER COLUMN cost_price TYPE DECIMAL(12, 4);
+ALTER TABLE customer_return_item_batches ALTER COLUMN cost_price TYPE DECIMAL(12, 4);
+ALTER TABLE company_return_items ALTER COLUMN cost_price TYPE DECIMAL(12, 4);
+ALTER TABLE stock_write_off ALTER COLUMN cost_price TYPE DECIMAL(12, 4);
@@ -0,0 +1,34 @@
+-- Migration 004 only filled the gap when batches held less than
+-- available_stock. Products whose active batches hold more are trimmed to
+-- match, taking the excess off the latest-expiring batches first (the ones
+-- FEFO allocation would reach last). Each product trimmed is listed.
+DO $$
+DECLARE
+    r RECORD;
+BEGIN
+    FOR r IN
+        SELECT p.id, p.product_name, COALESCE(p.available_stock, 0) AS available, b.quantity
+        FROM product p
+        JOIN (SELECT product_id, SUM(quantity) AS quantity FROM product_batch
+              WHERE status = 'active' AND quantity > 0 GROUP BY product_id) b ON b.product_id = p.id
+        WHERE b.quantity > GREATEST(COALESCE(p.available_stock, 0), 0)
+        ORDER BY p.id
+    LOOP
+        RAISE NOTICE 'prod_%: % batches hold % units but available stock is %; trimming batches',
+            lpad(r.id::text, 3, '0'), r.product_name, r.quantity, r.available;
+    END LOOP;
+END $$;
+
+WITH ordered AS (
+    SELECT b.id, b.quantity,
+           GREATEST(COALESCE(p.available_stock, 0), 0) AS available,
+           SUM(b.quantity) OVER (PARTITION BY b.product_id
+                                 ORDER BY b.expiry_date ASC NULLS LAST, b.id ASC) - b.quantity AS earlier
+    FROM product_batch b
+    JOIN product p ON p.id = b.product_id
+    WHERE b.status = 'active' AND b.quantity > 0
+)
+UPDATE product_batch b
+SET quantity = GREATEST(o.available - o.earlier, 0), updated_at = NOW()
+FROM ordered o
+WHERE b.id = o.id AND o.earlier + o.quantity > o.available;
@@ -0,0 +1,11 @@
+#!/bin/sh
+# Applies migrations/*.sql in order on a fresh database, after init.sql.
+# Run by the postgres image from /docker-entrypoint-initdb.d; each file runs
+# in its own transaction and the first error stops the setup.
+set -e
+
+for f in /migrations/*.sql; do
+	echo "applying $(basename "$f")"
+	psql -v ON_ERROR_STOP=1 --single-transaction \
+		--username "$POSTGRES_USER" --dbname "$POSTGRES_DB" -f "$f"
+done